@@ -1,7 +1,18 @@
+// authHeaders adds the API token the hub is configured with, if it has one,
+// to the headers of a request
+function authHeaders(headers){
+    headers = headers || {}
+    if (window.APP_CONFIG.API_TOKEN) {
+        headers['Authorization'] = 'Bearer ' + window.APP_CONFIG.API_TOKEN
+    }
+    return headers
+}
+
 export function GetRecords(bounds){
-    let queryString = "minLat=" + bounds._southWest.lat + "&minLng=" + bounds._southWest.lng + "&maxLat=" + bounds._northEast.lat + "&maxLng=" + bounds._northEast.lng
+    let queryString = "minLat=" + bounds._southWest.lat + "&minLng=" + bounds._southWest.lng + "&maxLat=" + bounds._northEast.lat + "&maxLng=" + bounds._northEast.lng + "&pageSize=500"
     return fetch(window.APP_CONFIG.API_URL + '/record?' + queryString, {
-        method: 'GET'
+        method: 'GET',
+        headers: authHeaders()
     }).then(response => {
         if(!response.ok)
             throw Error(response.status)
@@ -11,10 +22,38 @@ export function GetRecords(bounds){
     });
 }
 
+export function WatchRecords(bounds, onChange){
+    let queryString = "minLat=" + bounds._southWest.lat + "&minLng=" + bounds._southWest.lng + "&maxLat=" + bounds._northEast.lat + "&maxLng=" + bounds._northEast.lng
+    if (!window.EventSource) {
+        return { close: function(){} }
+    }
+    // EventSource can't send headers so the token goes in the query instead
+    if (window.APP_CONFIG.API_TOKEN) {
+        queryString += "&access_token=" + encodeURIComponent(window.APP_CONFIG.API_TOKEN)
+    }
+    let source = new EventSource(window.APP_CONFIG.API_URL + '/events?' + queryString)
+    let types = ["record.created", "record.updated", "record.deleted", "record.restored"]
+    types.forEach(type => {
+        source.addEventListener(type, event => {
+            onChange(JSON.parse(event.data))
+        })
+    })
+    return source
+}
+
+export function GetRecord(id){
+    return fetch(window.APP_CONFIG.API_URL + '/record/' + id, {
+        method: 'GET',
+        headers: authHeaders()
+    }).catch(function(){
+        return null
+    });
+}
+
 export function CreateRecord(record) {
         return fetch(window.APP_CONFIG.API_URL + '/record', {
             method:'POST',
-            headers: {'Content-Type':'application/json'},
+            headers: authHeaders({'Content-Type':'application/json'}),
             body: JSON.stringify(
                 record
             )
@@ -31,12 +70,15 @@ export function CreateRecord(record) {
 export function UpdateRecord(record){
     return fetch(window.APP_CONFIG.API_URL + '/record/' + record.id, {
         method:'PUT',
-        headers: {'Content-Type':'application/json'},
+        headers: authHeaders({
+            'Content-Type':'application/json',
+            'If-Match': '"' + (record.version || 0) + '"'
+        }),
         body: JSON.stringify(
             record
         )
     }).then(response => {
-        if(!response.ok){
+        if(!response.ok && response.status !== 409){
             throw Error(response.statusText)
         }
         return response
@@ -48,7 +90,7 @@ export function UpdateRecord(record){
 export function DeleteRecord(id){
     return fetch(window.APP_CONFIG.API_URL + '/record/' + id, {
         method: 'DELETE',
-        headers: {'Content-Type':'application/json'},
+        headers: authHeaders({'Content-Type':'application/json'}),
     }).then(response => {
         if(!response.ok){
             throw Error(response.statusText)
@@ -61,7 +103,8 @@ export function DeleteRecord(id){
 
 export function LoadFields(){
     return fetch(window.APP_CONFIG.API_URL + '/field', {
-        method: 'GET'
+        method: 'GET',
+        headers: authHeaders()
     }).then(response => {
         if(!response.ok)
             throw Error(response.status)
@@ -76,7 +119,7 @@ export function LoadFields(){
 export function UpdateFields(fields){
     return fetch(window.APP_CONFIG.API_URL + '/field', {
         method:'PUT',
-        headers: {'Content-Type':'application/json'},
+        headers: authHeaders({'Content-Type':'application/json'}),
         body: JSON.stringify(
             fields
         )
@@ -99,7 +142,8 @@ export function UpdateField(fieldId, value){
 
 export function DeleteField(fieldId){
     return fetch(window.APP_CONFIG.API_URL + '/field/' + fieldId, {
-        method: 'DELETE'
+        method: 'DELETE',
+        headers: authHeaders()
     }).then(response => {
         if(!response.ok)
             throw Error(response.status)
@@ -10,7 +10,7 @@ import { ReportDialog } from "../../components/ReportDialog/reportDialog";
 import { MapView } from '../../components/Map/map';
 import { VersionBar } from '../../components/VersionBar/versionBar';
 import { FilterOptions } from '../../components/FilterOptions/filterOptions';
-import { CreateRecord, DeleteRecord, GetRecords, LoadFields, UpdateRecord } from '../../data/api'
+import { CreateRecord, DeleteRecord, GetRecord, GetRecords, LoadFields, UpdateRecord, WatchRecords } from '../../data/api'
 import { ToastContainer, toast } from 'react-toastify';
 import '../../react-toastify.css';
 import MenuBar from '../../components/MenuBar/menuBar';
@@ -60,6 +60,54 @@ class Home extends Component {
     });
   }
 
+  componentWillUnmount() {
+    if (this.changes) {
+      this.changes.close();
+    }
+  }
+
+  // recordChanged applies a change someone else has made to the records being
+  // shown, records that have moved out of view or been deleted are removed.
+  // Changes made in bulk or by an import only have the id, so the record is
+  // fetched to see how it is now.
+  recordChanged = (change) => {
+    if (change.type === "record.deleted") {
+      this.placeRecord(change.id, null);
+      return
+    }
+    if (change.record) {
+      this.placeRecord(change.id, change.record);
+      return
+    }
+    GetRecord(change.id).then(response => {
+      if (!response) {
+        return
+      }
+      if (response.status === 404) {
+        this.placeRecord(change.id, null);
+        return
+      }
+      if (response.ok) {
+        response.json().then(record => this.placeRecord(change.id, record));
+      }
+    });
+  }
+
+  // placeRecord replaces the record with the id by the record, if it's still
+  // in view, or removes it when there's no record
+  placeRecord = (id, record) => {
+    let reports = this.state.reports.filter(report => report.id !== id);
+    if (record) {
+      const bounds = this.bounds;
+      const lat = record.location.lat;
+      const lng = record.location.lng;
+      if (!bounds || (lat >= bounds._southWest.lat && lat <= bounds._northEast.lat && lng >= bounds._southWest.lng && lng <= bounds._northEast.lng)) {
+        reports.push(record);
+      }
+    }
+    this.setState({ reports }, () => { this.filterChange(this.state.filterText) });
+  }
+
   filterChange = (value, event) => {
     const filteredReports = FilterData(this.state.reports, value, this.state.filterOptions, null);
 
@@ -116,6 +164,12 @@ class Home extends Component {
   }
 
   getRecords = (bounds) => {
+    this.bounds = bounds;
+    if (this.changes) {
+      this.changes.close();
+    }
+    this.changes = WatchRecords(bounds, this.recordChanged);
+
     let records = GetRecords(bounds).then(response => {
       if (!response || (response.status !== 200)) {
         if (response.message && response.message == "404") {
@@ -183,6 +237,7 @@ class Home extends Component {
             return json.ID
           }).then(id => {
             reportToSave.id = id;
+            reportToSave.version = 1;
 
             let newReports = this.state.reports;
             newReports.push(reportToSave)
@@ -196,10 +251,19 @@ class Home extends Component {
 
       } else {
         UpdateRecord(reportToSave).then((response) => {
+          if (response && response.status === 409) {
+            toast("Someone else has changed this location, showing their changes")
+            response.json().then(current => {
+              const reports = this.state.reports.map(report => report.id === current.id ? current : report);
+              this.setState({ reports, filteredReports: reports, selectedReport: current });
+            });
+            return
+          }
           if (!response || response.status !== 200) {
             toast("Failed to Save")
             return
           }
+          reportToSave.version = (reportToSave.version || 0) + 1;
           toast("Saved")
 
           this.setState({
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Keys finds the public key a JWT was signed with from the kid in its header
+type Keys interface {
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+// ErrUnknownKey is returned when there is no key with the kid of a JWT
+var ErrUnknownKey = errors.New("Token was signed with an unknown key")
+
+// KeySet is a JSON Web Key Set of RSA and EC signing keys
+type KeySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS reads a JSON Web Key Set, keys that aren't RSA or EC signing keys
+// are ignored
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("Unable to parse JWKS: %s", err.Error())
+	}
+
+	ks := &KeySet{keys: make(map[string]crypto.PublicKey)}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read key %q: %s", k.Kid, err.Error())
+		}
+		if key != nil {
+			ks.keys[k.Kid] = key
+		}
+	}
+
+	if len(ks.keys) == 0 {
+		return nil, errors.New("JWKS has no RSA or EC signing keys")
+	}
+
+	return ks, nil
+}
+
+// LoadJWKS reads a JSON Web Key Set from a file
+func LoadJWKS(path string) (*KeySet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// Key returns the key with the kid, a key without a kid is used when the set
+// only has one key
+func (ks *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	if key, ok := ks.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid base64url value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// IssuerKeys fetches the keys of an OpenID Connect issuer from the jwks_uri of
+// its discovery document. Keys are fetched again when a JWT has an unknown
+// kid, as the issuer may have rotated its keys, and once they are an hour old.
+type IssuerKeys struct {
+	Issuer string
+	Client *http.Client
+
+	mu      sync.Mutex
+	keys    *KeySet
+	fetched time.Time
+}
+
+const (
+	// keysMaxAge is how long fetched keys are used before fetching them again
+	keysMaxAge = time.Hour
+	// keysMinAge is the shortest time between fetches, so tokens with unknown
+	// kids can't be used to flood the issuer with requests
+	keysMinAge = time.Minute
+)
+
+// NewIssuerKeys returns the keys of the issuer, they are fetched when first
+// needed
+func NewIssuerKeys(issuer string) *IssuerKeys {
+	return &IssuerKeys{
+		Issuer: strings.TrimRight(issuer, "/"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Key returns the issuers key with the kid
+func (ik *IssuerKeys) Key(kid string) (crypto.PublicKey, error) {
+	ik.mu.Lock()
+	defer ik.mu.Unlock()
+
+	age := time.Since(ik.fetched)
+	if ik.keys != nil && age < keysMaxAge {
+		key, err := ik.keys.Key(kid)
+		if err == nil || age < keysMinAge {
+			return key, err
+		}
+	}
+
+	keys, err := ik.fetch()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"issuer": ik.Issuer,
+			"error":  err.Error(),
+		}).Error("Failed to fetch the issuers keys")
+		if ik.keys == nil {
+			return nil, err
+		}
+		// Keep using the keys we have rather than asking again straight away
+		ik.fetched = time.Now()
+		return ik.keys.Key(kid)
+	}
+
+	ik.keys = keys
+	ik.fetched = time.Now()
+	return ik.keys.Key(kid)
+}
+
+func (ik *IssuerKeys) fetch() (*KeySet, error) {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := ik.get(ik.Issuer+"/.well-known/openid-configuration", func(body []byte) error {
+		return json.Unmarshal(body, &discovery)
+	}); err != nil {
+		return nil, err
+	}
+
+	if discovery.JWKSURI == "" {
+		return nil, errors.New("Discovery document has no jwks_uri")
+	}
+
+	var keys *KeySet
+	err := ik.get(discovery.JWKSURI, func(body []byte) error {
+		var err error
+		keys, err = ParseJWKS(body)
+		return err
+	})
+	return keys, err
+}
+
+func (ik *IssuerKeys) get(url string, read func([]byte) error) error {
+	resp, err := ik.Client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return read(body)
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers SHA-256 for RS256 and ES256
+	_ "crypto/sha512" // registers SHA-384 and SHA-512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Verifier checks the signature and claims of bearer JWTs
+type Verifier struct {
+	Keys Keys
+
+	// Issuer and Audience, when set, must match the iss and aud claims
+	Issuer   string
+	Audience string
+
+	// Leeway allows for clock skew when checking exp and nbf
+	Leeway time.Duration
+
+	now func() time.Time
+}
+
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// Verify checks the JWT was signed by one of the keys and is valid now,
+// returning its claims
+func (v *Verifier) Verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("Token is not a JWT")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("Unable to read token header: %s", err.Error())
+	}
+
+	hash, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("Token algorithm %q is not allowed", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("Unable to read token signature")
+	}
+
+	key, err := v.Keys.Key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(header.Alg, key, h.Sum(nil), hash, signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("Unable to read token claims: %s", err.Error())
+	}
+
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, digest []byte, hash crypto.Hash, signature []byte) error {
+	invalid := errors.New("Token signature is invalid")
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") || rsa.VerifyPKCS1v15(k, hash, digest, signature) != nil {
+			return invalid
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return invalid
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return invalid
+		}
+	default:
+		return invalid
+	}
+
+	return nil
+}
+
+func (v *Verifier) checkClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	if v.now != nil {
+		now = v.now()
+	}
+
+	exp, ok := claims["exp"].(json.Number)
+	if !ok {
+		return errors.New("Token has no expiry")
+	}
+	if expiry, err := exp.Float64(); err != nil || now.Add(-v.Leeway).After(time.Unix(int64(expiry), 0)) {
+		return errors.New("Token has expired")
+	}
+
+	if nbf, ok := claims["nbf"].(json.Number); ok {
+		if notBefore, err := nbf.Float64(); err != nil || now.Add(v.Leeway).Before(time.Unix(int64(notBefore), 0)) {
+			return errors.New("Token is not valid yet")
+		}
+	}
+
+	if v.Issuer != "" && strings.TrimRight(fmt.Sprint(claims["iss"]), "/") != strings.TrimRight(v.Issuer, "/") {
+		return errors.New("Token was issued by an unknown issuer")
+	}
+
+	if v.Audience != "" && !hasAudience(claims["aud"], v.Audience) {
+		return errors.New("Token is not for this audience")
+	}
+
+	return nil
+}
+
+func hasAudience(aud interface{}, audience string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == audience
+	case []interface{}:
+		for _, value := range a {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
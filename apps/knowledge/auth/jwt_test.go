@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signRS256 creates a JWT with the claims signed by the key
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	unsigned := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func rsaJWKS(t *testing.T, kid string, key *rsa.PublicKey) []byte {
+	return []byte(fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":%q,"use":"sig","n":%q,"e":%q}]}`,
+		kid,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())))
+}
+
+func newRSAVerifier(t *testing.T) (*rsa.PrivateKey, *Verifier) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ParseJWKS(rsaJWKS(t, "one", &key.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, &Verifier{Keys: keys, Issuer: "https://id.example.com", Audience: "knowledge"}
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   "1234",
+		"email": "sam@example.com",
+		"iss":   "https://id.example.com",
+		"aud":   []string{"knowledge", "other"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerifyAcceptsTokenSignedByKey(t *testing.T) {
+	key, verifier := newRSAVerifier(t)
+
+	claims, err := verifier.Verify(signRS256(t, key, "one", validClaims()))
+	if err != nil {
+		t.Fatalf("Expected token to be valid but got %s", err.Error())
+	}
+
+	if claims["sub"] != "1234" || claims["email"] != "sam@example.com" {
+		t.Errorf("Expected the token's claims to be returned but got %v", claims)
+	}
+}
+
+func TestVerifyAcceptsES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ParseJWKS([]byte(fmt.Sprintf(`{"keys":[{"kty":"EC","crv":"P-256","x":%q,"y":%q}]}`,
+		base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.Y.Bytes()))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := &Verifier{Keys: keys}
+
+	unsigned := encodeSegment(t, map[string]string{"alg": "ES256"}) + "." + encodeSegment(t, validClaims())
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(signature[32-len(rb):], rb)
+	copy(signature[64-len(sb):], sb)
+
+	if _, err := verifier.Verify(unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)); err != nil {
+		t.Errorf("Expected ES256 token to be valid but got %s", err.Error())
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	key, verifier := newRSAVerifier(t)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	valid := signRS256(t, key, "one", validClaims())
+	parts := strings.Split(valid, ".")
+
+	tests := map[string]string{
+		"expired":          signRS256(t, key, "one", with("exp", time.Now().Add(-time.Hour).Unix())),
+		"no expiry":        signRS256(t, key, "one", with("exp", nil)),
+		"not yet valid":    signRS256(t, key, "one", with("nbf", time.Now().Add(time.Hour).Unix())),
+		"wrong issuer":     signRS256(t, key, "one", with("iss", "https://evil.example.com")),
+		"wrong audience":   signRS256(t, key, "one", with("aud", "other")),
+		"unknown key":      signRS256(t, key, "two", validClaims()),
+		"other key":        signRS256(t, other, "one", validClaims()),
+		"changed claims":   parts[0] + "." + encodeSegment(t, with("sub", "admin")) + "." + parts[2],
+		"alg none":         encodeSegment(t, map[string]string{"alg": "none"}) + "." + parts[1] + ".",
+		"alg HS256":        encodeSegment(t, map[string]string{"alg": "HS256", "kid": "one"}) + "." + parts[1] + "." + parts[2],
+		"not a JWT":        "abc",
+		"garbled segments": "a.b.c",
+	}
+
+	for name, token := range tests {
+		if _, err := verifier.Verify(token); err == nil {
+			t.Errorf("Expected %s token to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Authenticator checks requests carry a valid bearer JWT or API token in their
+// Authorization header
+type Authenticator struct {
+	// Verifier checks JWTs, when nil JWTs aren't accepted
+	Verifier *Verifier
+
+	// Tokens are the API tokens that are accepted, when nil API tokens aren't
+	// accepted
+	Tokens *Tokens
+
+	// Public reports whether a request can be made without authenticating
+	Public func(r *http.Request) bool
+
+	// QueryToken reports whether a request can send its bearer token in the
+	// access_token query param instead of the Authorization header, for
+	// clients such as EventSource that can't set headers
+	QueryToken func(r *http.Request) bool
+
+	// Roles assigns roles to principals by their name or subject
+	Roles RoleMap
+
+	// RolesClaim is the JWT claim holding the roles of the principal, when
+	// empty roles aren't read from JWTs
+	RolesClaim string
+
+	// DefaultRole is given to principals that haven't been assigned any
+	// roles, when empty they have none
+	DefaultRole string
+
+	// WorkspaceClaim is the JWT claim holding the workspace the principal is
+	// limited to, when empty principals from JWTs can use any workspace
+	WorkspaceClaim string
+}
+
+type errorResponse struct {
+	Message string
+}
+
+// Middleware rejects requests that haven't authenticated with 401, adding the
+// principal to the context of those that have
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	logger := log.WithFields(log.Fields{
+		"event": "authenticate",
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions || (a.Public != nil && a.Public(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 401,
+				"path":   r.URL.Path,
+				"error":  err.Error(),
+			}).Warn("Request not authenticated")
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="knowledge"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(&errorResponse{Message: err.Error()})
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"principal": principal.Name,
+			"method":    principal.Method,
+			"roles":     principal.Roles,
+		}).Debug("Request authenticated")
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), principal)))
+	})
+}
+
+// Disabled is used instead of Middleware when authentication is disabled, it
+// makes every request as an admin named by its X-Actor header, or anonymous
+// when it has none
+func Disabled(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.Header.Get("X-Actor")
+		if name == "" {
+			name = "anonymous"
+		}
+		principal := Principal{Subject: name, Name: name, Method: MethodNone, Roles: []string{RoleAdmin}}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), principal)))
+	})
+}
+
+// Authenticate returns the principal a request was made by from its bearer
+// JWT or API token
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" && a.QueryToken != nil && a.QueryToken(r) {
+		if token := r.URL.Query().Get("access_token"); token != "" {
+			header = "Bearer " + token
+		}
+	}
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return Principal{}, fmt.Errorf("Authentication required, send a bearer token in the Authorization header")
+	}
+	bearer := strings.TrimSpace(header[7:])
+
+	if strings.HasPrefix(bearer, TokenPrefix) {
+		if a.Tokens != nil {
+			if token, ok := a.Tokens.Lookup(bearer); ok {
+				principal := Principal{Subject: token.Name, Name: token.Name, Method: MethodToken, Workspace: token.Workspace}
+				return a.assignRoles(principal, token.Roles), nil
+			}
+		}
+		return Principal{}, fmt.Errorf("Invalid API token")
+	}
+
+	if a.Verifier == nil {
+		return Principal{}, fmt.Errorf("Only API tokens are accepted")
+	}
+
+	claims, err := a.Verifier.Verify(bearer)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return Principal{}, fmt.Errorf("Token has no subject")
+	}
+
+	principal := Principal{Subject: subject, Name: subject, Method: MethodJWT, Claims: claims}
+	for _, claim := range []string{"email", "preferred_username"} {
+		if name, ok := claims[claim].(string); ok && name != "" {
+			principal.Name = name
+			break
+		}
+	}
+
+	if a.WorkspaceClaim != "" {
+		principal.Workspace, _ = claims[a.WorkspaceClaim].(string)
+	}
+
+	var roles []string
+	if a.RolesClaim != "" {
+		roles = claimRoles(claims, a.RolesClaim)
+	}
+
+	return a.assignRoles(principal, roles), nil
+}
+
+// assignRoles gives the principal the roles from its token along with those
+// assigned to it by name or subject, or the default role when it has none
+func (a *Authenticator) assignRoles(p Principal, roles []string) Principal {
+	roles = append(roles, a.Roles[p.Name]...)
+	if p.Subject != p.Name {
+		roles = append(roles, a.Roles[p.Subject]...)
+	}
+
+	if len(roles) == 0 && a.DefaultRole != "" {
+		roles = []string{a.DefaultRole}
+	}
+
+	p.Roles = roles
+	return p
+}
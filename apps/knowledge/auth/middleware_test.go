@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newAuthenticator(t *testing.T) (*rsa.PrivateKey, string, *Authenticator) {
+	key, verifier := newRSAVerifier(t)
+
+	secret, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := NewTokens([]Token{{Name: "importer", Hash: HashToken(secret)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, secret, &Authenticator{
+		Verifier: verifier,
+		Tokens:   tokens,
+		Public: func(r *http.Request) bool {
+			return r.URL.Path == "/v1/"
+		},
+	}
+}
+
+// serve makes the request through the middleware, returning the response and
+// the principal the handler saw
+func serve(a *Authenticator, r *http.Request) (*httptest.ResponseRecorder, *Principal) {
+	var seen *Principal
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := FromContext(r.Context()); ok {
+			seen = &p
+		}
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	return rr, seen
+}
+
+func TestMiddlewareAddsPrincipalFromJWT(t *testing.T) {
+	key, _, a := newAuthenticator(t)
+
+	req := httptest.NewRequest("POST", "/v1/record", nil)
+	req.Header.Set("Authorization", "Bearer "+signRS256(t, key, "one", validClaims()))
+	rr, principal := serve(a, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected OK (200) status to be returned got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if principal == nil || principal.Subject != "1234" || principal.Name != "sam@example.com" || principal.Method != MethodJWT {
+		t.Errorf("Expected principal sam@example.com from the JWT but got %+v", principal)
+	}
+}
+
+func TestMiddlewareAddsPrincipalFromAPIToken(t *testing.T) {
+	_, secret, a := newAuthenticator(t)
+
+	req := httptest.NewRequest("POST", "/v1/record", nil)
+	req.Header.Set("Authorization", "bearer "+secret)
+	rr, principal := serve(a, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected OK (200) status to be returned got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if principal == nil || principal.Name != "importer" || principal.Method != MethodToken {
+		t.Errorf("Expected principal importer from the API token but got %+v", principal)
+	}
+}
+
+func TestMiddlewareOnlyReadsQueryTokenWhereAllowed(t *testing.T) {
+	_, secret, a := newAuthenticator(t)
+	a.QueryToken = func(r *http.Request) bool {
+		return r.URL.Path == "/v1/events"
+	}
+
+	rr, principal := serve(a, httptest.NewRequest("GET", "/v1/events?access_token="+secret, nil))
+	if rr.Code != http.StatusOK || principal == nil || principal.Name != "importer" {
+		t.Errorf("Expected the event stream to accept the query token but got %d", rr.Code)
+	}
+
+	rr, _ = serve(a, httptest.NewRequest("GET", "/v1/record?access_token="+secret, nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected Unauthorized (401) for a query token elsewhere but got %d", rr.Code)
+	}
+}
+
+func TestMiddlewareRejectsUnauthenticatedRequests(t *testing.T) {
+	_, _, a := newAuthenticator(t)
+	other, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, header := range []string{"", "Basic c2FtOnBhc3M=", "Bearer " + other, "Bearer abc.def.ghi"} {
+		req := httptest.NewRequest("GET", "/v1/record", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rr, principal := serve(a, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected Unauthorized (401) for %q but got %d", header, rr.Code)
+		}
+		if rr.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Expected WWW-Authenticate header for %q", header)
+		}
+		if principal != nil {
+			t.Errorf("Expected handler not to be called for %q", header)
+		}
+	}
+}
+
+func TestMiddlewareAllowsPublicAndPreflightRequests(t *testing.T) {
+	_, _, a := newAuthenticator(t)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/v1/", nil),
+		httptest.NewRequest("OPTIONS", "/v1/record", nil),
+	} {
+		rr, _ := serve(a, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected %s %s to be allowed without authenticating but got %d", req.Method, req.URL.Path, rr.Code)
+		}
+	}
+}
+
+func TestDisabledMakesRequestsAsAnAdminNamedByXActor(t *testing.T) {
+	var seen Principal
+	handler := Disabled(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("DELETE", "/v1/record/12345", nil)
+	req.Header.Set("X-Actor", "sam")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen.Name != "sam" || seen.Method != MethodNone || !seen.HasRole(RoleAdmin) {
+		t.Errorf("Expected sam to be an admin but got %+v", seen)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/field", nil))
+	if seen.Name != "anonymous" {
+		t.Errorf("Expected requests without X-Actor to be anonymous but got %s", seen.Name)
+	}
+}
+
+func TestTokensAreOnlyKeptAsHashes(t *testing.T) {
+	if _, err := NewTokens([]Token{{Name: "importer", Hash: "kh_plaintext"}}); err == nil {
+		t.Error("Expected a token without a SHA-256 hash to be rejected")
+	}
+
+	_, secret, a := newAuthenticator(t)
+	if _, ok := a.Tokens.Lookup(HashToken(secret)); ok {
+		t.Error("Expected the hash of a token not to be accepted as the token")
+	}
+}
@@ -0,0 +1,54 @@
+// Package auth authenticates requests using bearer JWTs issued by an OpenID
+// Connect provider or long lived API tokens, making the principal the request
+// was made by available to handlers.
+package auth
+
+import "context"
+
+// Ways a principal can authenticate
+const (
+	MethodJWT   = "jwt"
+	MethodToken = "token"
+
+	// MethodNone is used for the principals of requests made while
+	// authentication is disabled
+	MethodNone = "none"
+)
+
+// Principal is who a request was made by
+type Principal struct {
+	// Subject identifies the principal, the sub claim of a JWT or the name of
+	// an API token
+	Subject string
+
+	// Name is used to attribute changes to the principal, the email or user
+	// name from a JWT when it has one otherwise the Subject
+	Name string
+
+	// Method is how the principal authenticated, jwt, token or none
+	Method string
+
+	// Roles the principal has, viewer, editor or admin
+	Roles []string
+
+	// Workspace the principal is limited to, when empty it can use any
+	Workspace string
+
+	// Claims holds the claims of a JWT, it is empty for API tokens
+	Claims map[string]interface{}
+}
+
+type contextKey int
+
+const principalKey contextKey = 0
+
+// NewContext returns a copy of the context holding the principal
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// FromContext returns the principal held in the context, if there is one
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey).(Principal)
+	return p, ok
+}
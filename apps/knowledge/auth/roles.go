@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Roles a principal can have, each role can do everything the ones before it
+// can
+const (
+	// RoleViewer can read records and fields
+	RoleViewer = "viewer"
+
+	// RoleEditor can also create, change and restore records
+	RoleEditor = "editor"
+
+	// RoleAdmin can also delete records and change the fields
+	RoleAdmin = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// ValidRole reports whether the role is viewer, editor or admin
+func ValidRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+func checkRoles(roles []string) error {
+	for _, role := range roles {
+		if !ValidRole(role) {
+			return fmt.Errorf("Unknown role %q, must be viewer, editor or admin", role)
+		}
+	}
+	return nil
+}
+
+// HasRole reports whether the principal has the role, or one that can do
+// everything it can
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if roleRanks[r] >= roleRanks[role] && ValidRole(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleMap assigns roles to principals by their name or subject
+type RoleMap map[string][]string
+
+// LoadRoles reads a JSON object of principal names or subjects to their roles
+// from a file
+func LoadRoles(path string) (RoleMap, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles RoleMap
+	if err := json.Unmarshal(data, &roles); err != nil {
+		return nil, fmt.Errorf("Unable to parse roles: %s", err.Error())
+	}
+
+	for principal, assigned := range roles {
+		if err := checkRoles(assigned); err != nil {
+			return nil, fmt.Errorf("%s: %s", principal, err.Error())
+		}
+	}
+	return roles, nil
+}
+
+// claimRoles reads the roles in a JWT claim, either an array or a space or
+// comma separated string. Roles other than viewer, editor and admin are
+// ignored so a provider's groups claim can be used.
+func claimRoles(claims map[string]interface{}, claim string) []string {
+	var values []string
+	switch v := claims[claim].(type) {
+	case string:
+		values = strings.FieldsFunc(v, func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+	case []interface{}:
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var roles []string
+	for _, value := range values {
+		if ValidRole(value) {
+			roles = append(roles, value)
+		}
+	}
+	return roles
+}
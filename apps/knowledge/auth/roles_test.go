@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHasRoleIncludesLesserRoles(t *testing.T) {
+	tests := []struct {
+		roles    []string
+		role     string
+		expected bool
+	}{
+		{[]string{RoleAdmin}, RoleViewer, true},
+		{[]string{RoleAdmin}, RoleAdmin, true},
+		{[]string{RoleEditor}, RoleViewer, true},
+		{[]string{RoleEditor}, RoleAdmin, false},
+		{[]string{RoleViewer}, RoleEditor, false},
+		{[]string{"owner"}, RoleViewer, false},
+		{nil, RoleViewer, false},
+	}
+
+	for _, test := range tests {
+		if got := (Principal{Roles: test.roles}).HasRole(test.role); got != test.expected {
+			t.Errorf("Expected principal with %v to have %s: %v", test.roles, test.role, test.expected)
+		}
+	}
+}
+
+func TestRolesAreAssignedFromClaimsFileAndToken(t *testing.T) {
+	key, secret, a := newAuthenticator(t)
+	a.RolesClaim = "groups"
+	a.DefaultRole = RoleViewer
+	a.Roles = RoleMap{"sam@example.com": {RoleEditor}}
+
+	tokens, err := NewTokens([]Token{{Name: "importer", Hash: HashToken(secret), Roles: []string{RoleEditor}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Tokens = tokens
+
+	claims := validClaims()
+	claims["groups"] = []string{"staff", RoleAdmin}
+	other := validClaims()
+	other["email"] = "alex@example.com"
+
+	tests := map[string][]string{
+		"Bearer " + signRS256(t, key, "one", claims):        {RoleAdmin, RoleEditor},
+		"Bearer " + signRS256(t, key, "one", validClaims()): {RoleEditor},
+		"Bearer " + signRS256(t, key, "one", other):         {RoleViewer},
+		"Bearer " + secret: {RoleEditor},
+	}
+
+	for header, expected := range tests {
+		req := httptest.NewRequest("GET", "/v1/record", nil)
+		req.Header.Set("Authorization", header)
+
+		p, err := a.Authenticate(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(p.Roles, expected) {
+			t.Errorf("Expected %s to have roles %v but got %v", p.Name, expected, p.Roles)
+		}
+	}
+}
+
+func TestUnknownRolesAreRejected(t *testing.T) {
+	if _, err := NewTokens([]Token{{Name: "importer", Hash: HashToken("kh_x"), Roles: []string{"owner"}}}); err == nil {
+		t.Error("Expected a token with an unknown role to be rejected")
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// TokenPrefix starts every API token, telling them apart from JWTs
+const TokenPrefix = "kh_"
+
+// Token is a long lived API token for scripts. Only the SHA-256 hash of the
+// token is kept so the tokens file doesn't hold anything that can be used to
+// authenticate. The roles of the token are given to whoever uses it, and when
+// it has a workspace it can only be used in that workspace.
+type Token struct {
+	Name      string   `json:"name"`
+	Hash      string   `json:"hash"`
+	Roles     []string `json:"roles,omitempty"`
+	Workspace string   `json:"workspace,omitempty"`
+}
+
+// Tokens are the API tokens that can be used to authenticate
+type Tokens struct {
+	tokens []Token
+}
+
+// NewTokens returns the tokens, checking each has a name and a hash
+func NewTokens(tokens []Token) (*Tokens, error) {
+	for i, token := range tokens {
+		if token.Name == "" {
+			return nil, fmt.Errorf("Token %d has no name", i+1)
+		}
+		if hash, err := hex.DecodeString(token.Hash); err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("Token %s must have a hex SHA-256 hash", token.Name)
+		}
+		if err := checkRoles(token.Roles); err != nil {
+			return nil, fmt.Errorf("Token %s: %s", token.Name, err.Error())
+		}
+		tokens[i].Hash = strings.ToLower(token.Hash)
+	}
+	return &Tokens{tokens: tokens}, nil
+}
+
+// LoadTokens reads a JSON array of tokens from a file
+func LoadTokens(path string) (*Tokens, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("Unable to parse tokens: %s", err.Error())
+	}
+	return NewTokens(tokens)
+}
+
+// Lookup returns the token matching the secret
+func (t *Tokens) Lookup(secret string) (Token, bool) {
+	hash := []byte(HashToken(secret))
+	for _, token := range t.tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(token.Hash)) == 1 {
+			return token, true
+		}
+	}
+	return Token{}, false
+}
+
+// NewToken generates a random API token
+func NewToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashToken returns the hex SHA-256 hash of a token, as kept in the tokens
+// file
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,41 @@
+// Package blob stores the files attached to records, such as photos and
+// floor plans, behind an interface so they can be kept on disk or in Mongo.
+package blob
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned when there is no blob with the key
+var ErrNotFound = errors.New("Blob does not exist")
+
+// Store keeps blobs by key. Keys are slash separated paths such as
+// default/12345/67890.
+type Store interface {
+	// Put stores everything read from r under the key, replacing any blob
+	// already there
+	Put(key string, r io.Reader) error
+
+	// Get opens the blob with the key for reading, the caller must close it
+	Get(key string) (io.ReadCloser, error)
+
+	// Delete removes the blob with the key, it is not an error if there
+	// isn't one
+	Delete(key string) error
+}
+
+// validKey checks the key is a relative path without empty, . or .. parts so
+// it can't be used to reach outside the store
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return errors.New("Invalid blob key")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return errors.New("Invalid blob key")
+		}
+	}
+	return nil
+}
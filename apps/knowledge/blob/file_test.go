@@ -0,0 +1,53 @@
+package blob
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFileStorePutGetDelete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Put("default/12345/67890", bytes.NewBufferString("floor plan")); err != nil {
+		t.Fatalf("Expected blob to be stored but got %s", err.Error())
+	}
+
+	f, err := store.Get("default/12345/67890")
+	if err != nil {
+		t.Fatalf("Expected blob to be read but got %s", err.Error())
+	}
+	data, _ := ioutil.ReadAll(f)
+	f.Close()
+	if string(data) != "floor plan" {
+		t.Errorf("Expected the stored blob to be read back but got %q", data)
+	}
+
+	if err := store.Delete("default/12345/67890"); err != nil {
+		t.Fatalf("Expected blob to be deleted but got %s", err.Error())
+	}
+	if _, err := store.Get("default/12345/67890"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after deleting but got %v", err)
+	}
+	if err := store.Delete("default/12345/67890"); err != nil {
+		t.Errorf("Expected deleting a missing blob to succeed but got %s", err.Error())
+	}
+}
+
+func TestKeysCannotLeaveTheStore(t *testing.T) {
+	for _, key := range []string{"", "/etc/passwd", "../secret", "default/../../secret", "default//x", `default\x`} {
+		if err := validKey(key); err == nil {
+			t.Errorf("Expected key %q to be rejected", key)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package blob
+
+import (
+	"io"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// GridFSStore keeps blobs in Mongo using GridFS, named by their key
+type GridFSStore struct {
+	URL string
+
+	// Prefix names the GridFS collections, prefix.files and prefix.chunks
+	Prefix string
+}
+
+// Put stores the blob as a new file then removes any older files with the
+// same key, so readers see either the old or new blob
+func (gs *GridFSStore) Put(key string, r io.Reader) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	session, err := database.GetSession(gs.URL)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	gfs := session.DB("").GridFS(gs.Prefix)
+
+	file, err := gfs.Create(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, r)
+	if err != nil {
+		file.Abort()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	var older []struct {
+		ID interface{} `bson:"_id"`
+	}
+	err = gfs.Find(bson.M{"filename": key, "_id": bson.M{"$ne": file.Id()}}).All(&older)
+	if err != nil {
+		return err
+	}
+	for _, old := range older {
+		if err := gfs.RemoveId(old.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get opens the newest file with the key
+func (gs *GridFSStore) Get(key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	session, err := database.GetSession(gs.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := session.DB("").GridFS(gs.Prefix).Open(key)
+	if err != nil {
+		session.Close()
+		if err == mgo.ErrNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &gridFile{GridFile: file, session: session}, nil
+}
+
+// Delete removes every file with the key
+func (gs *GridFSStore) Delete(key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	session, err := database.GetSession(gs.URL)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return session.DB("").GridFS(gs.Prefix).Remove(key)
+}
+
+// gridFile closes the session a file was opened with when it's closed
+type gridFile struct {
+	*mgo.GridFile
+	session *mgo.Session
+}
+
+func (gf *gridFile) Close() error {
+	err := gf.GridFile.Close()
+	gf.session.Close()
+	return err
+}
@@ -0,0 +1,55 @@
+package blob
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestGridFSStoreRejectsKeysBeforeConnecting(t *testing.T) {
+	store := &GridFSStore{URL: "mongodb://unreachable.invalid/test", Prefix: "attachments"}
+
+	if err := store.Put("../secret", bytes.NewBufferString("floor plan")); err == nil {
+		t.Error("Expected Put to reject the key")
+	}
+	if _, err := store.Get("default//x"); err == nil {
+		t.Error("Expected Get to reject the key")
+	}
+	if err := store.Delete(""); err == nil {
+		t.Error("Expected Delete to reject the key")
+	}
+}
+
+// TestGridFSStorePutGetDelete needs a Mongo it can write to, set
+// MONGODB_TEST_URI to run it
+func TestGridFSStorePutGetDelete(t *testing.T) {
+	url := os.Getenv("MONGODB_TEST_URI")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	store := &GridFSStore{URL: url, Prefix: "test_attachments"}
+
+	for _, content := range []string{"floor plan", "new floor plan"} {
+		if err := store.Put("default/12345/67890", bytes.NewBufferString(content)); err != nil {
+			t.Fatalf("Expected blob to be stored but got %s", err.Error())
+		}
+	}
+
+	f, err := store.Get("default/12345/67890")
+	if err != nil {
+		t.Fatalf("Expected blob to be read but got %s", err.Error())
+	}
+	data, _ := ioutil.ReadAll(f)
+	f.Close()
+	if string(data) != "new floor plan" {
+		t.Errorf("Expected the newest blob to be read back but got %q", data)
+	}
+
+	if err := store.Delete("default/12345/67890"); err != nil {
+		t.Fatalf("Expected blob to be deleted but got %s", err.Error())
+	}
+	if _, err := store.Get("default/12345/67890"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after deleting but got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+// Command geocode fills in the country code and region of records written
+// before they were geocoded, or of every record with -all, such as after
+// changing the boundaries. It reads $MONGODB_URI, $GEOCODER and
+// $GEOCODER_BOUNDARIES the same as the server.
+//
+// Usage: geocode [-workspace name] [-all]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/geo"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+func main() {
+	workspace := flag.String("workspace", "", "only geocode the records of the workspace, defaults to every workspace")
+	all := flag.Bool("all", false, "geocode every record, not just those without a country code")
+	flag.Parse()
+
+	dbURL := os.Getenv("MONGODB_URI")
+	if dbURL == "" {
+		fmt.Fprintln(os.Stderr, "$MONGODB_URI must be set")
+		os.Exit(2)
+	}
+
+	geocoder, err := geo.NewGeocoder(os.Getenv("GEOCODER"), os.Getenv("GEOCODER_BOUNDARIES"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to set up $GEOCODER: %s\n", err.Error())
+		os.Exit(2)
+	}
+	if geocoder == nil {
+		fmt.Fprintln(os.Stderr, "Geocoding is disabled, set $GEOCODER_BOUNDARIES or $GEOCODER=offline")
+		os.Exit(2)
+	}
+
+	db := &database.MongoDB{
+		URL:                 dbURL,
+		Collection:          "records",
+		WorkspaceCollection: "workspaces",
+		Geocoder:            geocoder,
+	}
+
+	workspaces := []types.Workspace{{Name: *workspace}}
+	if *workspace == "" {
+		if workspaces, err = db.Workspaces(); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to list workspaces: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	failed := false
+	for _, ws := range workspaces {
+		updated, err := db.Scoped(ws.Name).Geocode(*all)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: geocoded %d records before failing: %s\n", ws.Name, updated, err.Error())
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: geocoded %d records\n", ws.Name, updated)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,56 @@
+// Command reindex fills in the detail values of records written before their
+// details were included in the text index, so that searches find them by
+// their details. It reads $MONGODB_URI the same as the server.
+//
+// Usage: reindex [-workspace name]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+func main() {
+	workspace := flag.String("workspace", "", "only reindex the records of the workspace, defaults to every workspace")
+	flag.Parse()
+
+	dbURL := os.Getenv("MONGODB_URI")
+	if dbURL == "" {
+		fmt.Fprintln(os.Stderr, "$MONGODB_URI must be set")
+		os.Exit(2)
+	}
+
+	db := &database.MongoDB{
+		URL:                 dbURL,
+		Collection:          "records",
+		WorkspaceCollection: "workspaces",
+	}
+
+	workspaces := []types.Workspace{{Name: *workspace}}
+	if *workspace == "" {
+		var err error
+		if workspaces, err = db.Workspaces(); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to list workspaces: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	failed := false
+	for _, ws := range workspaces {
+		updated, err := db.Scoped(ws.Name).IndexDetails()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: reindexed %d records before failing: %s\n", ws.Name, updated, err.Error())
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: reindexed %d records\n", ws.Name, updated)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
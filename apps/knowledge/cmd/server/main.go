@@ -3,25 +3,228 @@ package main
 import (
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/blob"
 	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/events"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/geo"
 	"github.com/cstdev/knowledge-hub/apps/knowledge/knowledge"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/webhook"
 	"github.com/rs/cors"
 	log "github.com/sirupsen/logrus"
 )
 
-func setupGlobalMiddleware(handler http.Handler) http.Handler {
+func setupGlobalMiddleware(handler http.Handler, origins []string) http.Handler {
 	handleCORS := cors.New(cors.Options{
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedOrigins: origins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Origin", "Accept", "Content-Type", "X-Requested-With", "X-Actor", "If-Match", "Authorization"},
+		ExposedHeaders: []string{"X-Total-Count", "X-Page", "X-Page-Size", "ETag", "Content-Disposition", "WWW-Authenticate"},
 	}).Handler
 	return handleCORS(handler)
 }
 
+// allowedOrigins reads the comma separated origins allowed to make cross
+// origin requests, all origins are allowed when none are set
+func allowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// setupAuth creates the authenticator from the environment, JWTs are verified
+// against the keys in $JWKS_FILE or published by $OIDC_ISSUER and API tokens
+// are read from $API_TOKENS_FILE. Roles are assigned by $ROLES_FILE or read
+// from the $ROLES_CLAIM of JWTs. Nil is returned when auth is disabled by
+// $AUTH_DISABLED=true, it won't start without one of them otherwise so it's
+// never left open by mistake.
+func setupAuth() *auth.Authenticator {
+	jwksFile := os.Getenv("JWKS_FILE")
+	issuer := os.Getenv("OIDC_ISSUER")
+	tokensFile := os.Getenv("API_TOKENS_FILE")
+	disabled := os.Getenv("AUTH_DISABLED") == "true"
+
+	if jwksFile == "" && issuer == "" && tokensFile == "" {
+		if !disabled {
+			log.Fatal("One of $JWKS_FILE, $OIDC_ISSUER or $API_TOKENS_FILE must be set, or $AUTH_DISABLED=true to allow every request as an admin")
+		}
+		log.Warn("Authentication is disabled, every request is allowed as an admin")
+		return nil
+	}
+	if disabled {
+		log.Fatal("$AUTH_DISABLED=true can't be set along with $JWKS_FILE, $OIDC_ISSUER or $API_TOKENS_FILE")
+	}
+
+	authenticator := &auth.Authenticator{
+		Public:         knowledge.Public,
+		QueryToken:     knowledge.QueryToken,
+		RolesClaim:     os.Getenv("ROLES_CLAIM"),
+		WorkspaceClaim: os.Getenv("WORKSPACE_CLAIM"),
+		DefaultRole:    auth.RoleViewer,
+	}
+
+	if role := os.Getenv("DEFAULT_ROLE"); role == "none" {
+		authenticator.DefaultRole = ""
+	} else if role != "" {
+		if !auth.ValidRole(role) {
+			log.Fatal("$DEFAULT_ROLE must be viewer, editor, admin or none")
+		}
+		authenticator.DefaultRole = role
+	}
+
+	if rolesFile := os.Getenv("ROLES_FILE"); rolesFile != "" {
+		roles, err := auth.LoadRoles(rolesFile)
+		if err != nil {
+			log.WithField("error", err.Error()).Fatal("Unable to load $ROLES_FILE")
+		}
+		authenticator.Roles = roles
+	}
+
+	if jwksFile != "" || issuer != "" {
+		verifier := &auth.Verifier{
+			Issuer:   issuer,
+			Audience: os.Getenv("OIDC_AUDIENCE"),
+			Leeway:   time.Minute,
+		}
+
+		if jwksFile != "" {
+			keys, err := auth.LoadJWKS(jwksFile)
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Unable to load $JWKS_FILE")
+			}
+			verifier.Keys = keys
+		} else {
+			verifier.Keys = auth.NewIssuerKeys(issuer)
+		}
+		authenticator.Verifier = verifier
+	}
+
+	if tokensFile != "" {
+		tokens, err := auth.LoadTokens(tokensFile)
+		if err != nil {
+			log.WithField("error", err.Error()).Fatal("Unable to load $API_TOKENS_FILE")
+		}
+		authenticator.Tokens = tokens
+	}
+
+	log.WithFields(log.Fields{
+		"jwks":   jwksFile,
+		"issuer": issuer,
+		"tokens": tokensFile,
+	}).Info("Authentication enabled")
+	return authenticator
+}
+
+// purgeDeleted permanently removes records and fields that have been deleted
+// for longer than the retention period from every workspace, along with the
+// files attached to the records, checking once a day
+func purgeDeleted(db *database.MongoDB, blobs blob.Store, retention time.Duration) {
+	for {
+		workspaces, err := db.Workspaces()
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Unable to list workspaces to purge")
+		}
+
+		for _, workspace := range workspaces {
+			records, fields, attachments, err := db.Scoped(workspace.Name).Purge(time.Now().Add(-retention))
+			// The records of the attachments are gone even if purging failed
+			// after removing them, so their files are deleted regardless
+			for _, key := range attachments {
+				if err := blobs.Delete(key); err != nil {
+					log.WithFields(log.Fields{
+						"workspace": workspace.Name,
+						"key":       key,
+						"error":     err.Error(),
+					}).Error("Unable to delete attachment of purged record")
+				}
+			}
+			if err != nil {
+				log.WithFields(log.Fields{
+					"workspace": workspace.Name,
+					"error":     err.Error(),
+				}).Error("Unable to purge deleted items")
+				continue
+			}
+			log.WithFields(log.Fields{
+				"workspace": workspace.Name,
+				"records":   records,
+				"fields":    fields,
+			}).Info("Purged deleted items")
+		}
+		time.Sleep(24 * time.Hour)
+	}
+}
+
+// setupGeocoder creates the geocoder set by $GEOCODER, finding places offline
+// from the boundaries in $GEOCODER_BOUNDARIES when it's set
+func setupGeocoder() geo.Geocoder {
+	geocoder, err := geo.NewGeocoder(os.Getenv("GEOCODER"), os.Getenv("GEOCODER_BOUNDARIES"))
+	if err != nil {
+		log.WithField("error", err.Error()).Fatal("Unable to set up $GEOCODER")
+	}
+	if geocoder == nil {
+		log.Warn("Reverse geocoding is disabled, records won't be given country codes or regions until $GEOCODER_BOUNDARIES is set")
+	}
+	return geocoder
+}
+
+// setupForwardGeocoder creates the geocoder looking up addresses set by
+// $GEOCODER, by default finding them offline in the gazetteer in
+// $GAZETTEER_FILE
+func setupForwardGeocoder() geo.ForwardGeocoder {
+	gazetteer := os.Getenv("GAZETTEER_FILE")
+	if gazetteer == "" {
+		gazetteer = geo.DefaultGazetteer
+	}
+
+	geocoder, err := geo.NewForwardGeocoder(os.Getenv("GEOCODER"), gazetteer)
+	if err != nil {
+		log.WithField("error", err.Error()).Fatal("Unable to set up $GAZETTEER_FILE")
+	}
+	return geocoder
+}
+
+// setupBlobs creates the store for attachments set by $ATTACHMENT_STORE,
+// either files in $ATTACHMENT_DIR or GridFS in the Mongo database
+func setupBlobs(dbURL string) blob.Store {
+	switch store := os.Getenv("ATTACHMENT_STORE"); store {
+	case "", "file":
+		dir := os.Getenv("ATTACHMENT_DIR")
+		if dir == "" {
+			dir = "attachments"
+		}
+		files, err := blob.NewFileStore(dir)
+		if err != nil {
+			log.WithField("error", err.Error()).Fatal("Unable to create $ATTACHMENT_DIR")
+		}
+		return files
+	case "gridfs":
+		return &blob.GridFSStore{URL: dbURL, Prefix: "attachments"}
+	default:
+		log.Fatal("$ATTACHMENT_STORE must be file or gridfs")
+		return nil
+	}
+}
+
 func main() {
 	//dbURL := "172.17.0.2"
 	dbName := "knowledge-hub"
 	dbCollection := "records"
 	fieldCollection := "fields"
+	historyCollection := "history"
+	noteCollection := "notes"
+	webhookCollection := "webhooks"
+	deliveryCollection := "deliveries"
+	workspaceCollection := "workspaces"
 
 	switch logLevel := os.Getenv("LOG_LEVEL"); logLevel {
 	case "debug":
@@ -41,13 +244,50 @@ func main() {
 	}
 
 	db := &database.MongoDB{
-		URL:             dbURL,
-		Database:        dbName,
-		Collection:      dbCollection,
-		FieldCollection: fieldCollection,
+		URL:                 dbURL,
+		Database:            dbName,
+		Collection:          dbCollection,
+		FieldCollection:     fieldCollection,
+		HistoryCollection:   historyCollection,
+		NoteCollection:      noteCollection,
+		WebhookCollection:   webhookCollection,
+		DeliveryCollection:  deliveryCollection,
+		WorkspaceCollection: workspaceCollection,
+		Geocoder:            setupGeocoder(),
+	}
+
+	workspaces, err := db.Workspaces()
+	if err != nil {
+		log.WithField("error", err.Error()).Error("Unable to list workspaces")
+		workspaces = []types.Workspace{{Name: types.DefaultWorkspace}}
+	}
+	for _, workspace := range workspaces {
+		if err := db.Scoped(workspace.Name).EnsureIndexes(); err != nil {
+			log.WithFields(log.Fields{
+				"workspace": workspace.Name,
+				"error":     err.Error(),
+			}).Error("Unable to create database indexes")
+		}
 	}
 
-	var service = &knowledge.WebService{DB: db}
+	blobs := setupBlobs(dbURL)
+
+	if days := os.Getenv("PURGE_AFTER_DAYS"); days != "" {
+		retention, err := strconv.Atoi(days)
+		if err != nil || retention < 1 {
+			log.Fatal("$PURGE_AFTER_DAYS must be a number of days greater than 0")
+		}
+		go purgeDeleted(db, blobs, time.Duration(retention)*24*time.Hour)
+	}
+
+	broker := events.NewBroker()
+	var service = &knowledge.WebService{
+		DB:       events.Publishing(db, broker),
+		Blobs:    blobs,
+		Broker:   broker,
+		Geocoder: setupForwardGeocoder(),
+	}
+	go webhook.NewDispatcher(db).Run(broker, nil)
 
 	port := os.Getenv("PORT")
 
@@ -56,6 +296,12 @@ func main() {
 	}
 
 	router := knowledge.NewRouter(service)
+	if authenticator := setupAuth(); authenticator != nil {
+		router.Use(authenticator.Middleware)
+	} else {
+		router.Use(auth.Disabled)
+	}
+
 	log.WithField("port", port).Info("Starting server")
-	log.Fatal(http.ListenAndServe(":"+port, setupGlobalMiddleware(router)))
+	log.Fatal(http.ListenAndServe(":"+port, setupGlobalMiddleware(router, allowedOrigins())))
 }
@@ -0,0 +1,38 @@
+// Command token generates an API token, printing the token to give to the
+// script using it and the entry to add to the $API_TOKENS_FILE of the server.
+//
+// Usage: token <name> [role...]
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "" {
+		fmt.Fprintln(os.Stderr, "Usage: token <name> [role...]")
+		os.Exit(2)
+	}
+
+	roles := os.Args[2:]
+	for _, role := range roles {
+		if !auth.ValidRole(role) {
+			fmt.Fprintf(os.Stderr, "Unknown role %q, must be viewer, editor or admin\n", role)
+			os.Exit(2)
+		}
+	}
+
+	token, err := auth.NewToken()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to generate token: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	entry, _ := json.Marshal(auth.Token{Name: os.Args[1], Hash: auth.HashToken(token), Roles: roles})
+	fmt.Printf("Token: %s\n", token)
+	fmt.Printf("Add to $API_TOKENS_FILE: %s\n", entry)
+}
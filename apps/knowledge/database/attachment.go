@@ -0,0 +1,103 @@
+package database
+
+import (
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// AddAttachment adds the metadata of a file to the attachments of a record,
+// the file must already have been stored. The attachment is returned with
+// when and by who it was added filled in.
+func (db *MongoDB) AddAttachment(id string, a types.Attachment) (types.Attachment, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return a, err
+	}
+	defer session.Close()
+
+	current, err := db.Get(id)
+	if err != nil {
+		return a, err
+	}
+
+	a.Created = time.Now().UTC()
+	a.CreatedBy = db.Actor
+
+	c := session.DB("").C(db.Collection)
+
+	err = c.Update(bson.M{"id": id, "deleted": bson.M{"$ne": true}}, bson.M{
+		"$push": bson.M{"attachments": a},
+		"$inc":  bson.M{"version": 1},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return a, &types.RecordNotFoundError{ID: id, Message: "Record does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    id,
+			"error": err.Error(),
+		}).Error("Failed to add attachment in database.")
+		return a, err
+	}
+
+	updated := current
+	updated.Version++
+	updated.Attachments = append(append([]types.Attachment(nil), current.Attachments...), a)
+	db.addRevision(session, types.ActionUpdated, current, updated)
+
+	return a, nil
+}
+
+// DeleteAttachment removes an attachment from a record, returning its
+// metadata so the file can be removed
+func (db *MongoDB) DeleteAttachment(id, attachmentID string) (types.Attachment, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return types.Attachment{}, err
+	}
+	defer session.Close()
+
+	current, err := db.Get(id)
+	if err != nil {
+		return types.Attachment{}, err
+	}
+
+	attachment, ok := current.Attachment(attachmentID)
+	if !ok {
+		return attachment, &types.AttachmentNotFoundError{ID: attachmentID, RecordID: id, Message: "Attachment does not exist."}
+	}
+
+	c := session.DB("").C(db.Collection)
+
+	err = c.Update(bson.M{"id": id, "attachments.id": attachmentID}, bson.M{
+		"$pull": bson.M{"attachments": bson.M{"id": attachmentID}},
+		"$inc":  bson.M{"version": 1},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return attachment, &types.AttachmentNotFoundError{ID: attachmentID, RecordID: id, Message: "Attachment does not exist."}
+		}
+		log.WithFields(log.Fields{
+			"id":         id,
+			"attachment": attachmentID,
+			"error":      err.Error(),
+		}).Error("Failed to remove attachment from database.")
+		return attachment, err
+	}
+
+	updated := current
+	updated.Version++
+	updated.Attachments = nil
+	for _, a := range current.Attachments {
+		if a.ID != attachmentID {
+			updated.Attachments = append(updated.Attachments, a)
+		}
+	}
+	db.addRevision(session, types.ActionUpdated, current, updated)
+
+	return attachment, nil
+}
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxBulkRecords is the most records a bulk operation can change at once
+const MaxBulkRecords = 1000
+
+// Bulk makes the operation to every record with one of the IDs, or matching
+// the query when there are no IDs, in a single bulk write. The result for
+// each record is returned, in the order of the IDs when they're given.
+func (db *MongoDB) Bulk(op types.BulkOperation) ([]types.BulkResult, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	if op.Operation == types.BulkSetDetail {
+		op.Key, op.Value, err = db.bulkDetail(op.Key, op.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c := session.DB("").C(db.Collection)
+
+	filter := bson.M{"id": bson.M{"$in": op.IDs}, "deleted": bson.M{"$ne": true}}
+	if len(op.IDs) == 0 {
+		if op.Query == nil {
+			return nil, nil
+		}
+		filter = searchFilter(*op.Query)
+	}
+
+	matched, err := c.Find(filter).Count()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to count records for bulk operation.")
+		return nil, err
+	}
+	if matched > MaxBulkRecords {
+		return nil, &types.BulkLimitError{Matched: matched, Limit: MaxBulkRecords}
+	}
+
+	var records []types.Record
+	if err := c.Find(filter).Sort("_id").All(&records); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get records for bulk operation.")
+		return nil, err
+	}
+
+	found := make(map[string]types.Record, len(records))
+	order := make([]string, 0, len(records))
+	for _, r := range records {
+		found[r.ID] = r
+		order = append(order, r.ID)
+	}
+	if len(op.IDs) > 0 {
+		order = op.IDs
+	}
+
+	results := make([]types.BulkResult, len(order))
+	bulk := c.Bulk()
+	bulk.Unordered()
+
+	type change struct {
+		result        int
+		before, after types.Record
+	}
+	var changes []change
+
+	for i, id := range order {
+		results[i].ID = id
+
+		before, ok := found[id]
+		if !ok {
+			results[i].Status = types.BulkNotFound
+			continue
+		}
+
+		after, update := bulkChange(op, before)
+		if update == nil {
+			results[i].Status = types.BulkUnchanged
+			continue
+		}
+
+		bulk.Update(bson.M{"id": id, "deleted": bson.M{"$ne": true}}, update)
+		changes = append(changes, change{result: i, before: before, after: after})
+		results[i].Status = types.BulkUpdated
+		if op.Operation == types.BulkDelete {
+			results[i].Status = types.BulkDeleted
+		}
+	}
+
+	if len(changes) == 0 {
+		return results, nil
+	}
+
+	failed := make(map[int]bool)
+	if _, err := bulk.Run(); err != nil {
+		bulkErr, ok := err.(*mgo.BulkError)
+		if !ok {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Failed to run bulk operation.")
+			return nil, err
+		}
+		for _, failure := range bulkErr.Cases() {
+			if failure.Index < 0 || failure.Index >= len(changes) {
+				continue
+			}
+			failed[failure.Index] = true
+			results[changes[failure.Index].result].Status = types.BulkFailed
+			results[changes[failure.Index].result].Message = failure.Err.Error()
+		}
+	}
+
+	action := types.ActionUpdated
+	if op.Operation == types.BulkDelete {
+		action = types.ActionDeleted
+	}
+	for i, c := range changes {
+		if !failed[i] {
+			db.addRevision(session, action, c.before, c.after)
+		}
+	}
+
+	return results, nil
+}
+
+// bulkDetail resolves the key of the detail being set, which can be a fields
+// key or id, and checks the value against the field. An empty value removes
+// the detail.
+func (db *MongoDB) bulkDetail(key string, value interface{}) (string, interface{}, error) {
+	fields, err := db.Fields()
+	if err != nil {
+		return "", nil, err
+	}
+
+	field := types.Field{Value: types.DescriptionKey}
+	if key != types.DescriptionKey {
+		found := false
+		for _, f := range fields {
+			if f.Key() == key || f.ID == key {
+				field, found = f, true
+				break
+			}
+		}
+		if !found {
+			return "", nil, &types.ValidationError{
+				Message: "Record details do not match the fields",
+				Fields:  []types.FieldError{{Field: key, Message: "is not a field"}},
+			}
+		}
+	}
+
+	details := map[string]interface{}{field.Key(): value}
+	if err := types.ValidateDetails([]types.Field{field}, details); err != nil {
+		return "", nil, err
+	}
+
+	if s, ok := details[field.Key()].(string); ok && s == "" {
+		return field.Key(), nil, nil
+	}
+	return field.Key(), details[field.Key()], nil
+}
+
+// bulkChange makes the operation to a copy of the record, returning the
+// changed record and the update that writes it, or a nil update if the
+// operation doesn't change the record
+func bulkChange(op types.BulkOperation, r types.Record) (types.Record, bson.M) {
+	after := r
+	after.Version = r.Version + 1
+	inc := bson.M{"version": 1}
+
+	switch op.Operation {
+	case types.BulkSetDetail:
+		current, present := r.Details[op.Key]
+		if (op.Value == nil && !present) || (present && reflect.DeepEqual(current, op.Value)) {
+			return r, nil
+		}
+
+		after.Details = make(map[string]interface{}, len(r.Details)+1)
+		for key, value := range r.Details {
+			after.Details[key] = value
+		}
+		update := bson.M{"$inc": inc}
+		if op.Value == nil {
+			delete(after.Details, op.Key)
+			update["$unset"] = bson.M{"details." + op.Key: ""}
+		} else {
+			after.Details[op.Key] = op.Value
+		}
+		after.DetailValues = detailValues(after.Details)
+		set := bson.M{"detailvalues": after.DetailValues}
+		if op.Value != nil {
+			set["details."+op.Key] = op.Value
+		}
+		update["$set"] = set
+		return after, update
+
+	case types.BulkAddFacility:
+		for _, facility := range r.Facilities {
+			if facility == op.Facility {
+				return r, nil
+			}
+		}
+		after.Facilities = append(append([]string(nil), r.Facilities...), op.Facility)
+		return after, bson.M{"$addToSet": bson.M{"facilities": op.Facility}, "$inc": inc}
+
+	case types.BulkRemoveFacility:
+		after.Facilities = nil
+		for _, facility := range r.Facilities {
+			if facility != op.Facility {
+				after.Facilities = append(after.Facilities, facility)
+			}
+		}
+		if len(after.Facilities) == len(r.Facilities) {
+			return r, nil
+		}
+		return after, bson.M{"$pull": bson.M{"facilities": op.Facility}, "$inc": inc}
+
+	case types.BulkDelete:
+		now := time.Now().UTC()
+		after.DeletedAt = &now
+		return after, bson.M{"$set": bson.M{"deleted": true, "deletedat": now}, "$inc": inc}
+	}
+
+	return r, nil
+}
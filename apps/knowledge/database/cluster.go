@@ -0,0 +1,80 @@
+package database
+
+import (
+	"math"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// cellSize is the width and height in degrees of the grid cells records are
+// clustered into at the zoom level, a quarter of a 256 pixel web map tile so
+// clusters are around 64 pixels apart
+func cellSize(zoom int) float64 {
+	return 90 / math.Pow(2, float64(zoom))
+}
+
+// clusterPipeline builds the aggregation that groups the records matching
+// the query into the cells of the grid for the zoom level, largest first
+func clusterPipeline(query types.SearchQuery, zoom int) []bson.M {
+	size := cellSize(zoom)
+	cell := func(field string, offset float64) bson.M {
+		return bson.M{"$floor": bson.M{"$divide": []interface{}{
+			bson.M{"$add": []interface{}{field, offset}},
+			size,
+		}}}
+	}
+
+	return []bson.M{
+		{"$match": searchFilter(query)},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"x": cell("$location.lng", 180),
+				"y": cell("$location.lat", 90),
+			},
+			"count":  bson.M{"$sum": 1},
+			"lat":    bson.M{"$avg": "$location.lat"},
+			"lng":    bson.M{"$avg": "$location.lng"},
+			"minlat": bson.M{"$min": "$location.lat"},
+			"maxlat": bson.M{"$max": "$location.lat"},
+			"minlng": bson.M{"$min": "$location.lng"},
+			"maxlng": bson.M{"$max": "$location.lng"},
+			"id":     bson.M{"$first": "$id"},
+		}},
+		{"$sort": bson.D{{Name: "count", Value: -1}, {Name: "_id.y", Value: 1}, {Name: "_id.x", Value: 1}}},
+		{"$limit": types.MaxClusters},
+	}
+}
+
+// Clusters groups the records matching the query into a grid sized for the
+// zoom level, returning the count, centroid and bounds of the records in
+// each cell that has any. Like Search it needs an area to cluster.
+func (db *MongoDB) Clusters(query types.SearchQuery, zoom int) ([]types.Cluster, error) {
+	clusters := []types.Cluster{}
+	if !boundsPresent(query) && !radiusPresent(query) && !polygonsPresent(query) {
+		return clusters, nil
+	}
+
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.Collection)
+	if err := c.Pipe(clusterPipeline(query, zoom)).All(&clusters); err != nil {
+		log.WithFields(log.Fields{
+			"zoom":  zoom,
+			"error": err.Error(),
+		}).Error("Failed to cluster records in the database.")
+		return nil, err
+	}
+
+	for i := range clusters {
+		if clusters[i].Count > 1 {
+			clusters[i].ID = ""
+		}
+	}
+	return clusters, nil
+}
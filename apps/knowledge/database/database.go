@@ -2,7 +2,13 @@ package database
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/cstdev/knowledge-hub/apps/knowledge/geo"
 	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
@@ -13,20 +19,83 @@ import (
 // connector.
 type Database interface {
 	Create(r types.Record) (string, error)
-	Search(query types.SearchQuery) ([]types.Record, error)
+	Search(query types.SearchQuery) ([]types.Record, int, error)
+	Export(query types.SearchQuery, each func(types.Record) error) error
+	Clusters(query types.SearchQuery, zoom int) ([]types.Cluster, error)
+	Get(id string) (types.Record, error)
 	Update(id string, r types.Record) error
+	Patch(id string, p types.Patch) (types.Record, error)
 	Delete(id string) error
+	Import(records []types.Record, dryRun bool) ([]types.ImportRow, error)
+	Bulk(op types.BulkOperation) ([]types.BulkResult, error)
+	History(id string) ([]types.Revision, error)
+	Revision(id string, rev int) (types.Revision, error)
+	Deleted(page, pageSize int) ([]types.Record, int, error)
+	Restore(id string) error
+	AddAttachment(id string, a types.Attachment) (types.Attachment, error)
+	DeleteAttachment(id, attachmentID string) (types.Attachment, error)
+	Notes(recordID string) ([]types.Note, error)
+	GetNote(recordID, noteID string) (types.Note, error)
+	AddNote(recordID string, n types.Note) (types.Note, error)
+	UpdateNote(recordID string, n types.Note) (types.Note, error)
+	DeleteNote(recordID, noteID string) error
 	Fields() ([]types.Field, error)
 	UpdateFields(fields []types.Field) error
 	DeleteField(id string) error
+	DeletedFields() ([]types.Field, error)
+	RestoreField(id string) error
+	As(actor string) Database
+	In(workspace string) Database
+	Workspaces() ([]types.Workspace, error)
+	GetWorkspace(name string) (types.Workspace, error)
+	CreateWorkspace(w types.Workspace) (types.Workspace, error)
+	Webhooks() ([]types.Webhook, error)
+	GetWebhook(id string) (types.Webhook, error)
+	CreateWebhook(h types.Webhook) (types.Webhook, error)
+	UpdateWebhook(h types.Webhook) (types.Webhook, error)
+	DeleteWebhook(id string) error
+	Deliveries(webhookID, status string, page, pageSize int) ([]types.Delivery, int, error)
+	AddDelivery(d types.Delivery) (types.Delivery, error)
+	ClaimDelivery(lease time.Duration) (types.Delivery, error)
+	UpdateDelivery(d types.Delivery) error
+	RetryDelivery(webhookID, id string) (types.Delivery, error)
 }
 
 // MongoDB provides access and methods to talk to Mongo
 type MongoDB struct {
-	URL             string
-	Database        string
-	Collection      string
-	FieldCollection string
+	URL               string
+	Database          string
+	Collection        string
+	FieldCollection   string
+	HistoryCollection string
+	NoteCollection    string
+
+	// WebhookCollection and DeliveryCollection hold the webhooks and the
+	// deliveries made to them
+	WebhookCollection  string
+	DeliveryCollection string
+
+	// WorkspaceCollection lists the workspaces, it is shared by all of them
+	WorkspaceCollection string
+
+	// Workspace is the workspace whose records and fields are used, see In
+	Workspace string
+
+	// Actor is who is making changes, it is recorded in the history of any
+	// records that are written
+	Actor string
+
+	// Geocoder finds the country code and region of records as they're
+	// written, they aren't geocoded when it's nil
+	Geocoder geo.Geocoder
+}
+
+// As returns a copy of the database that records changes as being made by
+// the actor
+func (db *MongoDB) As(actor string) Database {
+	scoped := *db
+	scoped.Actor = actor
+	return &scoped
 }
 
 // Create takes a record and writes it to the Mongo database
@@ -37,19 +106,16 @@ func (db *MongoDB) Create(r types.Record) (string, error) {
 		return "", err
 	}
 
-	if len(r.Location.Coordinates) == 0 {
-		r.Location.Coordinates = []float64{r.Location.Lng, r.Location.Lat}
-		r.Location.Type = "Point"
-		log.WithFields(log.Fields{
-			"coordinates": r.Location.Coordinates,
-		}).Debug("Added coordinates for easy searching")
-	}
-
 	//Use DB from URL
 	c := session.DB("").C(db.Collection)
 
+	if err := db.validateDetails(r); err != nil {
+		return "", err
+	}
+
 	id := bson.NewObjectId()
-	r.ID = id.Hex()
+	r = newRecord(id, r)
+	db.locate(&r)
 	_, err = c.UpsertId(id, r)
 
 	if err != nil {
@@ -63,27 +129,191 @@ func (db *MongoDB) Create(r types.Record) (string, error) {
 		"_id": r.ID,
 	}).Debug("Inserted Id")
 
+	db.addRevision(session, types.ActionCreated, types.Record{}, r)
+
 	return r.ID, nil
 }
 
+// newRecord prepares a record to be inserted with the id, filling in the
+// values the database maintains
+func newRecord(id bson.ObjectId, r types.Record) types.Record {
+	if len(r.Location.Coordinates) == 0 {
+		r.Location.Coordinates = []float64{r.Location.Lng, r.Location.Lat}
+		r.Location.Type = "Point"
+		log.WithFields(log.Fields{
+			"coordinates": r.Location.Coordinates,
+		}).Debug("Added coordinates for easy searching")
+	}
+
+	r.ID = id.Hex()
+	r.Version = 1
+	r.Attachments = nil
+	r.DetailValues = detailValues(r.Details)
+	r.DeletedAt = nil
+	return r
+}
+
 func boundsPresent(query types.SearchQuery) bool {
 	log.Debug("Checking bounds exist")
 	return query.MinLat != 0 && query.MaxLat != 0 && query.MinLng != 0 && query.MaxLng != 0
 }
 
-// Search takes a query and returns all records that match
-func (db *MongoDB) Search(query types.SearchQuery) ([]types.Record, error) {
+func radiusPresent(query types.SearchQuery) bool {
+	return query.Radius > 0
+}
+
+func polygonsPresent(query types.SearchQuery) bool {
+	return len(query.Polygons) > 0
+}
+
+// filterClauses returns the non geographic clauses of a search, only
+// returning records that haven't been marked as deleted
+func filterClauses(query types.SearchQuery) []interface{} {
+	clauses := []interface{}{
+		bson.M{"deleted": bson.M{"$ne": true}},
+	}
+
+	if query.Query != "" {
+		clauses = append(clauses, bson.M{"$text": bson.M{"$search": query.Query}})
+	}
+
+	if len(query.Facilities) > 0 {
+		operator := "$in"
+		if query.FacilityMatch == "all" {
+			operator = "$all"
+		}
+		clauses = append(clauses, bson.M{"facilities": bson.M{operator: query.Facilities}})
+	}
+
+	if len(query.CountryCodes) > 0 {
+		codes := make([]string, len(query.CountryCodes))
+		for i, code := range query.CountryCodes {
+			codes[i] = strings.ToUpper(code)
+		}
+		clauses = append(clauses, bson.M{"location.countrycode": bson.M{"$in": codes}})
+	}
+
+	for _, filter := range query.Details {
+		clauses = append(clauses, bson.M{"details." + filter.Key: detailPredicate(filter)})
+	}
+
+	return clauses
+}
+
+// detailPredicate converts a detail filter into the Mongo predicate for the
+// detail. Values that look like numbers are compared as numbers as well as
+// strings, so they match however they were stored.
+func detailPredicate(filter types.DetailFilter) interface{} {
+	number, err := strconv.ParseFloat(filter.Value, 64)
+	isNumber := err == nil
+
+	switch filter.Operator {
+	case types.OperatorContains:
+		return bson.RegEx{Pattern: regexp.QuoteMeta(filter.Value), Options: "i"}
+	case types.OperatorExists:
+		if exists, _ := strconv.ParseBool(filter.Value); exists {
+			return bson.M{"$exists": true, "$nin": []interface{}{"", nil}}
+		}
+		return bson.M{"$in": []interface{}{"", nil}}
+	case types.OperatorGt:
+		if isNumber {
+			return bson.M{"$gt": number}
+		}
+		return bson.M{"$gt": filter.Value}
+	case types.OperatorLt:
+		if isNumber {
+			return bson.M{"$lt": number}
+		}
+		return bson.M{"$lt": filter.Value}
+	default:
+		if isNumber {
+			return bson.M{"$in": []interface{}{filter.Value, number}}
+		}
+		return filter.Value
+	}
+}
+
+// searchFilter builds the Mongo selector for a search query. Polygons take
+// precedence over a radius, which takes precedence over the bounds. Radius
+// searches use $centerSphere so the selector can also be used to count the
+// results.
+func searchFilter(query types.SearchQuery) bson.M {
+	clauses := filterClauses(query)
+
+	if polygonsPresent(query) {
+		clauses = append(clauses, bson.M{
+			"location.coordinates": bson.M{
+				"$geoWithin": bson.M{
+					"$geometry": bson.M{
+						"type":        "MultiPolygon",
+						"coordinates": query.Polygons,
+					},
+				},
+			},
+		})
+	} else if radiusPresent(query) {
+		clauses = append(clauses, bson.M{
+			"location.coordinates": bson.M{
+				"$geoWithin": bson.M{
+					"$centerSphere": []interface{}{
+						[]interface{}{query.Lng, query.Lat},
+						geo.Radians(query.Radius),
+					},
+				},
+			},
+		})
+	} else if boundsPresent(query) {
+		clauses = append(clauses, bson.M{
+			"location.coordinates": bson.M{
+				"$geoWithin": bson.M{
+					"$box": []interface{}{
+						[]interface{}{query.MinLng, query.MinLat},
+						[]interface{}{query.MaxLng, query.MaxLat},
+					},
+				},
+			},
+		})
+	}
+
+	return bson.M{"$and": clauses}
+}
+
+// nearFilter builds the selector for a radius search that returns the records
+// ordered by distance from the centre, nearest first
+func nearFilter(query types.SearchQuery) bson.M {
+	return bson.M{
+		"$and": filterClauses(query),
+		"location.coordinates": bson.M{
+			"$nearSphere": bson.M{
+				"$geometry": bson.M{
+					"type":        "Point",
+					"coordinates": []float64{query.Lng, query.Lat},
+				},
+				"$maxDistance": query.Radius,
+			},
+		},
+	}
+}
+
+// Search takes a query and returns the requested page of records that match
+// along with the total number of matching records
+func (db *MongoDB) Search(query types.SearchQuery) ([]types.Record, int, error) {
 	log.WithFields(log.Fields{
-		"query":  query.Query,
-		"minLat": query.MinLat,
-		"maxLat": query.MaxLat,
-		"minLng": query.MinLng,
-		"maxLng": query.MaxLng,
+		"query":    query.Query,
+		"minLat":   query.MinLat,
+		"maxLat":   query.MaxLat,
+		"minLng":   query.MinLng,
+		"maxLng":   query.MaxLng,
+		"lat":      query.Lat,
+		"lng":      query.Lng,
+		"radius":   query.Radius,
+		"page":     query.Page,
+		"pageSize": query.PageSize,
 	}).Debug("Searching DB")
 	session, err := GetSession(db.URL)
 	defer session.Close()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	c := session.DB("").C(db.Collection)
 
@@ -93,31 +323,131 @@ func (db *MongoDB) Search(query types.SearchQuery) ([]types.Record, error) {
 		"boundsPresent": boundsPresent(query),
 	}).Debug("Checking for bounds")
 
-	if boundsPresent(query) {
-		err = c.Find(bson.M{"$and": []interface{}{bson.M{
-			"location.coordinates": bson.M{
-				"$geoWithin": bson.M{
-					"$box": []interface{}{
-						[]interface{}{query.MinLng, query.MinLat},
-						[]interface{}{query.MaxLng, query.MaxLat},
-					},
-				},
-			},
-		}, bson.M{"deleted": bson.M{"$ne": true}}}}).All(&records)
+	if !boundsPresent(query) && !radiusPresent(query) && !polygonsPresent(query) {
+		return records, 0, nil
+	}
 
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error": err.Error(),
-			}).Error("Failed to get any records from the database.")
-			return nil, err
+	total, err := c.Find(searchFilter(query)).Count()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to count records in the database.")
+		return nil, 0, err
+	}
+
+	// Radius searches are ordered by distance by $nearSphere, text matches are
+	// ordered by relevance, best first, otherwise order by id so pages are
+	// stable between requests
+	near := radiusPresent(query) && !polygonsPresent(query)
+
+	var q *mgo.Query
+	switch {
+	case near:
+		q = c.Find(nearFilter(query))
+	case query.Query != "":
+		q = c.Find(searchFilter(query)).Select(bson.M{"score": bson.M{"$meta": "textScore"}}).Sort("$textScore:score", "_id")
+	default:
+		q = c.Find(searchFilter(query)).Sort("_id")
+	}
+
+	if query.PageSize > 0 {
+		page := query.Page
+		if page < 1 {
+			page = 1
 		}
+		q = q.Skip((page - 1) * query.PageSize).Limit(query.PageSize)
+	}
+
+	err = q.All(&records)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get any records from the database.")
+		return nil, 0, err
+	}
 
+	if near {
+		for i := range records {
+			distance := geo.Distance(query.Lat, query.Lng, records[i].Location.Lat, records[i].Location.Lng)
+			records[i].Distance = &distance
+		}
 	}
-	return records, nil
+
+	return records, total, nil
+}
+
+// validateDetails checks the records details against the current fields,
+// returning a *types.ValidationError if they don't match
+func (db *MongoDB) validateDetails(r types.Record) error {
+	fields, err := db.Fields()
+	if err != nil {
+		return err
+	}
+
+	err = types.ValidateDetails(fields, r.Details)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"id":    r.ID,
+			"error": err.Error(),
+		}).Debug("Record details are invalid")
+		return err
+	}
+
+	return nil
+}
+
+// detailValues flattens the values of a records details so they can be
+// included in the text index
+func detailValues(details map[string]interface{}) []string {
+	var values []string
+	for _, v := range details {
+		switch value := v.(type) {
+		case string:
+			if value != "" {
+				values = append(values, value)
+			}
+		case float64, int, bool:
+			values = append(values, fmt.Sprint(value))
+		}
+	}
+	return values
+}
+
+// Get returns the record with the matching id, records that have been marked
+// as deleted are treated as not existing
+func (db *MongoDB) Get(id string) (types.Record, error) {
+	var record types.Record
+
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return record, err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.Collection)
+
+	err = c.Find(bson.M{"id": id, "deleted": bson.M{"$ne": true}}).One(&record)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			log.WithFields(log.Fields{
+				"id": id,
+			}).Debug("Record with id doesn't exist.")
+			return record, &types.RecordNotFoundError{ID: id, Message: "Record does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    id,
+			"error": err.Error(),
+		}).Error("Failed to get record from the database.")
+		return record, err
+	}
+
+	return record, nil
 }
 
 // Update takes and id of the record to update and a Record object
-// containing any changes and writes it to Mongo
+// containing any changes and writes it to Mongo. The Version of the record
+// must match the version in the database, otherwise a ConflictError holding
+// the current record is returned and nothing is written.
 func (db *MongoDB) Update(id string, r types.Record) error {
 	if r.ID != id {
 		log.WithFields(log.Fields{
@@ -127,7 +457,13 @@ func (db *MongoDB) Update(id string, r types.Record) error {
 		return errors.New("Record ID does not match URL Path ID")
 	}
 
+	if err := db.validateDetails(r); err != nil {
+		return err
+	}
 	r.Location.Coordinates = []float64{r.Location.Lng, r.Location.Lat}
+	r.DetailValues = detailValues(r.Details)
+	r.DeletedAt = nil
+	db.locate(&r)
 
 	session, err := GetSession(db.URL)
 	defer session.Close()
@@ -135,9 +471,33 @@ func (db *MongoDB) Update(id string, r types.Record) error {
 		return err
 	}
 
+	current, err := db.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if current.Version != r.Version {
+		log.WithFields(log.Fields{
+			"id":      id,
+			"version": r.Version,
+			"current": current.Version,
+		}).Debug("Record has been changed since it was read")
+		return &types.ConflictError{ID: id, Message: "Record has been changed since it was read.", Current: current}
+	}
+	r.Version = current.Version + 1
+	r.Attachments = current.Attachments
+
 	c := session.DB("").C(db.Collection)
 
-	err = c.Update(bson.M{"id": id}, r)
+	err = c.Update(bson.M{"id": id, "version": versionMatch(current.Version)}, r)
+	if err == mgo.ErrNotFound {
+		// Written by someone else between reading and updating
+		latest, err := db.Get(id)
+		if err != nil {
+			return err
+		}
+		return &types.ConflictError{ID: id, Message: "Record has been changed since it was read.", Current: latest}
+	}
 	if err != nil {
 		log.WithFields(log.Fields{
 			"id":    id,
@@ -146,9 +506,20 @@ func (db *MongoDB) Update(id string, r types.Record) error {
 		return err
 	}
 
+	db.addRevision(session, types.ActionUpdated, current, r)
+
 	return nil
 }
 
+// versionMatch matches a version in a query, records written before versions
+// were introduced have no version and are treated as version 0
+func versionMatch(version int) interface{} {
+	if version == 0 {
+		return bson.M{"$in": []interface{}{0, nil}}
+	}
+	return version
+}
+
 // Delete marks the matching record in the database as deleted
 func (db *MongoDB) Delete(id string) error {
 
@@ -162,9 +533,18 @@ func (db *MongoDB) Delete(id string) error {
 		return err
 	}
 
+	current, err := db.Get(id)
+	if err != nil {
+		return err
+	}
+
 	c := session.DB("").C(db.Collection)
 
-	err = c.Update(bson.M{"id": id}, bson.M{"$set": bson.M{"deleted": true}})
+	now := time.Now().UTC()
+	err = c.Update(bson.M{"id": id}, bson.M{
+		"$set": bson.M{"deleted": true, "deletedat": now},
+		"$inc": bson.M{"version": 1},
+	})
 	if err != nil {
 		log.WithFields(log.Fields{
 			"id":    id,
@@ -172,6 +552,12 @@ func (db *MongoDB) Delete(id string) error {
 		}).Error("Failed to mark as deleted in database.")
 		return err
 	}
+
+	deleted := current
+	deleted.Version = current.Version + 1
+	deleted.DeletedAt = &now
+	db.addRevision(session, types.ActionDeleted, current, deleted)
+
 	return nil
 }
 
@@ -225,7 +611,7 @@ func (db *MongoDB) UpdateFields(fields []types.Field) error {
 	return nil
 }
 
-//DeleteField takes an id of a field and marks it as deleted
+// DeleteField takes an id of a field and marks it as deleted
 func (db *MongoDB) DeleteField(id string) error {
 
 	session, err := GetSession(db.URL)
@@ -236,14 +622,17 @@ func (db *MongoDB) DeleteField(id string) error {
 
 	c := session.DB("").C(db.FieldCollection)
 
-	err = c.Update(bson.M{"id": id}, bson.M{"$set": bson.M{"deleted": true}})
+	err = c.Update(bson.M{"id": id}, bson.M{
+		"$set": bson.M{"deleted": true, "deletedat": time.Now().UTC()},
+		"$inc": bson.M{"version": 1},
+	})
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			log.WithFields(log.Fields{
 				"id":    id,
 				"error": err.Error(),
 			}).Warn("Field with id doesn't exist.")
-			return &types.FieldNotFoundError{id, "Field does not exist in the database."}
+			return &types.FieldNotFoundError{ID: id, Message: "Field does not exist in the database."}
 		}
 		log.WithFields(log.Fields{
 			"id":    id,
@@ -262,18 +651,90 @@ func (f *FakeDB) Create(r types.Record) (string, error) {
 	return "", nil
 }
 
-func (f *FakeDB) Search(query types.SearchQuery) ([]types.Record, error) {
+func (f *FakeDB) Search(query types.SearchQuery) ([]types.Record, int, error) {
+	return nil, 0, nil
+}
+
+func (f *FakeDB) Export(query types.SearchQuery, each func(types.Record) error) error {
+	return nil
+}
+
+func (f *FakeDB) Clusters(query types.SearchQuery, zoom int) ([]types.Cluster, error) {
 	return nil, nil
 }
 
+func (f *FakeDB) Get(id string) (types.Record, error) {
+	return types.Record{}, nil
+}
+
 func (f *FakeDB) Update(id string, r types.Record) error {
 	return nil
 }
 
+func (f *FakeDB) Patch(id string, p types.Patch) (types.Record, error) {
+	return types.Record{}, nil
+}
+
+func (f *FakeDB) Import(records []types.Record, dryRun bool) ([]types.ImportRow, error) {
+	return nil, nil
+}
+
+func (f *FakeDB) Bulk(op types.BulkOperation) ([]types.BulkResult, error) {
+	return nil, nil
+}
+
 func (f *FakeDB) Delete(id string) error {
 	return nil
 }
 
+func (f *FakeDB) History(id string) ([]types.Revision, error) {
+	return nil, nil
+}
+
+func (f *FakeDB) Revision(id string, rev int) (types.Revision, error) {
+	return types.Revision{}, nil
+}
+
+func (f *FakeDB) As(actor string) Database {
+	return f
+}
+
+func (f *FakeDB) Deleted(page, pageSize int) ([]types.Record, int, error) {
+	return nil, 0, nil
+}
+
+func (f *FakeDB) Restore(id string) error {
+	return nil
+}
+
+func (f *FakeDB) AddAttachment(id string, a types.Attachment) (types.Attachment, error) {
+	return a, nil
+}
+
+func (f *FakeDB) DeleteAttachment(id, attachmentID string) (types.Attachment, error) {
+	return types.Attachment{}, nil
+}
+
+func (f *FakeDB) Notes(recordID string) ([]types.Note, error) {
+	return nil, nil
+}
+
+func (f *FakeDB) GetNote(recordID, noteID string) (types.Note, error) {
+	return types.Note{}, nil
+}
+
+func (f *FakeDB) AddNote(recordID string, n types.Note) (types.Note, error) {
+	return n, nil
+}
+
+func (f *FakeDB) UpdateNote(recordID string, n types.Note) (types.Note, error) {
+	return n, nil
+}
+
+func (f *FakeDB) DeleteNote(recordID, noteID string) error {
+	return nil
+}
+
 func (f *FakeDB) Fields() ([]types.Field, error) {
 	return nil, nil
 }
@@ -281,3 +742,71 @@ func (f *FakeDB) Fields() ([]types.Field, error) {
 func (f *FakeDB) UpdateFields(fields []types.Field) error {
 	return nil
 }
+
+func (f *FakeDB) DeleteField(id string) error {
+	return nil
+}
+
+func (f *FakeDB) DeletedFields() ([]types.Field, error) {
+	return nil, nil
+}
+
+func (f *FakeDB) RestoreField(id string) error {
+	return nil
+}
+
+func (f *FakeDB) In(workspace string) Database {
+	return f
+}
+
+func (f *FakeDB) Workspaces() ([]types.Workspace, error) {
+	return nil, nil
+}
+
+func (f *FakeDB) GetWorkspace(name string) (types.Workspace, error) {
+	return types.Workspace{}, nil
+}
+
+func (f *FakeDB) CreateWorkspace(w types.Workspace) (types.Workspace, error) {
+	return w, nil
+}
+
+func (f *FakeDB) Webhooks() ([]types.Webhook, error) {
+	return nil, nil
+}
+
+func (f *FakeDB) GetWebhook(id string) (types.Webhook, error) {
+	return types.Webhook{}, nil
+}
+
+func (f *FakeDB) CreateWebhook(h types.Webhook) (types.Webhook, error) {
+	return h, nil
+}
+
+func (f *FakeDB) UpdateWebhook(h types.Webhook) (types.Webhook, error) {
+	return h, nil
+}
+
+func (f *FakeDB) DeleteWebhook(id string) error {
+	return nil
+}
+
+func (f *FakeDB) Deliveries(webhookID, status string, page, pageSize int) ([]types.Delivery, int, error) {
+	return nil, 0, nil
+}
+
+func (f *FakeDB) AddDelivery(d types.Delivery) (types.Delivery, error) {
+	return d, nil
+}
+
+func (f *FakeDB) ClaimDelivery(lease time.Duration) (types.Delivery, error) {
+	return types.Delivery{}, &types.DeliveryNotFoundError{Message: "No deliveries are due."}
+}
+
+func (f *FakeDB) UpdateDelivery(d types.Delivery) error {
+	return nil
+}
+
+func (f *FakeDB) RetryDelivery(webhookID, id string) (types.Delivery, error) {
+	return types.Delivery{}, nil
+}
@@ -0,0 +1,399 @@
+package database
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/geo"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo/bson"
+)
+
+var leedsBounds = types.SearchQuery{
+	MinLat: 53.635682044465476,
+	MaxLat: 53.840373979032805,
+	MinLng: -1.9713592529296877,
+	MaxLng: -1.1144256591796877,
+}
+
+func clauses(t *testing.T, filter bson.M) []interface{} {
+	and, ok := filter["$and"].([]interface{})
+	if !ok {
+		t.Fatalf("Expected filter to be an $and of clauses, got: %v", filter)
+	}
+	return and
+}
+
+func hasClause(filter bson.M, key string) bool {
+	for _, c := range filter["$and"].([]interface{}) {
+		if _, ok := c.(bson.M)[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSearchFilterExcludesDeletedRecords(t *testing.T) {
+	filter := searchFilter(leedsBounds)
+	if !hasClause(filter, "deleted") {
+		t.Errorf("Expected deleted records to be excluded, got: %v", filter)
+	}
+}
+
+func TestSearchFilterWithoutQueryHasNoTextClause(t *testing.T) {
+	filter := searchFilter(leedsBounds)
+	if hasClause(filter, "$text") {
+		t.Errorf("Expected no $text clause, got: %v", filter)
+	}
+	if len(clauses(t, filter)) != 2 {
+		t.Errorf("Expected deleted and bounds clauses, got: %v", filter)
+	}
+}
+
+func TestSearchFilterAddsTextClauseForQuery(t *testing.T) {
+	query := leedsBounds
+	query.Query = "Airport"
+
+	for _, c := range clauses(t, searchFilter(query)) {
+		if text, ok := c.(bson.M)["$text"]; ok {
+			expected := bson.M{"$search": "Airport"}
+			if !reflect.DeepEqual(text, expected) {
+				t.Errorf("Expected text clause %v, got: %v", expected, text)
+			}
+			return
+		}
+	}
+	t.Error("Expected a $text clause for the query")
+}
+
+func TestDetailValuesCollectsStringsAndNumbers(t *testing.T) {
+	values := detailValues(map[string]interface{}{
+		"description": "Airport",
+		"runways":     float64(2),
+		"empty":       "",
+		"nested":      map[string]interface{}{"a": "b"},
+	})
+	sort.Strings(values)
+
+	expected := []string{"2", "Airport"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got: %v", expected, values)
+	}
+}
+
+func TestSameValuesIgnoresOrder(t *testing.T) {
+	if !sameValues([]string{"2", "Airport"}, []string{"Airport", "2"}) {
+		t.Error("Expected values in another order to be the same")
+	}
+	if sameValues([]string{"Airport", "Airport"}, []string{"Airport", "2"}) {
+		t.Error("Expected values with a different value to differ")
+	}
+	if sameValues([]string{"Airport"}, nil) {
+		t.Error("Expected values to differ from none")
+	}
+}
+
+func TestSearchFilterUsesCenterSphereForRadius(t *testing.T) {
+	query := types.SearchQuery{Lat: 53.7997, Lng: -1.5492, Radius: 25000}
+
+	for _, c := range clauses(t, searchFilter(query)) {
+		if location, ok := c.(bson.M)["location.coordinates"]; ok {
+			within := location.(bson.M)["$geoWithin"].(bson.M)
+			if _, ok := within["$centerSphere"]; !ok {
+				t.Errorf("Expected a $centerSphere clause, got: %v", within)
+			}
+			return
+		}
+	}
+	t.Error("Expected a location clause for the radius")
+}
+
+func TestNearFilterLimitsToRadius(t *testing.T) {
+	query := types.SearchQuery{Lat: 53.7997, Lng: -1.5492, Radius: 25000}
+
+	near := nearFilter(query)["location.coordinates"].(bson.M)["$nearSphere"].(bson.M)
+	if near["$maxDistance"] != 25000.0 {
+		t.Errorf("Expected a max distance of 25000, got: %v", near["$maxDistance"])
+	}
+
+	point := near["$geometry"].(bson.M)["coordinates"].([]float64)
+	if point[0] != query.Lng || point[1] != query.Lat {
+		t.Errorf("Expected the point to be [lng, lat], got: %v", point)
+	}
+}
+
+func TestSearchFilterMatchesAnyFacilityByDefault(t *testing.T) {
+	query := leedsBounds
+	query.Facilities = []string{"TAXI", "TRN"}
+
+	for _, c := range clauses(t, searchFilter(query)) {
+		if facilities, ok := c.(bson.M)["facilities"]; ok {
+			expected := bson.M{"$in": []string{"TAXI", "TRN"}}
+			if !reflect.DeepEqual(facilities, expected) {
+				t.Errorf("Expected %v, got: %v", expected, facilities)
+			}
+			return
+		}
+	}
+	t.Error("Expected a facilities clause")
+}
+
+func TestSearchFilterCanMatchAllFacilities(t *testing.T) {
+	query := leedsBounds
+	query.Facilities = []string{"TAXI", "TRN"}
+	query.FacilityMatch = "all"
+
+	for _, c := range clauses(t, searchFilter(query)) {
+		if facilities, ok := c.(bson.M)["facilities"]; ok {
+			expected := bson.M{"$all": []string{"TAXI", "TRN"}}
+			if !reflect.DeepEqual(facilities, expected) {
+				t.Errorf("Expected %v, got: %v", expected, facilities)
+			}
+			return
+		}
+	}
+	t.Error("Expected a facilities clause")
+}
+
+func TestDetailPredicates(t *testing.T) {
+	tests := []struct {
+		filter   types.DetailFilter
+		expected interface{}
+	}{
+		{types.DetailFilter{Key: "description", Operator: types.OperatorEq, Value: "Airport"}, "Airport"},
+		{types.DetailFilter{Key: "runways", Operator: types.OperatorEq, Value: "2"}, bson.M{"$in": []interface{}{"2", 2.0}}},
+		{types.DetailFilter{Key: "description", Operator: types.OperatorContains, Value: "air.port"}, bson.RegEx{Pattern: `air\.port`, Options: "i"}},
+		{types.DetailFilter{Key: "description", Operator: types.OperatorExists, Value: "true"}, bson.M{"$exists": true, "$nin": []interface{}{"", nil}}},
+		{types.DetailFilter{Key: "description", Operator: types.OperatorExists, Value: "false"}, bson.M{"$in": []interface{}{"", nil}}},
+		{types.DetailFilter{Key: "runways", Operator: types.OperatorGt, Value: "2"}, bson.M{"$gt": 2.0}},
+		{types.DetailFilter{Key: "opened", Operator: types.OperatorLt, Value: "2010-01-01"}, bson.M{"$lt": "2010-01-01"}},
+	}
+
+	for _, test := range tests {
+		predicate := detailPredicate(test.filter)
+		if !reflect.DeepEqual(predicate, test.expected) {
+			t.Errorf("Expected %s %s to be %v, got: %v", test.filter.Operator, test.filter.Value, test.expected, predicate)
+		}
+	}
+}
+
+func TestSearchFilterAddsClauseForEachDetailFilter(t *testing.T) {
+	query := leedsBounds
+	query.Details = []types.DetailFilter{
+		{Key: "description", Operator: types.OperatorContains, Value: "Airport"},
+		{Key: "runways", Operator: types.OperatorGt, Value: "1"},
+	}
+
+	filter := searchFilter(query)
+	if !hasClause(filter, "details.description") || !hasClause(filter, "details.runways") {
+		t.Errorf("Expected clauses for details.description and details.runways, got: %v", filter)
+	}
+}
+
+func TestVersionMatchIncludesUnversionedRecords(t *testing.T) {
+	if versionMatch(3) != 3 {
+		t.Errorf("Expected version 3 to be matched exactly but got %v", versionMatch(3))
+	}
+
+	legacy, ok := versionMatch(0).(bson.M)
+	if !ok || len(legacy["$in"].([]interface{})) != 2 {
+		t.Errorf("Expected version 0 to also match records without a version but got %v", versionMatch(0))
+	}
+}
+
+func TestPatchUpdateOnlySetsChangedFields(t *testing.T) {
+	patched := types.Record{
+		Title:   "Leeds",
+		Details: map[string]interface{}{"colour": "Red"},
+		Version: 3,
+	}
+	patched.Location.Lat = 53.86
+	patched.Location.Type = "Point"
+	patched.Location.Coordinates = []float64{0, 53.86}
+	patched.DetailValues = []string{"Red"}
+
+	update := patchUpdate([]string{"details.colour", "details.description", "location.lat"}, patched)
+
+	set := update["$set"].(bson.M)
+	unset := update["$unset"].(bson.M)
+
+	if set["details.colour"] != "Red" || set["location.lat"] != 53.86 || set["version"] != 3 {
+		t.Errorf("Expected patched fields and version to be set but got %v", set)
+	}
+
+	if _, ok := set["location.coordinates"]; !ok {
+		t.Errorf("Expected coordinates to be set when the latitude changes but got %v", set)
+	}
+
+	if _, ok := set["title"]; ok {
+		t.Errorf("Expected unpatched title not to be set but got %v", set)
+	}
+
+	if _, ok := unset["details.description"]; !ok {
+		t.Errorf("Expected removed detail to be unset but got %v", unset)
+	}
+}
+
+func TestPatchUpdateWritesWholeParentOnce(t *testing.T) {
+	patched := types.Record{Details: map[string]interface{}{"colour": "Red"}}
+
+	update := patchUpdate([]string{"details", "details.colour"}, patched)
+
+	set := update["$set"].(bson.M)
+	if _, ok := set["details.colour"]; ok {
+		t.Errorf("Expected only the whole details to be set but got %v", set)
+	}
+	if !reflect.DeepEqual(set["details"], patched.Details) {
+		t.Errorf("Expected details to be set to %v but got %v", patched.Details, set["details"])
+	}
+}
+
+func TestBulkChangeSkipsRecordsThatWouldNotChange(t *testing.T) {
+	r := types.Record{ID: "1", Facilities: []string{"TAXI"}, Details: map[string]interface{}{"region": "North"}}
+
+	for _, op := range []types.BulkOperation{
+		{Operation: types.BulkAddFacility, Facility: "TAXI"},
+		{Operation: types.BulkRemoveFacility, Facility: "BUS"},
+		{Operation: types.BulkSetDetail, Key: "region", Value: "North"},
+		{Operation: types.BulkSetDetail, Key: "colour"},
+	} {
+		if _, update := bulkChange(op, r); update != nil {
+			t.Errorf("Expected %+v to leave the record unchanged but got %v", op, update)
+		}
+	}
+}
+
+func TestBulkChangeSetsDetailAndVersion(t *testing.T) {
+	r := types.Record{ID: "1", Details: map[string]interface{}{"region": "North"}, Version: 2}
+
+	after, update := bulkChange(types.BulkOperation{Operation: types.BulkSetDetail, Key: "region", Value: "South"}, r)
+
+	if after.Details["region"] != "South" || after.Version != 3 {
+		t.Errorf("Expected region South at version 3 but got %+v", after)
+	}
+
+	if r.Details["region"] != "North" {
+		t.Error("Expected the original record to be left unchanged")
+	}
+
+	set := update["$set"].(bson.M)
+	if set["details.region"] != "South" || !reflect.DeepEqual(set["detailvalues"], []string{"South"}) {
+		t.Errorf("Expected detail and detail values to be set but got %v", set)
+	}
+}
+
+func TestBulkDeleteSnapshotIsDeleted(t *testing.T) {
+	r := types.Record{ID: "1", Version: 2}
+
+	after, update := bulkChange(types.BulkOperation{Operation: types.BulkDelete}, r)
+
+	if after.Version != 3 || after.DeletedAt == nil {
+		t.Fatalf("Expected a deleted record at version 3 but got %+v", after)
+	}
+	if set := update["$set"].(bson.M); set["deletedat"] != *after.DeletedAt {
+		t.Errorf("Expected the deletion time written to match the snapshot but got %v", set["deletedat"])
+	}
+	if r.DeletedAt != nil {
+		t.Error("Expected the original record to be left unchanged")
+	}
+}
+
+func TestScopedUsesTheCollectionsOfTheWorkspace(t *testing.T) {
+	db := &MongoDB{Collection: "records", FieldCollection: "fields", HistoryCollection: "history", WorkspaceCollection: "workspaces"}
+
+	scoped := db.Scoped("team-a")
+	if scoped.Collection != "team-a.records" || scoped.FieldCollection != "team-a.fields" || scoped.HistoryCollection != "team-a.history" {
+		t.Errorf("Expected collections prefixed with team-a but got %s, %s, %s", scoped.Collection, scoped.FieldCollection, scoped.HistoryCollection)
+	}
+
+	if scoped.WorkspaceCollection != "workspaces" {
+		t.Errorf("Expected workspaces to be shared but got %s", scoped.WorkspaceCollection)
+	}
+
+	if again := scoped.Scoped("team-b"); again.Collection != "team-b.records" {
+		t.Errorf("Expected rescoping to replace the workspace but got %s", again.Collection)
+	}
+
+	if back := scoped.Scoped(types.DefaultWorkspace); back.Collection != "records" {
+		t.Errorf("Expected the default workspace to use the configured collections but got %s", back.Collection)
+	}
+}
+
+func TestSearchFilterMatchesCountryCodes(t *testing.T) {
+	query := leedsBounds
+	query.CountryCodes = []string{"gb", "IE"}
+
+	for _, c := range clauses(t, searchFilter(query)) {
+		if codes, ok := c.(bson.M)["location.countrycode"]; ok {
+			expected := bson.M{"$in": []string{"GB", "IE"}}
+			if !reflect.DeepEqual(codes, expected) {
+				t.Errorf("Expected %v, got: %v", expected, codes)
+			}
+			return
+		}
+	}
+	t.Error("Expected a country code clause")
+}
+
+type stubGeocoder map[float64]geo.Place
+
+func (g stubGeocoder) Reverse(lat, lng float64) (geo.Place, error) {
+	if place, ok := g[lat]; ok {
+		return place, nil
+	}
+	return geo.Place{}, geo.ErrNoPlace
+}
+
+func TestLocateFillsInTheCountryCodeAndRegion(t *testing.T) {
+	db := &MongoDB{Geocoder: stubGeocoder{55.95: {CountryCode: "GB", Country: "United Kingdom", Region: "Scotland"}}}
+
+	r := types.Record{}
+	r.Location.Lat, r.Location.Lng = 55.95, -3.36
+	r.Location.Country = "Edinburgh, UK"
+	db.locate(&r)
+
+	if r.Location.CountryCode != "GB" || r.Location.Region != "Scotland" || r.Location.Country != "Edinburgh, UK" {
+		t.Errorf("Expected GB Scotland to be filled in alongside the country but got %+v", r.Location)
+	}
+
+	r.Location.Lat, r.Location.Lng = 47.0, -10.0
+	db.locate(&r)
+
+	if r.Location.CountryCode != "GB" || r.Location.Region != "Scotland" {
+		t.Errorf("Expected a record outside the boundaries to keep its country code but got %+v", r.Location)
+	}
+}
+
+func TestClusterPipelineGroupsMatchingRecordsIntoZoomSizedCells(t *testing.T) {
+	if cellSize(0) != 90 || cellSize(10) != 90.0/1024 {
+		t.Errorf("Expected cells to halve with each zoom level but got %f and %f", cellSize(0), cellSize(10))
+	}
+
+	pipeline := clusterPipeline(leedsBounds, 10)
+	if !reflect.DeepEqual(pipeline[0]["$match"], searchFilter(leedsBounds)) {
+		t.Errorf("Expected the records to be matched as they're searched, got: %v", pipeline[0])
+	}
+
+	group, ok := pipeline[1]["$group"].(bson.M)
+	if !ok {
+		t.Fatalf("Expected the records to be grouped, got: %v", pipeline[1])
+	}
+	x := group["_id"].(bson.M)["x"]
+	expected := bson.M{"$floor": bson.M{"$divide": []interface{}{
+		bson.M{"$add": []interface{}{"$location.lng", 180.0}},
+		90.0 / 1024,
+	}}}
+	if !reflect.DeepEqual(x, expected) {
+		t.Errorf("Expected %v, got: %v", expected, x)
+	}
+	for _, field := range []string{"count", "lat", "lng", "minlat", "maxlat", "minlng", "maxlng", "id"} {
+		if _, ok := group[field]; !ok {
+			t.Errorf("Expected the clusters to have %s", field)
+		}
+	}
+
+	if pipeline[len(pipeline)-1]["$limit"] != types.MaxClusters {
+		t.Errorf("Expected the clusters to be limited, got: %v", pipeline[len(pipeline)-1])
+	}
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// Export calls each with every record matching the filters of the query, in
+// the order they were created. Records are read from the database as they
+// are needed rather than all at once, paging is ignored and the query doesn't
+// need an area, without one every record is exported.
+func (db *MongoDB) Export(query types.SearchQuery, each func(types.Record) error) error {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.Collection)
+
+	iter := c.Find(searchFilter(query)).Sort("_id").Iter()
+
+	var record types.Record
+	for iter.Next(&record) {
+		if err := each(record); err != nil {
+			iter.Close()
+			return err
+		}
+		record = types.Record{}
+	}
+
+	if err := iter.Close(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to export records from the database.")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"github.com/cstdev/knowledge-hub/apps/knowledge/geo"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// locate fills in the country code and region of the record from its lat
+// and lng. They're left as they are when the location isn't in any of the
+// boundaries, as they may only be missing from them, or if the geocoder
+// fails.
+func (db *MongoDB) locate(r *types.Record) {
+	if db.Geocoder == nil {
+		return
+	}
+
+	place, err := db.Geocoder.Reverse(r.Location.Lat, r.Location.Lng)
+	if err == geo.ErrNoPlace {
+		return
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"id":    r.ID,
+			"error": err.Error(),
+		}).Warn("Failed to geocode record.")
+		return
+	}
+
+	r.Location.CountryCode = place.CountryCode
+	r.Location.Region = place.Region
+}
+
+// Geocode fills in the country code and region of the records, including
+// deleted ones, that don't have a country code yet, or of every record when
+// all is set. As they only follow from the location the records are updated
+// without changing their version or history. It returns how many records
+// were changed.
+func (db *MongoDB) Geocode(all bool) (int, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return 0, err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.Collection)
+
+	filter := bson.M{}
+	if !all {
+		filter["location.countrycode"] = bson.M{"$in": []interface{}{"", nil}}
+	}
+
+	updated := 0
+	iter := c.Find(filter).Select(bson.M{"id": 1, "location": 1}).Iter()
+	for {
+		var r types.Record
+		if !iter.Next(&r) {
+			break
+		}
+
+		code, region := r.Location.CountryCode, r.Location.Region
+		db.locate(&r)
+		if r.Location.CountryCode == code && r.Location.Region == region {
+			continue
+		}
+
+		set := bson.M{}
+		unset := bson.M{}
+		for key, value := range map[string]string{
+			"location.countrycode": r.Location.CountryCode,
+			"location.region":      r.Location.Region,
+		} {
+			if value == "" {
+				unset[key] = ""
+			} else {
+				set[key] = value
+			}
+		}
+		update := bson.M{}
+		if len(set) > 0 {
+			update["$set"] = set
+		}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		if err := c.Update(bson.M{"id": r.ID}, update); err != nil {
+			log.WithFields(log.Fields{
+				"id":    r.ID,
+				"error": err.Error(),
+			}).Error("Failed to geocode record in the database.")
+			iter.Close()
+			return updated, err
+		}
+		updated++
+	}
+
+	if err := iter.Close(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to read records to geocode.")
+		return updated, err
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// revisionAttempts is how many times a revision is written before giving up,
+// as writes that don't check the version of the record can race to take the
+// next rev
+const revisionAttempts = 5
+
+// addRevision writes a snapshot of the record into its history. The write
+// to the record has already happened so a failure is logged rather than
+// returned, the change shouldn't be reported as failed when it was made.
+// Each revision takes the rev after the latest, trying the next one if
+// another write took it first.
+func (db *MongoDB) addRevision(session *mgo.Session, action string, before, after types.Record) {
+	c := session.DB("").C(db.HistoryCollection)
+
+	changed := types.ChangedKeys(before, after)
+	if action == types.ActionDeleted || action == types.ActionRestored {
+		changed = []string{"deleted"}
+	}
+
+	revision := types.Revision{
+		RecordID:  after.ID,
+		Action:    action,
+		Timestamp: time.Now().UTC(),
+		Actor:     db.Actor,
+		Changed:   changed,
+		Record:    &after,
+	}
+
+	var err error
+	for attempt := 0; attempt < revisionAttempts; attempt++ {
+		var latest types.Revision
+		err = c.Find(bson.M{"recordid": after.ID}).Sort("-rev").One(&latest)
+		if err != nil && err != mgo.ErrNotFound {
+			log.WithFields(log.Fields{
+				"id":    after.ID,
+				"error": err.Error(),
+			}).Error("Failed to get latest revision.")
+			return
+		}
+
+		revision.Rev = latest.Rev + 1
+		err = c.Insert(revision)
+		if !mgo.IsDup(err) {
+			break
+		}
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"id":     after.ID,
+			"action": action,
+			"error":  err.Error(),
+		}).Error("Failed to write revision to history.")
+	}
+}
+
+// History lists the revisions of a record, newest first. The snapshots of
+// the record are left out, they can be retrieved with Revision.
+func (db *MongoDB) History(id string) ([]types.Revision, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.HistoryCollection)
+
+	var revisions []types.Revision
+	err = c.Find(bson.M{"recordid": id}).Select(bson.M{"record": 0}).Sort("-rev").All(&revisions)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"id":    id,
+			"error": err.Error(),
+		}).Error("Failed to get history from the database.")
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		return nil, &types.RecordNotFoundError{ID: id, Message: "Record has no history."}
+	}
+
+	return revisions, nil
+}
+
+// Revision returns a single revision of a record including its snapshot
+func (db *MongoDB) Revision(id string, rev int) (types.Revision, error) {
+	var revision types.Revision
+
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return revision, err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.HistoryCollection)
+
+	err = c.Find(bson.M{"recordid": id, "rev": rev}).One(&revision)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return revision, &types.RevisionNotFoundError{ID: id, Rev: rev, Message: "Revision does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    id,
+			"rev":   rev,
+			"error": err.Error(),
+		}).Error("Failed to get revision from the database.")
+		return revision, err
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// Import validates each of the records and inserts the valid ones in bulk,
+// returning the outcome of each in the same order. Records with the same
+// title and location as an existing record, or an earlier record of the
+// import, are skipped so an import can be run again. On a dry run nothing is
+// written.
+func (db *MongoDB) Import(records []types.Record, dryRun bool) ([]types.ImportRow, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	fields, err := db.Fields()
+	if err != nil {
+		return nil, err
+	}
+
+	c := session.DB("").C(db.Collection)
+
+	results := make([]types.ImportRow, len(records))
+	seen := make(map[string]bool)
+	var inserts []interface{}
+	var created []types.Record
+
+	for i, r := range records {
+		results[i].Title = r.Title
+
+		if err := types.ValidateDetails(fields, r.Details); err != nil {
+			results[i].Status = types.ImportFailed
+			results[i].Message = err.Error()
+			if invalid, ok := err.(*types.ValidationError); ok {
+				results[i].Message = invalid.Message
+				results[i].Fields = invalid.Fields
+			}
+			continue
+		}
+
+		key := fmt.Sprintf("%s|%v|%v", r.Title, r.Location.Lat, r.Location.Lng)
+		if seen[key] {
+			results[i].Status = types.ImportSkipped
+			results[i].Message = "Duplicate of an earlier row"
+			continue
+		}
+		seen[key] = true
+
+		existing, err := c.Find(bson.M{
+			"title":        r.Title,
+			"location.lat": r.Location.Lat,
+			"location.lng": r.Location.Lng,
+			"deleted":      bson.M{"$ne": true},
+		}).Count()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Failed to check for existing records.")
+			return nil, err
+		}
+		if existing > 0 {
+			results[i].Status = types.ImportSkipped
+			results[i].Message = "A record with this title and location already exists"
+			continue
+		}
+
+		results[i].Status = types.ImportCreated
+		if dryRun {
+			continue
+		}
+
+		id := bson.NewObjectId()
+		r = newRecord(id, r)
+		db.locate(&r)
+		results[i].ID = r.ID
+		inserts = append(inserts, bson.M{"_id": id}, r)
+		created = append(created, r)
+	}
+
+	if len(inserts) == 0 {
+		return results, nil
+	}
+
+	bulk := c.Bulk()
+	bulk.Unordered()
+	bulk.Upsert(inserts...)
+	if _, err := bulk.Run(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to import records into the database.")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"created": len(created),
+	}).Debug("Imported records")
+
+	for _, r := range created {
+		db.addRevision(session, types.ActionCreated, types.Record{}, r)
+	}
+
+	return results, nil
+}
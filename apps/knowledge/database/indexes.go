@@ -0,0 +1,172 @@
+package database
+
+import (
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeliveryRetention is how long deliveries to webhooks are kept for, whether
+// or not they succeeded
+const DeliveryRetention = 30 * 24 * time.Hour
+
+// EnsureIndexes creates the indexes the record searches rely on, it is safe
+// to call on every start up as existing indexes are left alone
+func (db *MongoDB) EnsureIndexes() error {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.Collection)
+
+	err = c.EnsureIndex(mgo.Index{
+		Name: "record_text",
+		Key: []string{
+			"$text:title",
+			"$text:shortname",
+			"$text:location.country",
+			"$text:facilities",
+			"$text:detailvalues",
+		},
+		Weights: map[string]int{
+			"title":            10,
+			"shortname":        10,
+			"facilities":       5,
+			"location.country": 3,
+			"detailvalues":     1,
+		},
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to create text index.")
+		return err
+	}
+
+	err = c.EnsureIndex(mgo.Index{
+		Name: "record_location",
+		Key:  []string{"$2dsphere:location.coordinates"},
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to create location index.")
+		return err
+	}
+
+	err = session.DB("").C(db.HistoryCollection).EnsureIndex(mgo.Index{
+		Name:   "history_record_rev",
+		Key:    []string{"recordid", "-rev"},
+		Unique: true,
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to create history index.")
+		return err
+	}
+
+	err = session.DB("").C(db.NoteCollection).EnsureIndex(mgo.Index{
+		Name: "note_record_created",
+		Key:  []string{"recordid", "created"},
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to create note index.")
+		return err
+	}
+
+	deliveries := session.DB("").C(db.DeliveryCollection)
+	for _, index := range []mgo.Index{
+		{Name: "delivery_due", Key: []string{"status", "nextattempt"}},
+		{Name: "delivery_webhook_created", Key: []string{"webhookid", "-created"}},
+		{Name: "delivery_expiry", Key: []string{"created"}, ExpireAfter: DeliveryRetention},
+	} {
+		if err := deliveries.EnsureIndex(index); err != nil {
+			log.WithFields(log.Fields{
+				"index": index.Name,
+				"error": err.Error(),
+			}).Error("Failed to create delivery index.")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IndexDetails fills in the detail values of the records, including deleted
+// ones, so that records written before their details were text indexed can
+// be found by them. As they only follow from the details the records are
+// updated without changing their version or history. It returns how many
+// records were changed.
+func (db *MongoDB) IndexDetails() (int, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return 0, err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.Collection)
+
+	updated := 0
+	iter := c.Find(bson.M{"details": bson.M{"$exists": true}}).Select(bson.M{"id": 1, "details": 1, "detailvalues": 1}).Iter()
+	for {
+		var r types.Record
+		if !iter.Next(&r) {
+			break
+		}
+
+		values := detailValues(r.Details)
+		if sameValues(values, r.DetailValues) {
+			continue
+		}
+
+		update := bson.M{"$set": bson.M{"detailvalues": values}}
+		if len(values) == 0 {
+			update = bson.M{"$unset": bson.M{"detailvalues": ""}}
+		}
+
+		if err := c.Update(bson.M{"id": r.ID}, update); err != nil {
+			log.WithFields(log.Fields{
+				"id":    r.ID,
+				"error": err.Error(),
+			}).Error("Failed to index details of record in the database.")
+			iter.Close()
+			return updated, err
+		}
+		updated++
+	}
+
+	if err := iter.Close(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to read records to index.")
+		return updated, err
+	}
+
+	return updated, nil
+}
+
+// sameValues reports whether a and b hold the same values, in any order
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int)
+	for _, v := range a {
+		counts[v]++
+	}
+	for _, v := range b {
+		if counts[v] == 0 {
+			return false
+		}
+		counts[v]--
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// Notes lists the notes of a record, oldest first. The notes of deleted
+// records are hidden along with the record.
+func (db *MongoDB) Notes(recordID string) ([]types.Note, error) {
+	if _, err := db.Get(recordID); err != nil {
+		return nil, err
+	}
+
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	notes := []types.Note{}
+	err = session.DB("").C(db.NoteCollection).Find(bson.M{"recordid": recordID}).Sort("created", "_id").All(&notes)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"id":    recordID,
+			"error": err.Error(),
+		}).Error("Failed to get notes from the database.")
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// GetNote returns a note of a record
+func (db *MongoDB) GetNote(recordID, noteID string) (types.Note, error) {
+	var note types.Note
+
+	if _, err := db.Get(recordID); err != nil {
+		return note, err
+	}
+
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return note, err
+	}
+	defer session.Close()
+
+	err = session.DB("").C(db.NoteCollection).Find(bson.M{"_id": noteID, "recordid": recordID}).One(&note)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return note, &types.NoteNotFoundError{ID: noteID, RecordID: recordID, Message: "Note does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    recordID,
+			"note":  noteID,
+			"error": err.Error(),
+		}).Error("Failed to get note from the database.")
+		return note, err
+	}
+
+	return note, nil
+}
+
+// AddNote adds a note to a record, written by the actor of the database
+func (db *MongoDB) AddNote(recordID string, n types.Note) (types.Note, error) {
+	if _, err := db.Get(recordID); err != nil {
+		return n, err
+	}
+
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return n, err
+	}
+	defer session.Close()
+
+	n.ID = bson.NewObjectId().Hex()
+	n.RecordID = recordID
+	n.Author = db.Actor
+	n.Created = time.Now().UTC()
+	n.Updated = nil
+
+	if err := session.DB("").C(db.NoteCollection).Insert(n); err != nil {
+		log.WithFields(log.Fields{
+			"id":    recordID,
+			"error": err.Error(),
+		}).Error("Failed to insert note into the database.")
+		return n, err
+	}
+
+	return n, nil
+}
+
+// UpdateNote changes the text and URL of a note, returning it as it now is
+func (db *MongoDB) UpdateNote(recordID string, n types.Note) (types.Note, error) {
+	current, err := db.GetNote(recordID, n.ID)
+	if err != nil {
+		return n, err
+	}
+
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return n, err
+	}
+	defer session.Close()
+
+	updated := time.Now().UTC()
+	current.Text = n.Text
+	current.URL = n.URL
+	current.Updated = &updated
+
+	update := bson.M{"$set": bson.M{"text": current.Text, "updated": updated}}
+	if current.URL == "" {
+		update["$unset"] = bson.M{"url": ""}
+	} else {
+		update["$set"].(bson.M)["url"] = current.URL
+	}
+
+	err = session.DB("").C(db.NoteCollection).Update(bson.M{"_id": n.ID, "recordid": recordID}, update)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return n, &types.NoteNotFoundError{ID: n.ID, RecordID: recordID, Message: "Note does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    recordID,
+			"note":  n.ID,
+			"error": err.Error(),
+		}).Error("Failed to update note in the database.")
+		return n, err
+	}
+
+	return current, nil
+}
+
+// DeleteNote permanently removes a note from a record
+func (db *MongoDB) DeleteNote(recordID, noteID string) error {
+	if _, err := db.Get(recordID); err != nil {
+		return err
+	}
+
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	err = session.DB("").C(db.NoteCollection).Remove(bson.M{"_id": noteID, "recordid": recordID})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return &types.NoteNotFoundError{ID: noteID, RecordID: recordID, Message: "Note does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    recordID,
+			"note":  noteID,
+			"error": err.Error(),
+		}).Error("Failed to delete note from the database.")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// Patch makes the changes of the patch to the matching record, only writing
+// the fields that have changed. The patched record is validated the same as
+// an update and returned once written. When the patch has a version it's
+// only written if the record is still at that version, otherwise it's written
+// over any changes made since the record was read.
+func (db *MongoDB) Patch(id string, p types.Patch) (types.Record, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return types.Record{}, err
+	}
+	defer session.Close()
+
+	current, err := db.Get(id)
+	if err != nil {
+		return types.Record{}, err
+	}
+
+	if p.Version != nil && *p.Version != current.Version {
+		log.WithFields(log.Fields{
+			"id":      id,
+			"version": *p.Version,
+			"current": current.Version,
+		}).Debug("Record has been changed since it was read")
+		return current, &types.ConflictError{ID: id, Message: "Record has been changed since it was read.", Current: current}
+	}
+
+	patched, err := p.Apply(current)
+	if err != nil {
+		return current, err
+	}
+
+	if err := db.validateDetails(patched); err != nil {
+		return current, err
+	}
+	patched.Location.Type = "Point"
+	patched.Location.Coordinates = []float64{patched.Location.Lng, patched.Location.Lat}
+	patched.DetailValues = detailValues(patched.Details)
+	patched.Version = current.Version + 1
+	db.locate(&patched)
+
+	c := session.DB("").C(db.Collection)
+
+	filter := bson.M{"id": id, "deleted": bson.M{"$ne": true}}
+	update := patchUpdate(p.Paths(), patched)
+	if p.Version != nil {
+		filter["version"] = versionMatch(current.Version)
+	} else {
+		// Only the patched fields are written over whatever has changed since
+		delete(update["$set"].(bson.M), "version")
+		update["$inc"] = bson.M{"version": 1}
+	}
+
+	var written types.Record
+	_, err = c.Find(filter).Apply(mgo.Change{Update: update, ReturnNew: true}, &written)
+	if err == mgo.ErrNotFound {
+		if p.Version == nil {
+			return current, &types.RecordNotFoundError{ID: id, Message: "Record does not exist in the database."}
+		}
+		// Written by someone else between reading and patching
+		latest, err := db.Get(id)
+		if err != nil {
+			return current, err
+		}
+		return latest, &types.ConflictError{ID: id, Message: "Record has been changed since it was read.", Current: latest}
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"id":    id,
+			"error": err.Error(),
+		}).Error("Failed to patch record in the database.")
+		return current, err
+	}
+	patched = written
+
+	db.addRevision(session, types.ActionUpdated, current, patched)
+
+	return patched, nil
+}
+
+// patchUpdate builds the $set and $unset operations that write the patched
+// fields of the record. Values are taken from the patched record so they are
+// stored with the same types as a full update.
+func patchUpdate(paths []string, patched types.Record) bson.M {
+	changed := make(map[string]bool)
+	for _, path := range paths {
+		changed[path] = true
+	}
+
+	set := bson.M{"version": patched.Version}
+	unset := bson.M{}
+
+	for _, path := range paths {
+		segments := strings.SplitN(path, ".", 2)
+		if len(segments) == 2 && changed[segments[0]] {
+			// The whole parent is being written
+			continue
+		}
+
+		switch segments[0] {
+		case "title":
+			set["title"] = patched.Title
+		case "shortName":
+			set["shortname"] = patched.ShortName
+		case "facilities":
+			set["facilities"] = patched.Facilities
+		case "location":
+			if len(segments) == 1 {
+				set["location"] = patched.Location
+				continue
+			}
+			switch segments[1] {
+			case "lat":
+				set["location.lat"] = patched.Location.Lat
+			case "lng":
+				set["location.lng"] = patched.Location.Lng
+			case "country":
+				set["location.country"] = patched.Location.Country
+			}
+			if segments[1] != "country" {
+				set["location.type"] = patched.Location.Type
+				set["location.coordinates"] = patched.Location.Coordinates
+				set["location.countrycode"] = patched.Location.CountryCode
+				set["location.region"] = patched.Location.Region
+			}
+		case "details":
+			if len(segments) == 1 {
+				if patched.Details == nil {
+					unset["details"] = ""
+				} else {
+					set["details"] = patched.Details
+				}
+				continue
+			}
+			if value, ok := patched.Details[segments[1]]; ok {
+				set["details."+segments[1]] = value
+			} else {
+				unset["details."+segments[1]] = ""
+			}
+		}
+	}
+
+	if len(patched.DetailValues) > 0 {
+		set["detailvalues"] = patched.DetailValues
+	} else {
+		unset["detailvalues"] = ""
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// Deleted returns a page of the records that have been marked as deleted,
+// most recently deleted first, along with the total number deleted
+func (db *MongoDB) Deleted(page, pageSize int) ([]types.Record, int, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.Collection)
+
+	q := c.Find(bson.M{"deleted": true})
+
+	total, err := q.Count()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to count deleted records in the database.")
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+
+	var records []types.Record
+	err = q.Sort("-deletedat", "_id").Skip((page - 1) * pageSize).Limit(pageSize).All(&records)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get deleted records from the database.")
+		return nil, 0, err
+	}
+
+	return records, total, nil
+}
+
+// Restore brings back a record that has been marked as deleted
+func (db *MongoDB) Restore(id string) error {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.Collection)
+
+	err = c.Update(bson.M{"id": id, "deleted": true}, bson.M{
+		"$set":   bson.M{"deleted": false},
+		"$unset": bson.M{"deletedat": ""},
+		"$inc":   bson.M{"version": 1},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return &types.RecordNotFoundError{ID: id, Message: "Deleted record does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    id,
+			"error": err.Error(),
+		}).Error("Failed to restore record in the database.")
+		return err
+	}
+
+	restored, err := db.Get(id)
+	if err != nil {
+		return err
+	}
+	db.addRevision(session, types.ActionRestored, restored, restored)
+
+	return nil
+}
+
+// DeletedFields returns the fields that have been marked as deleted
+func (db *MongoDB) DeletedFields() ([]types.Field, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.FieldCollection)
+
+	var fields []types.Field
+	err = c.Find(bson.M{"deleted": true}).Sort("-deletedat").All(&fields)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get deleted fields from the database.")
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// RestoreField brings back a field that has been marked as deleted
+func (db *MongoDB) RestoreField(id string) error {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	c := session.DB("").C(db.FieldCollection)
+
+	err = c.Update(bson.M{"id": id, "deleted": true}, bson.M{
+		"$set":   bson.M{"deleted": false},
+		"$unset": bson.M{"deletedat": ""},
+		"$inc":   bson.M{"version": 1},
+	})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return &types.FieldNotFoundError{ID: id, Message: "Deleted field does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    id,
+			"error": err.Error(),
+		}).Error("Failed to restore field in the database.")
+		return err
+	}
+
+	return nil
+}
+
+// Purge permanently removes records, along with their notes and history, and
+// fields that were deleted before the given time, returning how many records
+// and fields were removed and the blob store keys of the files attached to
+// the records, which the caller has to delete. The keys are returned along
+// with any error once the records are removed, as nothing else refers to
+// their files. Items deleted before deletion times were recorded are never
+// purged.
+func (db *MongoDB) Purge(before time.Time) (int, int, []string, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	defer session.Close()
+
+	expired := bson.M{"deleted": true, "deletedat": bson.M{"$lt": before}}
+	c := session.DB("").C(db.Collection)
+
+	var found []types.Record
+	err = c.Find(expired).Select(bson.M{"id": 1, "attachments": 1}).All(&found)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to find deleted records to purge.")
+		return 0, 0, nil, err
+	}
+
+	workspace := db.Workspace
+	if workspace == "" {
+		workspace = types.DefaultWorkspace
+	}
+
+	// Each record is only removed if it's still expired, so one restored in
+	// the meantime is kept along with its notes, history and attachments
+	var ids, attachments []string
+	var removeErr error
+	for _, r := range found {
+		err := c.Remove(bson.M{"id": r.ID, "deleted": true, "deletedat": bson.M{"$lt": before}})
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"id":    r.ID,
+				"error": err.Error(),
+			}).Error("Failed to purge deleted record.")
+			removeErr = err
+			break
+		}
+		ids = append(ids, r.ID)
+		for _, a := range r.Attachments {
+			attachments = append(attachments, types.AttachmentKey(workspace, r.ID, a.ID))
+		}
+	}
+	removed := len(ids)
+
+	if len(ids) > 0 {
+		if _, err := session.DB("").C(db.NoteCollection).RemoveAll(bson.M{"recordid": bson.M{"$in": ids}}); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Failed to purge notes of deleted records.")
+			return removed, 0, attachments, err
+		}
+
+		if _, err := session.DB("").C(db.HistoryCollection).RemoveAll(bson.M{"recordid": bson.M{"$in": ids}}); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Failed to purge history of deleted records.")
+			return removed, 0, attachments, err
+		}
+	}
+
+	if removeErr != nil {
+		return removed, 0, attachments, removeErr
+	}
+
+	fields, err := session.DB("").C(db.FieldCollection).RemoveAll(expired)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to purge deleted fields.")
+		return removed, 0, attachments, err
+	}
+
+	return removed, fields.Removed, attachments, nil
+}
@@ -0,0 +1,314 @@
+package database
+
+import (
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+// Webhooks lists the webhooks of the workspace, oldest first
+func (db *MongoDB) Webhooks() ([]types.Webhook, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	webhooks := []types.Webhook{}
+	err = session.DB("").C(db.WebhookCollection).Find(nil).Sort("created", "_id").All(&webhooks)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get webhooks from the database.")
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// GetWebhook returns the webhook with the ID
+func (db *MongoDB) GetWebhook(id string) (types.Webhook, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return types.Webhook{}, err
+	}
+	defer session.Close()
+
+	var webhook types.Webhook
+	err = session.DB("").C(db.WebhookCollection).FindId(id).One(&webhook)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return webhook, &types.WebhookNotFoundError{ID: id, Message: "Webhook does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    id,
+			"error": err.Error(),
+		}).Error("Failed to get webhook from the database.")
+		return webhook, err
+	}
+
+	return webhook, nil
+}
+
+// CreateWebhook adds a webhook created by the actor of the database,
+// returning it as it was stored
+func (db *MongoDB) CreateWebhook(h types.Webhook) (types.Webhook, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return h, err
+	}
+	defer session.Close()
+
+	h.ID = bson.NewObjectId().Hex()
+	h.Created = time.Now().UTC()
+	h.CreatedBy = db.Actor
+	h.Updated = nil
+
+	if err := session.DB("").C(db.WebhookCollection).Insert(h); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to insert webhook into the database.")
+		return h, err
+	}
+
+	return h, nil
+}
+
+// UpdateWebhook changes the URL and events of a webhook, and its secret when
+// one is given, returning it as it now is
+func (db *MongoDB) UpdateWebhook(h types.Webhook) (types.Webhook, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return h, err
+	}
+	defer session.Close()
+
+	set := bson.M{"url": h.URL, "events": h.Events, "updated": time.Now().UTC()}
+	if h.Secret != "" {
+		set["secret"] = h.Secret
+	}
+
+	var updated types.Webhook
+	_, err = session.DB("").C(db.WebhookCollection).FindId(h.ID).Apply(mgo.Change{
+		Update:    bson.M{"$set": set},
+		ReturnNew: true,
+	}, &updated)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return h, &types.WebhookNotFoundError{ID: h.ID, Message: "Webhook does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    h.ID,
+			"error": err.Error(),
+		}).Error("Failed to update webhook in the database.")
+		return h, err
+	}
+
+	return updated, nil
+}
+
+// DeleteWebhook permanently removes a webhook along with its deliveries
+func (db *MongoDB) DeleteWebhook(id string) error {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	err = session.DB("").C(db.WebhookCollection).RemoveId(id)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return &types.WebhookNotFoundError{ID: id, Message: "Webhook does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    id,
+			"error": err.Error(),
+		}).Error("Failed to delete webhook from the database.")
+		return err
+	}
+
+	if _, err := session.DB("").C(db.DeliveryCollection).RemoveAll(bson.M{"webhookid": id}); err != nil {
+		log.WithFields(log.Fields{
+			"id":    id,
+			"error": err.Error(),
+		}).Error("Failed to delete deliveries of webhook from the database.")
+		return err
+	}
+
+	return nil
+}
+
+// Deliveries returns a page of the deliveries to a webhook, or to every
+// webhook when no ID is given, newest first along with the total. When a
+// status is given only deliveries in that state are returned.
+func (db *MongoDB) Deliveries(webhookID, status string, page, pageSize int) ([]types.Delivery, int, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer session.Close()
+
+	filter := bson.M{}
+	if webhookID != "" {
+		filter["webhookid"] = webhookID
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	q := session.DB("").C(db.DeliveryCollection).Find(filter)
+
+	total, err := q.Count()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to count deliveries in the database.")
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+
+	var deliveries []types.Delivery
+	err = q.Sort("-created", "_id").Skip((page - 1) * pageSize).Limit(pageSize).All(&deliveries)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get deliveries from the database.")
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+// AddDelivery queues a delivery to be sent straight away
+func (db *MongoDB) AddDelivery(d types.Delivery) (types.Delivery, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return d, err
+	}
+	defer session.Close()
+
+	now := time.Now().UTC()
+	d.ID = bson.NewObjectId().Hex()
+	d.Status = types.DeliveryPending
+	d.Attempts = []types.DeliveryAttempt{}
+	d.NextAttempt = &now
+	d.Created = now
+
+	if err := session.DB("").C(db.DeliveryCollection).Insert(d); err != nil {
+		log.WithFields(log.Fields{
+			"webhook": d.WebhookID,
+			"error":   err.Error(),
+		}).Error("Failed to insert delivery into the database.")
+		return d, err
+	}
+
+	return d, nil
+}
+
+// ClaimDelivery returns the pending delivery that has been due the longest,
+// putting its next attempt back by the lease so no one else sends it in the
+// meantime. If it isn't updated before the lease runs out it will be sent
+// again. DeliveryNotFoundError is returned when none are due.
+func (db *MongoDB) ClaimDelivery(lease time.Duration) (types.Delivery, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return types.Delivery{}, err
+	}
+	defer session.Close()
+
+	now := time.Now().UTC()
+
+	var delivery types.Delivery
+	_, err = session.DB("").C(db.DeliveryCollection).Find(bson.M{
+		"status":      types.DeliveryPending,
+		"nextattempt": bson.M{"$lte": now},
+	}).Sort("nextattempt").Apply(mgo.Change{
+		Update:    bson.M{"$set": bson.M{"nextattempt": now.Add(lease)}},
+		ReturnNew: true,
+	}, &delivery)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return delivery, &types.DeliveryNotFoundError{Message: "No deliveries are due."}
+		}
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to claim delivery in the database.")
+		return delivery, err
+	}
+
+	return delivery, nil
+}
+
+// UpdateDelivery stores the status, attempts, tries and next attempt of a
+// delivery
+func (db *MongoDB) UpdateDelivery(d types.Delivery) error {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	update := bson.M{"$set": bson.M{"status": d.Status, "attempts": d.Attempts, "tries": d.Tries}}
+	if d.NextAttempt == nil {
+		update["$unset"] = bson.M{"nextattempt": ""}
+	} else {
+		update["$set"].(bson.M)["nextattempt"] = d.NextAttempt
+	}
+
+	err = session.DB("").C(db.DeliveryCollection).UpdateId(d.ID, update)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return &types.DeliveryNotFoundError{ID: d.ID, Message: "Delivery does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    d.ID,
+			"error": err.Error(),
+		}).Error("Failed to update delivery in the database.")
+		return err
+	}
+
+	return nil
+}
+
+// RetryDelivery queues a dead delivery to a webhook to be sent again
+// straight away, keeping its earlier attempts but resetting its tries so it
+// gets as many again
+func (db *MongoDB) RetryDelivery(webhookID, id string) (types.Delivery, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return types.Delivery{}, err
+	}
+	defer session.Close()
+
+	var delivery types.Delivery
+	_, err = session.DB("").C(db.DeliveryCollection).Find(bson.M{
+		"_id":       id,
+		"webhookid": webhookID,
+		"status":    types.DeliveryDead,
+	}).Apply(mgo.Change{
+		Update: bson.M{"$set": bson.M{
+			"status":      types.DeliveryPending,
+			"tries":       0,
+			"nextattempt": time.Now().UTC(),
+		}},
+		ReturnNew: true,
+	}, &delivery)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return delivery, &types.DeliveryNotFoundError{ID: id, Message: "Dead delivery does not exist in the database."}
+		}
+		log.WithFields(log.Fields{
+			"id":    id,
+			"error": err.Error(),
+		}).Error("Failed to retry delivery in the database.")
+		return delivery, err
+	}
+
+	return delivery, nil
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/globalsign/mgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// In returns a copy of the database that reads and writes the records, fields,
+// history, notes and webhooks of the workspace
+func (db *MongoDB) In(workspace string) Database {
+	return db.Scoped(workspace)
+}
+
+// Scoped returns a copy of the database using the collections of the
+// workspace. The default workspace uses the collections as configured, others
+// prefix them with the name of the workspace, e.g. team-a.records.
+func (db *MongoDB) Scoped(workspace string) *MongoDB {
+	scoped := *db
+	scoped.Workspace = workspace
+	scoped.Collection = workspaceCollection(db.Workspace, workspace, db.Collection)
+	scoped.FieldCollection = workspaceCollection(db.Workspace, workspace, db.FieldCollection)
+	scoped.HistoryCollection = workspaceCollection(db.Workspace, workspace, db.HistoryCollection)
+	scoped.NoteCollection = workspaceCollection(db.Workspace, workspace, db.NoteCollection)
+	scoped.WebhookCollection = workspaceCollection(db.Workspace, workspace, db.WebhookCollection)
+	scoped.DeliveryCollection = workspaceCollection(db.Workspace, workspace, db.DeliveryCollection)
+	return &scoped
+}
+
+// workspaceCollection moves the name of a collection from one workspace to
+// another
+func workspaceCollection(from, to, collection string) string {
+	if from != "" && from != types.DefaultWorkspace {
+		collection = strings.TrimPrefix(collection, from+".")
+	}
+	if to == "" || to == types.DefaultWorkspace {
+		return collection
+	}
+	return to + "." + collection
+}
+
+func defaultWorkspace() types.Workspace {
+	return types.Workspace{Name: types.DefaultWorkspace, Title: "Default"}
+}
+
+// Workspaces lists every workspace ordered by name, the default workspace
+// first
+func (db *MongoDB) Workspaces() ([]types.Workspace, error) {
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	var workspaces []types.Workspace
+	err = session.DB("").C(db.WorkspaceCollection).Find(nil).Sort("_id").All(&workspaces)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to list workspaces.")
+		return nil, err
+	}
+
+	return append([]types.Workspace{defaultWorkspace()}, workspaces...), nil
+}
+
+// GetWorkspace returns the workspace with the name
+func (db *MongoDB) GetWorkspace(name string) (types.Workspace, error) {
+	if name == types.DefaultWorkspace {
+		return defaultWorkspace(), nil
+	}
+
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return types.Workspace{}, err
+	}
+	defer session.Close()
+
+	var workspace types.Workspace
+	err = session.DB("").C(db.WorkspaceCollection).FindId(name).One(&workspace)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return types.Workspace{}, &types.WorkspaceNotFoundError{Name: name, Message: "Workspace does not exist."}
+		}
+		log.WithFields(log.Fields{
+			"workspace": name,
+			"error":     err.Error(),
+		}).Error("Failed to get workspace.")
+		return types.Workspace{}, err
+	}
+
+	return workspace, nil
+}
+
+// CreateWorkspace adds a workspace and creates the indexes of its
+// collections, returning it as it was stored
+func (db *MongoDB) CreateWorkspace(w types.Workspace) (types.Workspace, error) {
+	if w.Name == types.DefaultWorkspace {
+		return types.Workspace{}, &types.WorkspaceExistsError{Name: w.Name, Message: "Workspace already exists."}
+	}
+
+	session, err := GetSession(db.URL)
+	if err != nil {
+		return types.Workspace{}, err
+	}
+	defer session.Close()
+
+	w.Created = time.Now().UTC()
+	w.CreatedBy = db.Actor
+
+	if err := session.DB("").C(db.WorkspaceCollection).Insert(w); err != nil {
+		if mgo.IsDup(err) {
+			return types.Workspace{}, &types.WorkspaceExistsError{Name: w.Name, Message: "Workspace already exists."}
+		}
+		log.WithFields(log.Fields{
+			"workspace": w.Name,
+			"error":     err.Error(),
+		}).Error("Failed to create workspace.")
+		return types.Workspace{}, err
+	}
+
+	if err := db.Scoped(w.Name).EnsureIndexes(); err != nil {
+		return types.Workspace{}, err
+	}
+
+	return w, nil
+}
@@ -0,0 +1,167 @@
+// Package events tells clients about changes to records and fields as they
+// happen, so maps can update without being reloaded.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+// Types of event
+const (
+	RecordCreated  = "record.created"
+	RecordUpdated  = "record.updated"
+	RecordDeleted  = "record.deleted"
+	RecordRestored = "record.restored"
+	FieldsUpdated  = "field.updated"
+	FieldDeleted   = "field.deleted"
+	FieldRestored  = "field.restored"
+)
+
+// Types lists every type of event
+var Types = []string{
+	RecordCreated, RecordUpdated, RecordDeleted, RecordRestored,
+	FieldsUpdated, FieldDeleted, FieldRestored,
+}
+
+// ValidType reports whether there are events of the type
+func ValidType(eventType string) bool {
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event describes a change to a record or field
+type Event struct {
+	// Seq numbers the events in the order they were published
+	Seq       uint64    `json:"seq"`
+	Type      string    `json:"type"`
+	Workspace string    `json:"workspace"`
+	ID        string    `json:"id,omitempty"`
+	Actor     string    `json:"actor"`
+	Time      time.Time `json:"time"`
+
+	// Record is the record as it is after the change, when it is known
+	Record *types.Record `json:"record,omitempty"`
+
+	// Previous is the record before the change, it is only used to tell
+	// clients watching where the record was that it has moved away
+	Previous *types.Record `json:"-"`
+}
+
+// BufferSize is how many events a subscriber can fall behind by before it is
+// dropped
+const BufferSize = 64
+
+// Subscription receives the events matching its filter until it's closed
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	filter func(Event) bool
+	broker *Broker
+}
+
+// Close stops the subscription receiving events
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+// Broker passes published events on to their subscribers
+type Broker struct {
+	mu          sync.Mutex
+	seq         uint64
+	subscribers map[*Subscription]bool
+}
+
+// NewBroker returns a broker without any subscribers
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[*Subscription]bool)}
+}
+
+// Subscribe returns a subscription to the events the filter matches
+func (b *Broker) Subscribe(filter func(Event) bool) *Subscription {
+	c := make(chan Event, BufferSize)
+	s := &Subscription{C: c, c: c, filter: filter, broker: b}
+
+	b.mu.Lock()
+	b.subscribers[s] = true
+	b.mu.Unlock()
+
+	return s
+}
+
+func (b *Broker) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[s] {
+		delete(b.subscribers, s)
+		close(s.c)
+	}
+}
+
+// Publish numbers the event and passes it to every subscriber it matches.
+// Publishing never blocks, subscribers that have fallen too far behind are
+// closed so they can reconnect and reload.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.Seq = b.seq
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	for s := range b.subscribers {
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+
+		select {
+		case s.c <- e:
+		default:
+			delete(b.subscribers, s)
+			close(s.c)
+		}
+	}
+}
+
+// Subscribers returns how many subscriptions are open
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Within returns a filter matching the events of the workspace. When bounds
+// are given records must be inside them before or after the change, events
+// without a location, such as deletes and field changes, always match.
+func Within(workspace string, bounds *types.SearchQuery) func(Event) bool {
+	inside := func(r *types.Record) bool {
+		if r == nil || r.Location.Lat < bounds.MinLat || r.Location.Lat > bounds.MaxLat {
+			return false
+		}
+		if bounds.MinLng > bounds.MaxLng {
+			// The bounds cross the antimeridian
+			return r.Location.Lng >= bounds.MinLng || r.Location.Lng <= bounds.MaxLng
+		}
+		return r.Location.Lng >= bounds.MinLng && r.Location.Lng <= bounds.MaxLng
+	}
+
+	return func(e Event) bool {
+		if e.Workspace != workspace {
+			return false
+		}
+		if bounds == nil || (e.Record == nil && e.Previous == nil) {
+			return true
+		}
+		return inside(e.Record) || inside(e.Previous)
+	}
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+func recordAt(lat, lng float64) *types.Record {
+	r := &types.Record{ID: "12345"}
+	r.Location.Lat = lat
+	r.Location.Lng = lng
+	return r
+}
+
+func TestWithinMatchesRecordsInsideTheBounds(t *testing.T) {
+	leeds := &types.SearchQuery{MinLat: 53.63, MaxLat: 53.84, MinLng: -1.97, MaxLng: -1.11}
+	filter := Within(types.DefaultWorkspace, leeds)
+
+	tests := []struct {
+		name  string
+		event Event
+		want  bool
+	}{
+		{"inside", Event{Workspace: types.DefaultWorkspace, Record: recordAt(53.8, -1.5)}, true},
+		{"outside", Event{Workspace: types.DefaultWorkspace, Record: recordAt(51.5, -0.1)}, false},
+		{"moved out", Event{Workspace: types.DefaultWorkspace, Record: recordAt(51.5, -0.1), Previous: recordAt(53.8, -1.5)}, true},
+		{"without a location", Event{Workspace: types.DefaultWorkspace, Type: FieldDeleted}, true},
+		{"another workspace", Event{Workspace: "team-a", Record: recordAt(53.8, -1.5)}, false},
+	}
+
+	for _, test := range tests {
+		if got := filter(test.event); got != test.want {
+			t.Errorf("%s: expected %t but got %t", test.name, test.want, got)
+		}
+	}
+}
+
+func TestWithinHandlesBoundsCrossingTheAntimeridian(t *testing.T) {
+	filter := Within(types.DefaultWorkspace, &types.SearchQuery{MinLat: -50, MaxLat: -10, MinLng: 170, MaxLng: -170})
+
+	if !filter(Event{Workspace: types.DefaultWorkspace, Record: recordAt(-17.7, 178.1)}) {
+		t.Error("Expected a record east of the antimeridian to match")
+	}
+	if !filter(Event{Workspace: types.DefaultWorkspace, Record: recordAt(-21.1, -175.2)}) {
+		t.Error("Expected a record west of the antimeridian to match")
+	}
+	if filter(Event{Workspace: types.DefaultWorkspace, Record: recordAt(-17.7, 0)}) {
+		t.Error("Expected a record away from the antimeridian not to match")
+	}
+}
+
+func TestPublishDropsSubscribersThatFallBehind(t *testing.T) {
+	broker := NewBroker()
+	slow := broker.Subscribe(nil)
+	fast := broker.Subscribe(nil)
+
+	for i := 0; i <= BufferSize; i++ {
+		broker.Publish(Event{Type: RecordUpdated})
+		<-fast.C
+	}
+
+	if broker.Subscribers() != 1 {
+		t.Fatalf("Expected only the slow subscriber to be dropped but %d are left", broker.Subscribers())
+	}
+
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != BufferSize {
+		t.Errorf("Expected the buffered events to be received before the subscription closed but got %d", received)
+	}
+
+	fast.Close()
+	if _, open := <-fast.C; open {
+		t.Error("Expected the subscription to be closed")
+	}
+}
+
+func TestPublishingPublishesChangesInTheWorkspace(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.Subscribe(Within("team-a", nil))
+	defer sub.Close()
+
+	db := Publishing(&database.FakeDB{}, broker).In("team-a").As("sam")
+
+	if err := db.Delete("12345"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteField("67890"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Import([]types.Record{{Title: "Airfield"}}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	e := <-sub.C
+	if e.Type != RecordDeleted || e.ID != "12345" || e.Actor != "sam" || e.Workspace != "team-a" {
+		t.Errorf("Expected the record deleted by sam in team-a but got %+v", e)
+	}
+	e = <-sub.C
+	if e.Type != FieldDeleted || e.ID != "67890" {
+		t.Errorf("Expected the field to be deleted but got %+v", e)
+	}
+
+	select {
+	case e := <-sub.C:
+		t.Errorf("Expected nothing to be published for a dry run but got %+v", e)
+	default:
+	}
+}
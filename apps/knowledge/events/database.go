@@ -0,0 +1,163 @@
+package events
+
+import (
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+// publisher publishes an event for every change made through the database it
+// wraps
+type publisher struct {
+	database.Database
+	broker    *Broker
+	workspace string
+	actor     string
+}
+
+// Publishing wraps the database so every change to a record or field made
+// through it, or a copy of it scoped by In or As, is published to the broker
+func Publishing(db database.Database, broker *Broker) database.Database {
+	return &publisher{Database: db, broker: broker, workspace: types.DefaultWorkspace}
+}
+
+func (p *publisher) As(actor string) database.Database {
+	return &publisher{Database: p.Database.As(actor), broker: p.broker, workspace: p.workspace, actor: actor}
+}
+
+func (p *publisher) In(workspace string) database.Database {
+	return &publisher{Database: p.Database.In(workspace), broker: p.broker, workspace: workspace, actor: p.actor}
+}
+
+func (p *publisher) publish(eventType, id string, record, previous *types.Record) {
+	p.broker.Publish(Event{
+		Type:      eventType,
+		Workspace: p.workspace,
+		ID:        id,
+		Actor:     p.actor,
+		Record:    record,
+		Previous:  previous,
+	})
+}
+
+// get returns the record as it is now, or nil if it can't be read
+func (p *publisher) get(id string) *types.Record {
+	record, err := p.Database.Get(id)
+	if err != nil {
+		return nil
+	}
+	return &record
+}
+
+func (p *publisher) Create(r types.Record) (string, error) {
+	id, err := p.Database.Create(r)
+	if err == nil {
+		p.publish(RecordCreated, id, p.get(id), nil)
+	}
+	return id, err
+}
+
+func (p *publisher) Update(id string, r types.Record) error {
+	previous := p.get(id)
+	err := p.Database.Update(id, r)
+	if err == nil {
+		p.publish(RecordUpdated, id, p.get(id), previous)
+	}
+	return err
+}
+
+func (p *publisher) Patch(id string, patch types.Patch) (types.Record, error) {
+	previous := p.get(id)
+	record, err := p.Database.Patch(id, patch)
+	if err == nil {
+		p.publish(RecordUpdated, id, &record, previous)
+	}
+	return record, err
+}
+
+func (p *publisher) Delete(id string) error {
+	previous := p.get(id)
+	err := p.Database.Delete(id)
+	if err == nil {
+		p.publish(RecordDeleted, id, nil, previous)
+	}
+	return err
+}
+
+func (p *publisher) Restore(id string) error {
+	err := p.Database.Restore(id)
+	if err == nil {
+		p.publish(RecordRestored, id, p.get(id), nil)
+	}
+	return err
+}
+
+// Import publishes the IDs of the records created without reading each of
+// them back
+func (p *publisher) Import(records []types.Record, dryRun bool) ([]types.ImportRow, error) {
+	rows, err := p.Database.Import(records, dryRun)
+	if err == nil && !dryRun {
+		for _, row := range rows {
+			if row.Status == types.ImportCreated && row.ID != "" {
+				p.publish(RecordCreated, row.ID, nil, nil)
+			}
+		}
+	}
+	return rows, err
+}
+
+// Bulk publishes the IDs of the records changed without reading each of them
+// back
+func (p *publisher) Bulk(op types.BulkOperation) ([]types.BulkResult, error) {
+	results, err := p.Database.Bulk(op)
+	if err == nil {
+		for _, result := range results {
+			switch result.Status {
+			case types.BulkUpdated:
+				p.publish(RecordUpdated, result.ID, nil, nil)
+			case types.BulkDeleted:
+				p.publish(RecordDeleted, result.ID, nil, nil)
+			}
+		}
+	}
+	return results, err
+}
+
+func (p *publisher) AddAttachment(id string, a types.Attachment) (types.Attachment, error) {
+	attachment, err := p.Database.AddAttachment(id, a)
+	if err == nil {
+		p.publish(RecordUpdated, id, p.get(id), nil)
+	}
+	return attachment, err
+}
+
+func (p *publisher) DeleteAttachment(id, attachmentID string) (types.Attachment, error) {
+	attachment, err := p.Database.DeleteAttachment(id, attachmentID)
+	if err == nil {
+		p.publish(RecordUpdated, id, p.get(id), nil)
+	}
+	return attachment, err
+}
+
+func (p *publisher) UpdateFields(fields []types.Field) error {
+	err := p.Database.UpdateFields(fields)
+	if err == nil {
+		p.publish(FieldsUpdated, "", nil, nil)
+	}
+	return err
+}
+
+func (p *publisher) DeleteField(id string) error {
+	err := p.Database.DeleteField(id)
+	if err == nil {
+		p.publish(FieldDeleted, id, nil, nil)
+	}
+	return err
+}
+
+func (p *publisher) RestoreField(id string) error {
+	err := p.Database.RestoreField(id)
+	if err == nil {
+		p.publish(FieldRestored, id, nil, nil)
+	}
+	return err
+}
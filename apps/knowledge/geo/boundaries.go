@@ -0,0 +1,192 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultBoundaries is where the boundaries bundled with the service are
+// read from, relative to where it's run. They're a coarse sample only used
+// when asked for.
+const DefaultBoundaries = "geo/boundaries.geojson"
+
+// boundary is the outline of a place, along with the box around it so most
+// places can be ruled out without checking their polygons
+type boundary struct {
+	place    Place
+	polygons [][][][]float64
+
+	minLat, maxLat, minLng, maxLng float64
+}
+
+func (b boundary) contains(lat, lng float64) bool {
+	if lat < b.minLat || lat > b.maxLat || lng < b.minLng || lng > b.maxLng {
+		return false
+	}
+	for _, polygon := range b.polygons {
+		if inPolygon(polygon, lat, lng) {
+			return true
+		}
+	}
+	return false
+}
+
+// inPolygon reports whether the point is inside the polygon, its first ring
+// being the outline and any others holes in it. A ray is cast from the point
+// and the edges it crosses counted, an odd number meaning it's inside.
+func inPolygon(rings [][][]float64, lat, lng float64) bool {
+	inside := false
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			xi, yi := ring[i][0], ring[i][1]
+			xj, yj := ring[j][0], ring[j][1]
+			if (yi > lat) != (yj > lat) && lng < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// Boundaries is an offline geocoder, finding places from their outlines
+type Boundaries struct {
+	boundaries []boundary
+}
+
+// LoadBoundaries reads the boundaries from a GeoJSON file, see
+// ParseBoundaries
+func LoadBoundaries(path string) (*Boundaries, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseBoundaries(f)
+}
+
+// ParseBoundaries reads the Polygon and MultiPolygon features of a GeoJSON
+// FeatureCollection as the boundaries of places. The properties of each
+// feature follow Natural Earth, iso_a2 holding the country code and admin the
+// name of the country. The region is taken from a region property or, for
+// admin 1 features with an adm1_code, their name.
+func ParseBoundaries(r io.Reader) (*Boundaries, error) {
+	var collection struct {
+		Features []struct {
+			Properties map[string]interface{} `json:"properties"`
+			Geometry   *struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("Unable to parse boundaries: %s", err.Error())
+	}
+
+	b := &Boundaries{}
+	for i, feature := range collection.Features {
+		if feature.Geometry == nil {
+			continue
+		}
+
+		var polygons [][][][]float64
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var polygon [][][]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygon); err != nil {
+				return nil, fmt.Errorf("Unable to parse boundary %d: %s", i, err.Error())
+			}
+			polygons = [][][][]float64{polygon}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygons); err != nil {
+				return nil, fmt.Errorf("Unable to parse boundary %d: %s", i, err.Error())
+			}
+		default:
+			continue
+		}
+
+		place := placeOf(feature.Properties)
+		if place.CountryCode == "" {
+			return nil, fmt.Errorf("Boundary %d has no iso_a2 country code", i)
+		}
+		b.boundaries = append(b.boundaries, newBoundary(place, polygons))
+	}
+
+	if len(b.boundaries) == 0 {
+		return nil, fmt.Errorf("No boundaries found")
+	}
+	return b, nil
+}
+
+func newBoundary(place Place, polygons [][][][]float64) boundary {
+	b := boundary{place: place, polygons: polygons, minLat: 90, maxLat: -90, minLng: 180, maxLng: -180}
+	for _, polygon := range polygons {
+		for _, ring := range polygon {
+			for _, point := range ring {
+				if len(point) < 2 {
+					continue
+				}
+				if point[0] < b.minLng {
+					b.minLng = point[0]
+				}
+				if point[0] > b.maxLng {
+					b.maxLng = point[0]
+				}
+				if point[1] < b.minLat {
+					b.minLat = point[1]
+				}
+				if point[1] > b.maxLat {
+					b.maxLat = point[1]
+				}
+			}
+		}
+	}
+	return b
+}
+
+// placeOf reads the place from the properties of a feature
+func placeOf(properties map[string]interface{}) Place {
+	text := func(keys ...string) string {
+		for _, key := range keys {
+			if value, ok := properties[key].(string); ok && value != "" && value != "-99" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	place := Place{
+		CountryCode: strings.ToUpper(text("iso_a2", "ISO_A2", "iso_a2_eh", "ISO_A2_EH")),
+		Country:     text("admin", "ADMIN", "country"),
+		Region:      text("region"),
+	}
+	if place.Region == "" && text("adm1_code") != "" {
+		place.Region = text("name")
+	}
+	return place
+}
+
+// Reverse returns the place the location is in. When boundaries overlap, a
+// region is preferred over a whole country.
+func (b *Boundaries) Reverse(lat, lng float64) (Place, error) {
+	var country *Place
+	for i := range b.boundaries {
+		if !b.boundaries[i].contains(lat, lng) {
+			continue
+		}
+		if b.boundaries[i].place.Region != "" {
+			return b.boundaries[i].place, nil
+		}
+		if country == nil {
+			country = &b.boundaries[i].place
+		}
+	}
+
+	if country == nil {
+		return Place{}, ErrNoPlace
+	}
+	return *country, nil
+}
@@ -0,0 +1,90 @@
+package geo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBundledBoundariesPlaceTheSeedLocations(t *testing.T) {
+	b, err := LoadBoundaries("boundaries.geojson")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		lat, lng    float64
+		countryCode string
+		region      string
+	}{
+		{"London Gatwick", 51.1513, -0.1811, "GB", "England"},
+		{"Manchester Airport", 53.3646, -2.2730, "GB", "England"},
+		{"Glasgow Airport", 55.8647, -4.4322, "GB", "Scotland"},
+		{"Edinburgh Airport", 55.9483, -3.3636, "GB", "Scotland"},
+		{"Cardiff Airport", 51.3967, -3.3433, "GB", "Wales"},
+		{"Belfast International", 54.6575, -6.2158, "GB", "Northern Ireland"},
+		{"Dublin Airport", 53.4213, -6.2700, "IE", ""},
+		{"Charles de Gaulle", 49.0043, 2.5697, "FR", ""},
+		{"Barcelona–El Prat Airport", 41.2971, 2.0777, "ES", ""},
+		{"Málaga–Costa del Sol Airport", 36.6757, -4.4892, "ES", ""},
+		{"Bilbao Airport", 43.3040, -2.9062, "ES", ""},
+		{"Lisbon Airport", 38.7742, -9.1342, "PT", ""},
+		{"Malpensa Airport", 45.6272, 8.7137, "IT", ""},
+		{"Leonardo da Vinci–Fiumicino Airport", 41.7933, 12.2518, "IT", ""},
+		{"Turin Airport", 45.1948, 7.6461, "IT", ""},
+		{"Frankfurt Airport", 50.0443, 8.5518, "DE", ""},
+		{"Berlin Schönefeld", 52.3643, 13.5113, "DE", ""},
+	}
+
+	for _, test := range tests {
+		place, err := b.Reverse(test.lat, test.lng)
+		if err != nil {
+			t.Errorf("%s: expected to be placed but got %s", test.name, err.Error())
+			continue
+		}
+		if place.CountryCode != test.countryCode || place.Region != test.region {
+			t.Errorf("%s: expected %s %q but got %s %q", test.name, test.countryCode, test.region, place.CountryCode, place.Region)
+		}
+	}
+
+	if _, err := b.Reverse(47.0, -10.0); err != ErrNoPlace {
+		t.Errorf("Expected a location in the Atlantic not to be placed but got %v", err)
+	}
+}
+
+func TestParseBoundariesReadsNaturalEarthProperties(t *testing.T) {
+	b, err := ParseBoundaries(strings.NewReader(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{"ISO_A2":"-99","ISO_A2_EH":"NO","ADMIN":"Norway"},
+			"geometry":{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]],[[4,4],[6,4],[6,6],[4,6],[4,4]]]}},
+		{"type":"Feature","properties":{"iso_a2":"NO","admin":"Norway","adm1_code":"NOR-1","name":"Oslo"},
+			"geometry":{"type":"Polygon","coordinates":[[[1,1],[3,1],[3,3],[1,3],[1,1]]]}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if place, _ := b.Reverse(2, 2); place.CountryCode != "NO" || place.Region != "Oslo" {
+		t.Errorf("Expected the region to be preferred over the country but got %+v", place)
+	}
+	if place, _ := b.Reverse(8, 8); place.Country != "Norway" || place.Region != "" {
+		t.Errorf("Expected the country but got %+v", place)
+	}
+	if _, err := b.Reverse(5, 5); err != ErrNoPlace {
+		t.Errorf("Expected a location in a hole not to be placed but got %v", err)
+	}
+}
+
+func TestNewGeocoderIsDisabledUntilBoundariesAreGiven(t *testing.T) {
+	if g, err := NewGeocoder("", ""); g != nil || err != nil {
+		t.Errorf("Expected no geocoder without boundaries but got %v, %v", g, err)
+	}
+	if g, err := NewGeocoder("", "boundaries.geojson"); g == nil || err != nil {
+		t.Errorf("Expected the boundaries to be used but got %v", err)
+	}
+	if _, err := NewGeocoder("", "missing.geojson"); err == nil {
+		t.Error("Expected missing boundaries to be an error")
+	}
+	if g, err := NewGeocoder("none", "boundaries.geojson"); g != nil || err != nil {
+		t.Errorf("Expected none to disable geocoding but got %v, %v", g, err)
+	}
+}
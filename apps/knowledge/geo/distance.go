@@ -0,0 +1,31 @@
+// Package geo provides the geographic calculations used when searching and
+// presenting records.
+package geo
+
+import "math"
+
+// EarthRadius is the radius of the earth in metres, matching the value Mongo
+// uses for spherical queries
+const EarthRadius = 6378100.0
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Distance returns the great circle distance in metres between two points
+// given in degrees
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLng := radians(lng2 - lng1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * EarthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Radians converts a distance in metres along the surface of the earth into
+// the angle it covers, as expected by $centerSphere
+func Radians(metres float64) float64 {
+	return metres / EarthRadius
+}
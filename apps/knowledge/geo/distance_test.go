@@ -0,0 +1,26 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceBetweenSamePointIsZero(t *testing.T) {
+	if d := Distance(53.8, -1.5, 53.8, -1.5); d != 0 {
+		t.Errorf("Expected 0 but got %v", d)
+	}
+}
+
+func TestDistanceLeedsToLondon(t *testing.T) {
+	// Leeds to London is roughly 272km
+	d := Distance(53.8008, -1.5491, 51.5074, -0.1278)
+	if math.Abs(d-272000) > 2000 {
+		t.Errorf("Expected about 272000m but got %v", d)
+	}
+}
+
+func TestRadiansOfEarthRadiusIsOne(t *testing.T) {
+	if r := Radians(EarthRadius); r != 1 {
+		t.Errorf("Expected 1 but got %v", r)
+	}
+}
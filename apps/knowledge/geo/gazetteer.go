@@ -0,0 +1,246 @@
+package geo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultGazetteer is where the gazetteer bundled with the service is read
+// from, relative to where it's run
+const DefaultGazetteer = "geo/gazetteer.csv"
+
+// Match is a place found for an address
+type Match struct {
+	Name        string  `json:"name"`
+	Lat         float64 `json:"lat"`
+	Lng         float64 `json:"lng"`
+	CountryCode string  `json:"countryCode,omitempty"`
+	Region      string  `json:"region,omitempty"`
+
+	// Score is how much of the address the match accounts for, from 0 to 1
+	Score float64 `json:"score"`
+}
+
+// ForwardGeocoder finds the places an address could be, best first
+type ForwardGeocoder interface {
+	Forward(address string, limit int) ([]Match, error)
+}
+
+// NewForwardGeocoder returns the forward geocoder of the kind, either
+// offline, which finds places in the gazetteer read from the file, or none,
+// which returns nil so addresses can't be looked up
+func NewForwardGeocoder(kind, gazetteer string) (ForwardGeocoder, error) {
+	switch kind {
+	case "", "offline":
+		g, err := LoadGazetteer(gazetteer)
+		if err != nil {
+			return nil, err
+		}
+		return g, nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Unknown geocoder %s, expected offline or none", kind)
+	}
+}
+
+// maxPhrase is the most words of an address looked up as one name
+const maxPhrase = 6
+
+// maxCompletions is the most names starting with an address that are looked
+// at, so short addresses don't score the whole gazetteer
+const maxCompletions = 1000
+
+// Gazetteer is an offline forward geocoder, finding places by name from a
+// list of them such as towns or postcodes
+type Gazetteer struct {
+	// places are kept by their key, the name in lower case without spaces or
+	// punctuation, so "LS1 4AP" and "ls14ap" are the same
+	places map[string][]Match
+
+	// keys are sorted so names starting with an address can be found
+	keys []string
+}
+
+// unaccented replaces the accented letters common in European place names
+// with the letters they're typed as without them
+var unaccented = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a",
+	"ç", "c", "è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ý", "y", "ÿ", "y", "ß", "ss",
+)
+
+// key normalises a name for looking it up
+func key(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return r
+		}
+		return -1
+	}, unaccented.Replace(strings.ToLower(name)))
+}
+
+// words splits an address into its words
+func words(address string) []string {
+	return strings.FieldsFunc(address, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// LoadGazetteer reads the gazetteer from a CSV file, see ParseGazetteer
+func LoadGazetteer(path string) (*Gazetteer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseGazetteer(f)
+}
+
+// gazetteerColumns are the headers each column can be given by, covering
+// GeoNames style names and the ONS Postcode Directory
+var gazetteerColumns = map[string][]string{
+	"name":        {"name", "postcode", "pcds", "pcd"},
+	"lat":         {"lat", "latitude"},
+	"lng":         {"lng", "lon", "long", "longitude"},
+	"countryCode": {"countrycode", "country_code", "iso_a2"},
+	"region":      {"region", "admin1"},
+}
+
+// ParseGazetteer reads places from CSV with a header row naming the name,
+// lat and lng columns, and optionally the countryCode and region columns.
+// Rows without a name or with an invalid location are skipped.
+func ParseGazetteer(r io.Reader) (*Gazetteer, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read gazetteer header: %s", err.Error())
+	}
+
+	columns := make(map[string]int)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		for column, names := range gazetteerColumns {
+			for _, name := range names {
+				if _, found := columns[column]; !found && h == name {
+					columns[column] = i
+				}
+			}
+		}
+	}
+	for _, required := range []string{"name", "lat", "lng"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("Gazetteer has no %s column", required)
+		}
+	}
+
+	get := func(row []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	g := &Gazetteer{places: make(map[string][]Match)}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read gazetteer: %s", err.Error())
+		}
+
+		lat, latErr := strconv.ParseFloat(get(row, "lat"), 64)
+		lng, lngErr := strconv.ParseFloat(get(row, "lng"), 64)
+		name := get(row, "name")
+		k := key(name)
+		if k == "" || latErr != nil || lngErr != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			continue
+		}
+
+		if _, seen := g.places[k]; !seen {
+			g.keys = append(g.keys, k)
+		}
+		g.places[k] = append(g.places[k], Match{
+			Name:        name,
+			Lat:         lat,
+			Lng:         lng,
+			CountryCode: strings.ToUpper(get(row, "countryCode")),
+			Region:      get(row, "region"),
+		})
+	}
+	sort.Strings(g.keys)
+
+	if len(g.keys) == 0 {
+		return nil, fmt.Errorf("No places found in gazetteer")
+	}
+	return g, nil
+}
+
+// Forward returns the places in the gazetteer the address could be, best
+// first. Places named by the whole address score 1, then those named by part
+// of it, such as the postcode or town of a full address, score by how much of
+// it they cover. When nothing is named by the address, places whose name
+// starts with it are returned so it can be completed as it's typed.
+func (g *Gazetteer) Forward(address string, limit int) ([]Match, error) {
+	full := key(address)
+	if full == "" {
+		return []Match{}, nil
+	}
+
+	scores := make(map[string]float64)
+	ws := words(address)
+	for start := range ws {
+		for end := start + 1; end <= len(ws) && end-start <= maxPhrase; end++ {
+			k := key(strings.Join(ws[start:end], ""))
+			if _, ok := g.places[k]; ok {
+				score := float64(len(k)) / float64(len(full))
+				if score > scores[k] {
+					scores[k] = score
+				}
+			}
+		}
+	}
+	if _, ok := g.places[full]; ok {
+		scores[full] = 1
+	}
+
+	if len(scores) == 0 {
+		i := sort.SearchStrings(g.keys, full)
+		for end := i + maxCompletions; i < len(g.keys) && i < end && strings.HasPrefix(g.keys[i], full); i++ {
+			scores[g.keys[i]] = 0.5 * float64(len(full)) / float64(len(g.keys[i]))
+		}
+	}
+
+	matches := []Match{}
+	for k, score := range scores {
+		for _, m := range g.places[k] {
+			m.Score = score
+			matches = append(matches, m)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Name < matches[j].Name
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
@@ -0,0 +1,88 @@
+package geo
+
+import (
+	"strings"
+	"testing"
+)
+
+const postcodes = "\ufeffpcds,lat,long,country_code,admin1\n" +
+	"LS1 4AP,53.7960,-1.5479,gb,England\n" +
+	"LS1 4DY,53.7995,-1.5451,gb,England\n" +
+	"EH12 9DN,55.9483,-3.3636,gb,Scotland\n" +
+	"XX1 1XX,,,gb,England\n"
+
+func TestParseGazetteerReadsONSHeadersAndSkipsInvalidRows(t *testing.T) {
+	g, err := ParseGazetteer(strings.NewReader(postcodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.keys) != 3 {
+		t.Errorf("Expected 3 places but got %d", len(g.keys))
+	}
+
+	if _, err := ParseGazetteer(strings.NewReader("town,x,y\nLeeds,53.8,-1.5\n")); err == nil {
+		t.Error("Expected a gazetteer without name, lat and lng columns to be rejected")
+	}
+}
+
+func TestForwardFindsPlacesInAddresses(t *testing.T) {
+	g, err := ParseGazetteer(strings.NewReader(postcodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		address string
+		name    string
+		score   float64
+	}{
+		{"LS1 4AP", "LS1 4AP", 1},
+		{"ls14ap", "LS1 4AP", 1},
+		{"Leeds Station, New Station Street, Leeds LS1 4DY", "LS1 4DY", 6.0 / 39.0},
+		{"eh12", "EH12 9DN", 0.5 * 4 / 7},
+	}
+
+	for _, test := range tests {
+		matches, err := g.Forward(test.address, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) == 0 || matches[0].Name != test.name {
+			t.Errorf("%s: expected %s but got %v", test.address, test.name, matches)
+			continue
+		}
+		if matches[0].Score != test.score || matches[0].CountryCode != "GB" {
+			t.Errorf("%s: expected a score of %f in GB but got %f in %s", test.address, test.score, matches[0].Score, matches[0].CountryCode)
+		}
+	}
+
+	if matches, _ := g.Forward("LS1", 1); len(matches) != 1 {
+		t.Errorf("Expected the completions of LS1 to be limited to 1 but got %d", len(matches))
+	}
+	if matches, _ := g.Forward("Atlantis", 10); len(matches) != 0 {
+		t.Errorf("Expected no matches but got %v", matches)
+	}
+}
+
+func TestBundledGazetteerFindsTownsAndPostcodes(t *testing.T) {
+	g, err := LoadGazetteer("gazetteer.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for address, countryCode := range map[string]string{
+		"Leeds":                     "GB",
+		"Princes Street, Edinburgh": "GB",
+		"Malaga":                    "ES",
+		"Frankfurt am Main":         "DE",
+		"ls16 7lp":                  "GB",
+	} {
+		matches, err := g.Forward(address, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 1 || matches[0].CountryCode != countryCode {
+			t.Errorf("%s: expected a place in %s but got %v", address, countryCode, matches)
+		}
+	}
+}
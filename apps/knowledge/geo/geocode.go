@@ -0,0 +1,56 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Place is the country and region a location is in
+type Place struct {
+	// CountryCode is the ISO 3166-1 alpha-2 code of the country, e.g. GB
+	CountryCode string
+	Country     string
+
+	// Region is the first level division of the country the location is
+	// in, e.g. Scotland, when it's known
+	Region string
+}
+
+// ErrNoPlace is returned when a location isn't in any known country, such
+// as when it's at sea
+var ErrNoPlace = errors.New("Location is not in a known country")
+
+// Geocoder finds the place a location is in
+type Geocoder interface {
+	Reverse(lat, lng float64) (Place, error)
+}
+
+// NewGeocoder returns the geocoder of the kind, either offline, which finds
+// places in the boundaries read from the GeoJSON file, or the coarse ones
+// bundled with the service when no file is given, or none, which returns nil
+// so locations aren't geocoded. Without a kind, locations are only geocoded
+// when a boundaries file is given.
+func NewGeocoder(kind, boundaries string) (Geocoder, error) {
+	if kind == "" {
+		kind = "none"
+		if boundaries != "" {
+			kind = "offline"
+		}
+	}
+
+	switch kind {
+	case "offline":
+		if boundaries == "" {
+			boundaries = DefaultBoundaries
+		}
+		b, err := LoadBoundaries(boundaries)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Unknown geocoder %s, expected offline or none", kind)
+	}
+}
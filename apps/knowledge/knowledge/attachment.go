@@ -0,0 +1,439 @@
+package knowledge
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/blob"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxAttachmentSize is the largest file in bytes that can be attached to a
+// record
+const MaxAttachmentSize = 32 << 20
+
+// maxAttachmentBody is the most of the body of an upload that is read, which
+// leaves room for the rest of the form around the file
+const maxAttachmentBody = MaxAttachmentSize + 1<<20
+
+// attachmentKey is where the file of an attachment is kept in the blob store
+func attachmentKey(r *http.Request, recordID, attachmentID string) string {
+	return types.AttachmentKey(workspace(r), recordID, attachmentID)
+}
+
+func newAttachmentID() (string, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += int64(len(p))
+	return len(p), nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// attachmentRecord gets the record attachments are being worked on, writing
+// the response when it can't
+func (s *WebService) attachmentRecord(w http.ResponseWriter, r *http.Request, logger *log.Entry) (database.Database, types.Record, bool) {
+	id, err := getRecordID(r)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"status": 400,
+			"error":  err.Error(),
+		}).Warn("Issue with ID")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+		return nil, types.Record{}, false
+	}
+
+	if s.DB == nil || s.Blobs == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to connect to attachment storage"})
+		logger.WithFields(log.Fields{
+			"status": 500,
+		}).Error("No database or blob store set")
+		return nil, types.Record{}, false
+	}
+
+	db := s.db(r)
+	record, err := db.Get(id)
+	if err != nil {
+		if _, ok := err.(*types.RecordNotFoundError); ok {
+			logger.WithFields(log.Fields{
+				"status": 404,
+				"id":     id,
+			}).Warn("Record not found")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Record not found"})
+			return nil, types.Record{}, false
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 500,
+			"id":     id,
+			"error":  err.Error(),
+		}).Error("Failed to get record")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to get record"})
+		return nil, types.Record{}, false
+	}
+
+	return db, record, true
+}
+
+// AddAttachment attaches a file to a record, sent as the file part of a
+// multipart/form-data body. The name, size, content type and SHA-256 checksum
+// of the file are added to the attachments of the record and returned.
+// Path: /record/{id}/attachment
+// Method: POST
+// Example: curl -F file=@floorplan.pdf /record/12345/attachment
+// Files can be up to 32MB, larger ones get 413.
+func (s *WebService) AddAttachment() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "addAttachment",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		db, record, ok := s.attachmentRecord(w, r, logger)
+		if !ok {
+			return
+		}
+
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = http.MaxBytesReader(w, body, maxAttachmentBody)
+		parts, err := r.MultipartReader()
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Body is not multipart")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Expected a multipart/form-data body with a file"})
+			return
+		}
+
+		for {
+			part, err := parts.NextPart()
+			if err != nil {
+				logger.WithFields(log.Fields{
+					"status": 400,
+					"id":     record.ID,
+				}).Warn("No file in body")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: "Expected a multipart/form-data body with a file"})
+				return
+			}
+			if part.FormName() != "file" {
+				continue
+			}
+
+			s.storeAttachment(w, r, logger, db, record, part, body)
+			return
+		}
+	}
+}
+
+// storeAttachment writes the file to the blob store then adds it to the
+// attachments of the record, removing it again if that fails. The file is too
+// large when more than MaxAttachmentSize of it is read, or more than
+// maxAttachmentBody of the body is read to get to the end of it.
+func (s *WebService) storeAttachment(w http.ResponseWriter, r *http.Request, logger *log.Entry, db database.Database, record types.Record, part *multipart.Part, body *countingReader) {
+	attachmentID, err := newAttachmentID()
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"status": 500,
+			"error":  err.Error(),
+		}).Error("Failed to generate attachment ID")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to store attachment"})
+		return
+	}
+
+	name := filepath.Base(filepath.Clean("/" + part.FileName()))
+	if name == "/" || name == "." {
+		name = "file"
+	}
+
+	contentType := part.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		if byExtension := mime.TypeByExtension(filepath.Ext(name)); byExtension != "" {
+			contentType = byExtension
+		} else {
+			contentType = "application/octet-stream"
+		}
+	}
+
+	key := attachmentKey(r, record.ID, attachmentID)
+	checksum := sha256.New()
+	size := &countingWriter{}
+	limited := &io.LimitedReader{R: part, N: MaxAttachmentSize + 1}
+
+	err = s.Blobs.Put(key, io.TeeReader(limited, io.MultiWriter(checksum, size)))
+	if limited.N == 0 || body.n > maxAttachmentBody {
+		err = errTooLarge
+	}
+	if err != nil {
+		s.Blobs.Delete(key)
+
+		if err == errTooLarge {
+			logger.WithFields(log.Fields{
+				"status": 413,
+				"id":     record.ID,
+			}).Warn("Attachment is too large")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: fmt.Sprintf("Attachments must be at most %dMB", MaxAttachmentSize>>20)})
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 500,
+			"id":     record.ID,
+			"error":  err.Error(),
+		}).Error("Failed to store attachment")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to store attachment"})
+		return
+	}
+
+	attachment, err := db.AddAttachment(record.ID, types.Attachment{
+		ID:          attachmentID,
+		Name:        name,
+		Size:        size.n,
+		ContentType: contentType,
+		Checksum:    hex.EncodeToString(checksum.Sum(nil)),
+	})
+	if err != nil {
+		s.Blobs.Delete(key)
+
+		if _, ok := err.(*types.RecordNotFoundError); ok {
+			logger.WithFields(log.Fields{
+				"status": 404,
+				"id":     record.ID,
+			}).Warn("Record deleted while attaching")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Record not found"})
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 500,
+			"id":     record.ID,
+			"error":  err.Error(),
+		}).Error("Failed to add attachment")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to store attachment"})
+		return
+	}
+
+	logger.WithFields(log.Fields{
+		"status":     201,
+		"id":         record.ID,
+		"attachment": attachment.ID,
+		"size":       attachment.Size,
+	}).Info("Added attachment")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+var errTooLarge = errors.New("Attachment is too large")
+
+// Attachments lists the metadata of the files attached to a record
+// Path: /record/{id}/attachment
+// Method: GET
+// Example: /record/12345/attachment
+func (s *WebService) Attachments() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "attachments",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		_, record, ok := s.attachmentRecord(w, r, logger)
+		if !ok {
+			return
+		}
+
+		attachments := record.Attachments
+		if attachments == nil {
+			attachments = []types.Attachment{}
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"id":     record.ID,
+			"count":  len(attachments),
+		}).Info("Listed attachments")
+		json.NewEncoder(w).Encode(attachments)
+	}
+}
+
+// GetAttachment downloads a file attached to a record
+// Path: /record/{id}/attachment/{attachment}
+// Method: GET
+// Example: /record/12345/attachment/67890
+// The ETag header holds the SHA-256 checksum of the file.
+func (s *WebService) GetAttachment() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "getAttachment",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		_, record, ok := s.attachmentRecord(w, r, logger)
+		if !ok {
+			return
+		}
+
+		attachmentID := mux.Vars(r)["attachment"]
+		attachment, ok := record.Attachment(attachmentID)
+		if !ok {
+			logger.WithFields(log.Fields{
+				"status":     404,
+				"id":         record.ID,
+				"attachment": attachmentID,
+			}).Warn("Attachment not found")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Attachment not found"})
+			return
+		}
+
+		file, err := s.Blobs.Get(attachmentKey(r, record.ID, attachment.ID))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err == blob.ErrNotFound {
+				status = http.StatusNotFound
+			}
+			logger.WithFields(log.Fields{
+				"status":     status,
+				"id":         record.ID,
+				"attachment": attachment.ID,
+				"error":      err.Error(),
+			}).Error("Failed to read attachment")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to read attachment"})
+			return
+		}
+		defer file.Close()
+
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("ETag", fmt.Sprintf("\"%s\"", attachment.Checksum))
+		w.WriteHeader(http.StatusOK)
+
+		if _, err := io.Copy(w, file); err != nil {
+			logger.WithFields(log.Fields{
+				"id":         record.ID,
+				"attachment": attachment.ID,
+				"error":      err.Error(),
+			}).Error("Download stopped part way through")
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status":     200,
+			"id":         record.ID,
+			"attachment": attachment.ID,
+		}).Info("Downloaded attachment")
+	}
+}
+
+// DeleteAttachment removes a file from a record
+// Path: /record/{id}/attachment/{attachment}
+// Method: DELETE
+// Example: /record/12345/attachment/67890
+func (s *WebService) DeleteAttachment() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "deleteAttachment",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		db, record, ok := s.attachmentRecord(w, r, logger)
+		if !ok {
+			return
+		}
+
+		attachmentID := mux.Vars(r)["attachment"]
+		attachment, err := db.DeleteAttachment(record.ID, attachmentID)
+		if err != nil {
+			_, missingAttachment := err.(*types.AttachmentNotFoundError)
+			_, missingRecord := err.(*types.RecordNotFoundError)
+			if missingAttachment || missingRecord {
+				logger.WithFields(log.Fields{
+					"status":     404,
+					"id":         record.ID,
+					"attachment": attachmentID,
+				}).Warn("Attachment not found")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: "Attachment not found"})
+				return
+			}
+
+			logger.WithFields(log.Fields{
+				"status":     500,
+				"id":         record.ID,
+				"attachment": attachmentID,
+				"error":      err.Error(),
+			}).Error("Failed to delete attachment")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to delete attachment"})
+			return
+		}
+
+		// The attachment has gone from the record so a file left behind is
+		// only logged
+		if err := s.Blobs.Delete(attachmentKey(r, record.ID, attachment.ID)); err != nil {
+			logger.WithFields(log.Fields{
+				"id":         record.ID,
+				"attachment": attachment.ID,
+				"error":      err.Error(),
+			}).Error("Failed to remove attachment file")
+		}
+
+		logger.WithFields(log.Fields{
+			"status":     200,
+			"id":         record.ID,
+			"attachment": attachment.ID,
+		}).Info("Deleted attachment")
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,185 @@
+package knowledge
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/blob"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/gorilla/mux"
+)
+
+func attachmentService(t *testing.T, record *types.Record) (*WebService, *mockDB, func()) {
+	dir, err := ioutil.TempDir("", "attachments")
+	ok(t, err)
+	store, err := blob.NewFileStore(dir)
+	ok(t, err)
+
+	db := &mockDB{
+		GetFunc: func(id string) (types.Record, error) {
+			if id != record.ID {
+				return types.Record{}, &types.RecordNotFoundError{ID: id, Message: "Record does not exist in the database."}
+			}
+			return *record, nil
+		},
+		AddAttachmentFunc: func(id string, a types.Attachment) (types.Attachment, error) {
+			record.Attachments = append(record.Attachments, a)
+			return a, nil
+		},
+		DeleteAttachmentFunc: func(id, attachmentID string) (types.Attachment, error) {
+			a, found := record.Attachment(attachmentID)
+			if !found {
+				return a, &types.AttachmentNotFoundError{ID: attachmentID, RecordID: id, Message: "Attachment does not exist."}
+			}
+			record.Attachments = nil
+			return a, nil
+		},
+	}
+
+	return &WebService{DB: db, Blobs: store}, db, func() { os.RemoveAll(dir) }
+}
+
+func attachmentRouter(s *WebService) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/record/{id}/attachment", s.AddAttachment()).Methods("POST")
+	router.HandleFunc("/record/{id}/attachment", s.Attachments()).Methods("GET")
+	router.HandleFunc("/record/{id}/attachment/{attachment}", s.GetAttachment()).Methods("GET")
+	router.HandleFunc("/record/{id}/attachment/{attachment}", s.DeleteAttachment()).Methods("DELETE")
+	return router
+}
+
+func uploadRequest(t *testing.T, path, field, name string, content []byte) *http.Request {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile(field, name)
+	ok(t, err)
+	part.Write(content)
+	ok(t, form.Close())
+
+	req, err := http.NewRequest("POST", path, body)
+	ok(t, err)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-Actor", "sam")
+	return req
+}
+
+func TestAttachmentCanBeUploadedDownloadedAndDeleted(t *testing.T) {
+	record := &types.Record{ID: "12345"}
+	service, db, cleanup := attachmentService(t, record)
+	defer cleanup()
+	router := attachmentRouter(service)
+
+	content := []byte("%PDF-1.4 floor plan")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, uploadRequest(t, "/record/12345/attachment", "file", "plans/floor.pdf", content))
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected Created (201) status to be returned got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var attachment types.Attachment
+	ok(t, json.NewDecoder(rr.Body).Decode(&attachment))
+
+	sum := sha256.Sum256(content)
+	if attachment.ID == "" || attachment.Name != "floor.pdf" || attachment.Size != int64(len(content)) ||
+		attachment.ContentType != "application/pdf" || attachment.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the metadata of the file to be returned but got %+v", attachment)
+	}
+	if db.Actor != "sam" {
+		t.Errorf("Expected attachment to be added as sam but was added as %s", db.Actor)
+	}
+
+	rr = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/record/12345/attachment/"+attachment.ID, nil)
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), content) {
+		t.Fatalf("Expected the file to be downloaded got %d: %q", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "application/pdf" || rr.Header().Get("Content-Disposition") != `attachment; filename=floor.pdf` {
+		t.Errorf("Expected content type and file name headers but got %v", rr.Header())
+	}
+	if rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("Expected browsers to be told not to sniff the content type but got %v", rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/record/12345/attachment/"+attachment.ID, nil)
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected OK (200) status to be returned got %d", rr.Code)
+	}
+
+	if _, err := service.Blobs.Get("default/12345/" + attachment.ID); err != blob.ErrNotFound {
+		t.Errorf("Expected the file to be removed but got %v", err)
+	}
+}
+
+func TestAttachmentsOfUnknownRecordAreNotFound(t *testing.T) {
+	service, _, cleanup := attachmentService(t, &types.Record{ID: "12345"})
+	defer cleanup()
+	router := attachmentRouter(service)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, uploadRequest(t, "/record/67890/attachment", "file", "a.txt", []byte("a")))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) for upload got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/record/12345/attachment/nope", nil)
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) for unknown attachment got %d", rr.Code)
+	}
+}
+
+func TestAttachmentUploadNeedsAFile(t *testing.T) {
+	service, _, cleanup := attachmentService(t, &types.Record{ID: "12345"})
+	defer cleanup()
+	router := attachmentRouter(service)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, uploadRequest(t, "/record/12345/attachment", "photo", "a.jpg", []byte("a")))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) without a file part got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/record/12345/attachment", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) for a JSON body got %d", rr.Code)
+	}
+}
+
+func TestAttachmentsOverTheLimitAreTooLarge(t *testing.T) {
+	record := &types.Record{ID: "12345"}
+	service, _, cleanup := attachmentService(t, record)
+	defer cleanup()
+	router := attachmentRouter(service)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, uploadRequest(t, "/record/12345/attachment", "file", "a.bin", make([]byte, MaxAttachmentSize)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected Created (201) for a file of the largest size got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, uploadRequest(t, "/record/12345/attachment", "file", "a.bin", make([]byte, MaxAttachmentSize+1)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected Request Entity Too Large (413) got %d", rr.Code)
+	}
+	if len(record.Attachments) != 1 {
+		t.Errorf("Expected only the first file to be attached but got %d", len(record.Attachments))
+	}
+}
@@ -0,0 +1,48 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// authorize only lets principals with the role make requests to the route,
+// others get 403, as do requests without a principal. When authentication is
+// disabled requests are given an admin principal by auth.Disabled.
+func authorize(route, role string, next http.HandlerFunc) http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "authorize",
+		"route": route,
+		"role":  role,
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(r, role) {
+			forbidden(w, r, logger, role)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// allowed reports whether the request was made by a principal with the role
+func allowed(r *http.Request, role string) bool {
+	p, ok := auth.FromContext(r.Context())
+	return ok && p.HasRole(role)
+}
+
+// forbidden responds with 403 as the principal doesn't have the role
+func forbidden(w http.ResponseWriter, r *http.Request, logger *log.Entry, role string) {
+	p, _ := auth.FromContext(r.Context())
+	logger.WithFields(log.Fields{
+		"status":    403,
+		"principal": p.Name,
+		"roles":     p.Roles,
+	}).Warn("Principal does not have the role needed")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(&ErrorResponse{Message: fmt.Sprintf("The %s role is needed to do this", role)})
+}
@@ -0,0 +1,90 @@
+package knowledge
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+func authorizedRouter() http.Handler {
+	db := &mockDB{
+		CreateFunc: func(r types.Record) (string, error) {
+			return "12345", nil
+		},
+		GetFieldsFunc: func() ([]types.Field, error) {
+			return []types.Field{}, nil
+		},
+		UpdateFieldsFunc: func(f []types.Field) error {
+			return nil
+		},
+		DeleteFunc: func(id string) error {
+			return nil
+		},
+		BulkFunc: func(op types.BulkOperation) ([]types.BulkResult, error) {
+			return []types.BulkResult{}, nil
+		},
+	}
+	return NewRouter(&WebService{DB: db})
+}
+
+func requestAs(router http.Handler, role, method, path, body string) int {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if role != "" {
+		req = req.WithContext(auth.NewContext(req.Context(), auth.Principal{Name: "sam", Roles: []string{role}}))
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestRoutesNeedTheirRole(t *testing.T) {
+	router := authorizedRouter()
+	record := `{"title":"Place","location":{"lat":53.7,"lng":-1.5}}`
+
+	tests := []struct {
+		role   string
+		method string
+		path   string
+		body   string
+		denied bool
+	}{
+		{auth.RoleViewer, "GET", "/v1/field", "", false},
+		{auth.RoleViewer, "POST", "/v1/record", record, true},
+		{auth.RoleEditor, "POST", "/v1/record", record, false},
+		{auth.RoleEditor, "DELETE", "/v1/record/12345", "", true},
+		{auth.RoleAdmin, "DELETE", "/v1/record/12345", "", false},
+		{auth.RoleEditor, "PUT", "/v1/field", "[]", true},
+		{auth.RoleEditor, "DELETE", "/v1/field/12345", "", true},
+		{auth.RoleAdmin, "PUT", "/v1/field", "[]", false},
+		{"", "GET", "/v1/field", "", true},
+	}
+
+	for _, test := range tests {
+		code := requestAs(router, test.role, test.method, test.path, test.body)
+		if denied := code == http.StatusForbidden; denied != test.denied {
+			t.Errorf("Expected %s %s as %q to be denied: %v, got %d", test.method, test.path, test.role, test.denied, code)
+		}
+	}
+}
+
+func TestBulkDeleteNeedsAdmin(t *testing.T) {
+	router := authorizedRouter()
+	body := `{"ids":["1"],"operation":"delete"}`
+
+	if code := requestAs(router, auth.RoleEditor, "POST", "/v1/record/bulk", body); code != http.StatusForbidden {
+		t.Errorf("Expected Forbidden (403) for bulk delete as an editor got %d", code)
+	}
+
+	if code := requestAs(router, auth.RoleAdmin, "POST", "/v1/record/bulk", body); code != http.StatusOK {
+		t.Errorf("Expected OK (200) for bulk delete as an admin got %d", code)
+	}
+
+	if code := requestAs(router, auth.RoleEditor, "POST", "/v1/record/bulk", `{"ids":["1"],"operation":"addFacility","facility":"TAXI"}`); code != http.StatusOK {
+		t.Errorf("Expected OK (200) for bulk addFacility as an editor got %d", code)
+	}
+}
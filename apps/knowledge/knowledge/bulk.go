@@ -0,0 +1,187 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// Bulk makes one change to many records at once, picked either by their IDs
+// or by a search filter. Records can have a detail set, a facility added or
+// removed, or be deleted.
+// Path: /record/bulk
+// Method: POST
+// Example: /record/bulk
+//		Body: {
+//					"filter": "minLat=53.63&maxLat=53.84&minLng=-1.97&maxLng=-1.11",
+//					"operation": "addFacility",
+//					"facility": "TAXI"
+//				}
+//		Body: {
+//					"ids": ["12345", "67890"],
+//					"operation": "setDetail",
+//					"key": "region",
+//					"value": "North"
+//				}
+// Operations are setDetail (an empty value removes the detail), addFacility,
+// removeFacility and delete, which needs the admin role. At most 1000 records
+// can be changed at once.
+// The result for each record is returned, updated, deleted, unchanged,
+// notFound or failed.
+func (s *WebService) Bulk() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "bulk",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Body == nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+			}).Warn("No body provided")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "No body provided"})
+			return
+		}
+
+		var op types.BulkOperation
+		if err := json.NewDecoder(r.Body).Decode(&op); err != nil {
+			logger.WithFields(log.Fields{
+				"error":  err.Error(),
+				"status": 400,
+			}).Error("Unable to parse JSON")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to parse JSON"})
+			return
+		}
+
+		if s.DB == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to connect to database"})
+			logger.WithFields(log.Fields{
+				"status": 500,
+			}).Error("No database set")
+			return
+		}
+
+		if status, err := s.checkBulk(s.db(r), &op); err != nil {
+			logger.WithFields(log.Fields{
+				"status":    status,
+				"operation": op.Operation,
+				"error":     err.Error(),
+			}).Warn("Invalid bulk operation")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		if op.Operation == types.BulkDelete && !allowed(r, auth.RoleAdmin) {
+			forbidden(w, r, logger, auth.RoleAdmin)
+			return
+		}
+
+		results, err := s.db(r).Bulk(op)
+		if err != nil {
+			if invalid, ok := err.(*types.ValidationError); ok {
+				logger.WithFields(log.Fields{
+					"status": 422,
+					"error":  err.Error(),
+				}).Warn("Detail value is invalid")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(invalid)
+				return
+			}
+
+			if _, ok := err.(*types.BulkLimitError); ok {
+				logger.WithFields(log.Fields{
+					"status": 400,
+					"error":  err.Error(),
+				}).Warn("Bulk operation matches too many records")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+			}).Error("Failed to run bulk operation")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to run bulk operation"})
+			return
+		}
+
+		report := types.BulkReport{Results: []types.BulkResult{}}
+		for _, result := range results {
+			report.Add(result)
+		}
+
+		logger.WithFields(log.Fields{
+			"status":    200,
+			"operation": op.Operation,
+			"updated":   report.Updated,
+			"deleted":   report.Deleted,
+			"unchanged": report.Unchanged,
+			"notFound":  report.NotFound,
+			"failed":    report.Failed,
+		}).Info("Ran bulk operation")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// checkBulk checks the operation is complete and parses its filter, the
+// status to respond with is returned along with the error when it's invalid
+func (s *WebService) checkBulk(db database.Database, op *types.BulkOperation) (int, error) {
+	switch op.Operation {
+	case types.BulkSetDetail:
+		if op.Key == "" {
+			return http.StatusBadRequest, errors.New("setDetail requires the key of the detail")
+		}
+	case types.BulkAddFacility, types.BulkRemoveFacility:
+		op.Facility = strings.ToUpper(strings.TrimSpace(op.Facility))
+		if op.Facility == "" {
+			return http.StatusBadRequest, fmt.Errorf("%s requires a facility", op.Operation)
+		}
+	case types.BulkDelete:
+	default:
+		return http.StatusBadRequest, errors.New("Operation must be setDetail, addFacility, removeFacility or delete")
+	}
+
+	if (len(op.IDs) == 0) == (strings.TrimSpace(op.Filter) == "") {
+		return http.StatusBadRequest, errors.New("Either ids or a filter must be given")
+	}
+
+	if len(op.IDs) > database.MaxBulkRecords {
+		return http.StatusBadRequest, fmt.Errorf("Bulk operations can change at most %d records at once", database.MaxBulkRecords)
+	}
+
+	if len(op.IDs) > 0 {
+		return http.StatusOK, nil
+	}
+
+	params, err := url.ParseQuery(strings.TrimPrefix(op.Filter, "?"))
+	if err != nil {
+		return http.StatusBadRequest, errors.New("Unable to parse filter, expected search parameters")
+	}
+
+	op.Query = &types.SearchQuery{}
+	if status, err := s.filterQuery(db, params, op.Query); err != nil {
+		return status, err
+	}
+
+	q := op.Query
+	if q.Query == "" && q.Radius == 0 && !boundsPresent(*q) && len(q.Facilities) == 0 && len(q.CountryCodes) == 0 && len(q.Details) == 0 {
+		return http.StatusBadRequest, errors.New("Filter must restrict the records by query, area, country, facility or detail")
+	}
+
+	return http.StatusOK, nil
+}
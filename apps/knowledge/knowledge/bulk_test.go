@@ -0,0 +1,125 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+func TestBulkPassesIdsAndOperationToDatabase(t *testing.T) {
+	var passed types.BulkOperation
+	db := mockDB{
+		BulkFunc: func(op types.BulkOperation) ([]types.BulkResult, error) {
+			passed = op
+			return []types.BulkResult{
+				{ID: "1", Status: types.BulkUpdated},
+				{ID: "2", Status: types.BulkUnchanged},
+				{ID: "3", Status: types.BulkNotFound},
+			}, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	body := `{"ids":["1","2","3"],"operation":"addFacility","facility":" taxi "}`
+	req, err := http.NewRequest("POST", "/record/bulk", bytes.NewBufferString(body))
+	ok(t, err)
+	req.Header.Set("X-Actor", "sam")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Bulk()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected OK (200) status to be returned got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if len(passed.IDs) != 3 || passed.Facility != "TAXI" || passed.Query != nil {
+		t.Errorf("Expected ids and upper cased facility to be passed to the database but got %+v", passed)
+	}
+
+	if db.Actor != "sam" {
+		t.Errorf("Expected bulk operation to be made as sam but was made as %s", db.Actor)
+	}
+
+	var report types.BulkReport
+	ok(t, json.NewDecoder(rr.Body).Decode(&report))
+	if report.Updated != 1 || report.Unchanged != 1 || report.NotFound != 1 || len(report.Results) != 3 {
+		t.Errorf("Expected 1 updated, 1 unchanged and 1 not found but got %+v", report)
+	}
+}
+
+func TestBulkParsesFilter(t *testing.T) {
+	var passed types.BulkOperation
+	db := mockDB{
+		GetFieldsFunc: func() ([]types.Field, error) {
+			return []types.Field{{ID: "1", Value: "Region"}}, nil
+		},
+		BulkFunc: func(op types.BulkOperation) ([]types.BulkResult, error) {
+			passed = op
+			return nil, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	body := `{"filter":"facility=BUS&details.1=North","operation":"delete"}`
+	req, err := http.NewRequest("POST", "/record/bulk", bytes.NewBufferString(body))
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	auth.Disabled(service.Bulk()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected OK (200) status to be returned got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if passed.Query == nil || len(passed.Query.Facilities) != 1 || len(passed.Query.Details) != 1 || passed.Query.Details[0].Key != "region" {
+		t.Errorf("Expected the filter to be parsed into a query but got %+v", passed.Query)
+	}
+}
+
+func TestBulkRejectsInvalidOperations(t *testing.T) {
+	for _, body := range []string{
+		`{"ids":["1"],"operation":"rename"}`,
+		`{"ids":["1"],"operation":"setDetail"}`,
+		`{"ids":["1"],"operation":"removeFacility"}`,
+		`{"operation":"delete"}`,
+		`{"ids":["1"],"filter":"facility=BUS","operation":"delete"}`,
+		`{"filter":"page=2","operation":"delete"}`,
+	} {
+		service := &WebService{DB: &mockDB{}}
+
+		req, err := http.NewRequest("POST", "/record/bulk", bytes.NewBufferString(body))
+		ok(t, err)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(service.Bulk()).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected Bad Request (400) status to be returned for %s got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestBulkReturnsBadRequestWhenTooManyRecordsMatch(t *testing.T) {
+	db := mockDB{
+		BulkFunc: func(op types.BulkOperation) ([]types.BulkResult, error) {
+			return nil, &types.BulkLimitError{Matched: 1500, Limit: 1000}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	body := `{"filter":"facility=BUS","operation":"delete"}`
+	req, err := http.NewRequest("POST", "/record/bulk", bytes.NewBufferString(body))
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	auth.Disabled(service.Bulk()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
@@ -0,0 +1,105 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// Clusters summarises the records in an area for a map at a zoom level,
+// grouping them into a grid of cells around 64 pixels across and returning
+// the number of records in each cell with their centroid and bounds. The
+// map can plot the clusters instead of every record and zoom to the bounds
+// of one to see what's in it.
+// Path: /record/clusters
+// Method: GET
+// Parameters:
+//		zoom - zoom level of the map, from 0 (the whole world) to 22
+//		minLat, maxLat, minLng, maxLng, or lat, lng, radius - the area to
+//				cluster, as for /record
+//		query, facility, facilityMatch, countryCode, details.{key} -
+//				(optional) filter the records as for /record
+// Headers: X-Total-Count holds the total number of records clustered
+// Example: /record/clusters?zoom=6&minLat=49.5&maxLat=59&minLng=-8.5&maxLng=2
+// Clusters of a single record include its id. At most 10000 clusters are
+// returned, the largest first.
+func (s *WebService) Clusters() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "clusters",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		zoom, err := strconv.Atoi(r.URL.Query().Get("zoom"))
+		if err != nil || zoom < 0 || zoom > types.MaxClusterZoom {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"zoom":   r.URL.Query().Get("zoom"),
+			}).Warn("Invalid zoom")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: fmt.Sprintf("Zoom must be a number from 0 to %d", types.MaxClusterZoom)})
+			return
+		}
+
+		db := s.db(r)
+		query := &types.SearchQuery{}
+		if status, err := s.filterQuery(db, r.URL.Query(), query); err != nil {
+			logger.WithFields(log.Fields{
+				"status": status,
+				"error":  err.Error(),
+			}).Warn("Invalid search")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		if query.Radius == 0 && !boundsPresent(*query) {
+			logger.WithFields(log.Fields{
+				"status": 400,
+			}).Warn("No location bounds provided")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Not all location bounds provided, expected mininum and maximum latitude and longitude."})
+			return
+		}
+
+		clusters, err := db.Clusters(*query, zoom)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+			}).Error("Unable to cluster records")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to cluster records"})
+			return
+		}
+
+		total := 0
+		for _, cluster := range clusters {
+			total += cluster.Count
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+		if len(clusters) == 0 {
+			logger.WithFields(log.Fields{
+				"status": 404,
+				"zoom":   zoom,
+			}).Info("No records to cluster")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("[]"))
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status":   200,
+			"zoom":     zoom,
+			"clusters": len(clusters),
+			"total":    total,
+		}).Info("Clusters returned")
+		json.NewEncoder(w).Encode(clusters)
+	}
+}
@@ -0,0 +1,75 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+func TestClustersPassesTheAreaAndZoomToDB(t *testing.T) {
+	var passed types.SearchQuery
+	var passedZoom int
+	db := &mockDB{
+		ClustersFunc: func(query types.SearchQuery, zoom int) ([]types.Cluster, error) {
+			passed, passedZoom = query, zoom
+			return []types.Cluster{
+				{Lat: 53.8, Lng: -1.55, Count: 12, MinLat: 53.7, MaxLat: 53.9, MinLng: -1.7, MaxLng: -1.4},
+				{Lat: 53.86, Lng: -1.66, Count: 1, MinLat: 53.86, MaxLat: 53.86, MinLng: -1.66, MaxLng: -1.66, ID: "ABC123"},
+			}, nil
+		},
+	}
+
+	req, err := http.NewRequest("GET", "/record/clusters?zoom=9&minLat=53.63&maxLat=53.84&minLng=-1.97&maxLng=-1.11&countryCode=GB", nil)
+	ok(t, err)
+	rr := httptest.NewRecorder()
+	(&WebService{DB: db}).Clusters().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if passedZoom != 9 || passed.MinLat != 53.63 || passed.MaxLng != -1.11 || len(passed.CountryCodes) != 1 {
+		t.Errorf("Expected the area, filters and zoom to be passed but got %v at %d", passed, passedZoom)
+	}
+	if rr.Header().Get("X-Total-Count") != "13" {
+		t.Errorf("Expected 13 records to be counted but got %s", rr.Header().Get("X-Total-Count"))
+	}
+
+	var clusters []types.Cluster
+	ok(t, json.NewDecoder(rr.Body).Decode(&clusters))
+	if len(clusters) != 2 || clusters[1].ID != "ABC123" {
+		t.Errorf("Expected the clusters to be returned but got %v", clusters)
+	}
+}
+
+func TestClustersStatuses(t *testing.T) {
+	db := &mockDB{
+		ClustersFunc: func(query types.SearchQuery, zoom int) ([]types.Cluster, error) {
+			return []types.Cluster{}, nil
+		},
+	}
+
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/record/clusters?minLat=53.63&maxLat=53.84&minLng=-1.97&maxLng=-1.11", http.StatusBadRequest},
+		{"/record/clusters?zoom=23&minLat=53.63&maxLat=53.84&minLng=-1.97&maxLng=-1.11", http.StatusBadRequest},
+		{"/record/clusters?zoom=9", http.StatusBadRequest},
+		{"/record/clusters?zoom=9&minLat=53.63&maxLat=53.84&minLng=-1.97&maxLng=-1.11&facilityMatch=some", http.StatusBadRequest},
+		{"/record/clusters?zoom=9&minLat=53.63&maxLat=53.84&minLng=-1.97&maxLng=-1.11", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", test.url, nil)
+		ok(t, err)
+		rr := httptest.NewRecorder()
+		(&WebService{DB: db}).Clusters().ServeHTTP(rr, req)
+
+		if rr.Code != test.status {
+			t.Errorf("%s: expected %d but got %d", test.url, test.status, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/events"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// heartbeat is how often a comment is sent to keep idle streams open through
+// proxies
+var heartbeat = 30 * time.Second
+
+// Events streams changes to the records and fields of the workspace as
+// server-sent events, named record.created, record.updated, record.deleted,
+// record.restored, field.updated, field.deleted and field.restored. The data
+// of each is JSON holding the id and, when it's known, the record as it now
+// is.
+// Path: /events
+// Method: GET
+// Parameters:
+//		minLat, maxLat, minLng, maxLng - (optional) only send changes to
+//				records inside the bounds, before or after the change
+//		access_token - (optional) the bearer token, for clients such as
+//				EventSource that can't send the Authorization header
+// Example: /events?minLat=53.63&maxLat=53.84&minLng=-1.97&maxLng=-1.11
+// Changes made in bulk or by an import only hold the id of the record and
+// are sent regardless of the bounds. Clients that fall too far behind are
+// disconnected and should reload when they reconnect.
+func (s *WebService) Events() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "events",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		bounds, err := eventBounds(r.URL.Query())
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Invalid bounds")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if s.Broker == nil || !ok {
+			logger.WithFields(log.Fields{
+				"status": 500,
+			}).Error("Events can't be streamed")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to stream events"})
+			return
+		}
+
+		ws := workspace(r)
+		sub := s.Broker.Subscribe(events.Within(ws, bounds))
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 5000\n\n")
+		flusher.Flush()
+
+		logger.WithFields(log.Fields{
+			"status":    200,
+			"workspace": ws,
+			"bounds":    bounds != nil,
+		}).Info("Streaming events")
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case e, open := <-sub.C:
+				if !open {
+					logger.WithFields(log.Fields{
+						"workspace": ws,
+					}).Warn("Client fell behind, closing stream")
+					return
+				}
+
+				data, err := json.Marshal(e)
+				if err != nil {
+					logger.WithFields(log.Fields{
+						"error": err.Error(),
+					}).Error("Unable to encode event")
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// eventBounds reads the optional bounds to filter events to, all four of
+// them must be given or none
+func eventBounds(params url.Values) (*types.SearchQuery, error) {
+	names := []string{"minLat", "maxLat", "minLng", "maxLng"}
+	var values []float64
+	for _, name := range names {
+		if params.Get(name) == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(params.Get(name), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", name)
+		}
+		values = append(values, value)
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+	if len(values) != len(names) {
+		return nil, errors.New("minLat, maxLat, minLng and maxLng must all be given to filter by bounds")
+	}
+
+	bounds := &types.SearchQuery{MinLat: values[0], MaxLat: values[1], MinLng: values[2], MaxLng: values[3]}
+	if bounds.MinLat > bounds.MaxLat {
+		return nil, errors.New("minLat must be less than maxLat")
+	}
+	return bounds, nil
+}
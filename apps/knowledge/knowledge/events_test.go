@@ -0,0 +1,78 @@
+package knowledge
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/events"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+func TestEventsStreamsChangesInTheBounds(t *testing.T) {
+	broker := events.NewBroker()
+	s := &WebService{DB: &mockDB{}, Broker: broker}
+
+	server := httptest.NewServer(s.Events())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events?minLat=53.63&maxLat=53.84&minLng=-1.97&maxLng=-1.11")
+	ok(t, err)
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected an event stream but got %s", ct)
+	}
+
+	inside := &types.Record{ID: "12345", Title: "Leeds Bradford"}
+	inside.Location.Lat, inside.Location.Lng = 53.8, -1.5
+	outside := &types.Record{ID: "67890", Title: "Heathrow"}
+	outside.Location.Lat, outside.Location.Lng = 51.47, -0.45
+	moved := &types.Record{ID: "12345", Title: "Leeds Bradford"}
+	moved.Location.Lat, moved.Location.Lng = 53.86, -1.66
+
+	broker.Publish(events.Event{Type: events.RecordCreated, Workspace: types.DefaultWorkspace, ID: "67890", Record: outside})
+	broker.Publish(events.Event{Type: events.RecordDeleted, Workspace: "team-a", ID: "12345"})
+	broker.Publish(events.Event{Type: events.RecordUpdated, Workspace: types.DefaultWorkspace, ID: "12345", Record: moved, Previous: inside})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) == 0 || lines[len(lines)-1] != "" || !strings.HasPrefix(lines[0], "id:") {
+		line, err := reader.ReadString('\n')
+		ok(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if strings.HasPrefix(line, "retry:") || (line == "" && len(lines) == 0) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	resp.Body.Close()
+
+	if len(lines) != 4 || lines[0] != "id: 3" || lines[1] != "event: record.updated" || !strings.Contains(lines[2], `"id":"12345"`) {
+		t.Errorf("Expected only the record moving out of the bounds to be sent but got %q", lines)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for broker.Subscribers() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if broker.Subscribers() != 0 {
+		t.Error("Expected the subscription to be closed when the client goes away")
+	}
+}
+
+func TestEventsNeedsAllOfTheBounds(t *testing.T) {
+	s := &WebService{DB: &mockDB{}, Broker: events.NewBroker()}
+
+	req, err := http.NewRequest("GET", "/events?minLat=53.63&maxLat=53.84", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	s.Events().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d when only some of the bounds are given but got %d", http.StatusBadRequest, rr.Code)
+	}
+}
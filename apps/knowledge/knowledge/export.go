@@ -0,0 +1,372 @@
+package knowledge
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// exporter writes records out in a file format, begin is called before the
+// first record and end after the last
+type exporter interface {
+	begin() error
+	write(r types.Record) error
+	end() error
+}
+
+// exportFormat describes how to export records in one of the formats
+type exportFormat struct {
+	contentType string
+	extension   string
+	new         func(w io.Writer, columns []exportColumn) exporter
+}
+
+var exportFormats = map[string]exportFormat{
+	"geojson": {"application/geo+json", "geojson", newGeoJSONExporter},
+	"csv":     {"text/csv", "csv", newCSVExporter},
+	"kml":     {"application/vnd.google-earth.kml+xml", "kml", newKMLExporter},
+}
+
+// exportColumn is a detail field of the records being exported, labelled by
+// the name of the field
+type exportColumn struct {
+	key   string
+	label string
+}
+
+// Export writes every record matching the filters out as GeoJSON, CSV or KML.
+// The same filters as Search can be used, but an area isn't needed and every
+// matching record is written rather than a page of them. Records are written
+// as they are read rather than all at once.
+// Path: /record/export
+// Method: GET
+// Parameters:
+//		format - (optional) geojson (default), csv or kml
+//		query, minLat, maxLat, minLng, maxLng, lat, lng, radius, facility,
+//				facilityMatch, details.{key}[{operator}] - (optional) filters
+//				as for Search
+// Example: /record/export?format=csv&facility=TAXI
+// Details are written as properties, columns or extended data named by the
+// label of their field.
+func (s *WebService) Export() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "export",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.ToLower(r.URL.Query().Get("format"))
+		if name == "" {
+			name = "geojson"
+		}
+
+		format, ok := exportFormats[name]
+		if !ok {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"format": name,
+			}).Warn("Unknown export format")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Format must be geojson, csv or kml"})
+			return
+		}
+
+		query := &types.SearchQuery{}
+		status, err := s.filterQuery(s.db(r), r.URL.Query(), query)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": status,
+				"error":  err.Error(),
+			}).Warn("Invalid export")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		fields, err := s.db(r).Fields()
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+			}).Error("Failed to get fields")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to export records"})
+			return
+		}
+
+		exp := format.new(w, exportColumns(fields))
+		started := false
+		start := func() error {
+			started = true
+			w.Header().Set("Content-Type", format.contentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"records.%s\"", format.extension))
+			w.WriteHeader(http.StatusOK)
+			return exp.begin()
+		}
+
+		count := 0
+		err = s.db(r).Export(*query, func(record types.Record) error {
+			if !started {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+			count++
+			return exp.write(record)
+		})
+		if err == nil && !started {
+			err = start()
+		}
+		if err == nil {
+			err = exp.end()
+		}
+
+		if err != nil {
+			if !started {
+				logger.WithFields(log.Fields{
+					"status": 500,
+					"error":  err.Error(),
+				}).Error("Failed to export records")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to export records"})
+				return
+			}
+
+			logger.WithFields(log.Fields{
+				"error":   err.Error(),
+				"written": count,
+			}).Error("Export stopped part way through")
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"format": name,
+			"count":  count,
+		}).Info("Exported records")
+	}
+}
+
+// exportColumns lists the detail fields in order, the description comes
+// first when there isn't a field for it
+func exportColumns(fields []types.Field) []exportColumn {
+	ordered := append([]types.Field(nil), fields...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Order < ordered[j].Order
+	})
+
+	var columns []exportColumn
+	description := false
+	for _, field := range ordered {
+		if field.Key() == types.DescriptionKey {
+			description = true
+		}
+		columns = append(columns, exportColumn{key: field.Key(), label: field.Value})
+	}
+
+	if !description {
+		columns = append([]exportColumn{{key: types.DescriptionKey, label: "Description"}}, columns...)
+	}
+
+	return columns
+}
+
+// labelledDetails returns the details of a record keyed by the label of their
+// field, details without a field keep their key
+func labelledDetails(columns []exportColumn, details map[string]interface{}) map[string]interface{} {
+	labelled := make(map[string]interface{}, len(details))
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known[column.key] = true
+		if value, ok := details[column.key]; ok {
+			labelled[column.label] = value
+		}
+	}
+
+	for key, value := range details {
+		if !known[key] {
+			labelled[key] = value
+		}
+	}
+
+	return labelled
+}
+
+func detailText(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+type geoJSONExporter struct {
+	w       io.Writer
+	columns []exportColumn
+	count   int
+}
+
+type exportFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   exportPoint            `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type exportPoint struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+func newGeoJSONExporter(w io.Writer, columns []exportColumn) exporter {
+	return &geoJSONExporter{w: w, columns: columns}
+}
+
+func (e *geoJSONExporter) begin() error {
+	_, err := io.WriteString(e.w, `{"type":"FeatureCollection","features":[`)
+	return err
+}
+
+func (e *geoJSONExporter) write(r types.Record) error {
+	properties := labelledDetails(e.columns, r.Details)
+	properties["id"] = r.ID
+	properties["title"] = r.Title
+	properties["shortName"] = r.ShortName
+	properties["country"] = r.Location.Country
+	properties["facilities"] = r.Facilities
+	properties["version"] = r.Version
+
+	feature, err := json.Marshal(exportFeature{
+		Type:       "Feature",
+		Geometry:   exportPoint{Type: "Point", Coordinates: []float64{r.Location.Lng, r.Location.Lat}},
+		Properties: properties,
+	})
+	if err != nil {
+		return err
+	}
+
+	if e.count > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.count++
+
+	_, err = e.w.Write(feature)
+	return err
+}
+
+func (e *geoJSONExporter) end() error {
+	_, err := io.WriteString(e.w, "]}\n")
+	return err
+}
+
+type csvExporter struct {
+	w       *csv.Writer
+	columns []exportColumn
+}
+
+func newCSVExporter(w io.Writer, columns []exportColumn) exporter {
+	return &csvExporter{w: csv.NewWriter(w), columns: columns}
+}
+
+func (e *csvExporter) begin() error {
+	header := []string{"id", "title", "shortName", "lat", "lng", "country", "facilities"}
+	for _, column := range e.columns {
+		header = append(header, column.label)
+	}
+	return e.w.Write(header)
+}
+
+func (e *csvExporter) write(r types.Record) error {
+	row := []string{
+		r.ID,
+		r.Title,
+		r.ShortName,
+		strconv.FormatFloat(r.Location.Lat, 'f', -1, 64),
+		strconv.FormatFloat(r.Location.Lng, 'f', -1, 64),
+		r.Location.Country,
+		strings.Join(r.Facilities, ";"),
+	}
+	for _, column := range e.columns {
+		row = append(row, detailText(r.Details[column.key]))
+	}
+	return e.w.Write(row)
+}
+
+func (e *csvExporter) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type kmlExporter struct {
+	w       io.Writer
+	encoder *xml.Encoder
+	columns []exportColumn
+}
+
+type placemark struct {
+	XMLName     xml.Name  `xml:"Placemark"`
+	ID          string    `xml:"id,attr"`
+	Name        string    `xml:"name"`
+	Description string    `xml:"description,omitempty"`
+	Data        []kmlData `xml:"ExtendedData>Data"`
+	Coordinates string    `xml:"Point>coordinates"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+func newKMLExporter(w io.Writer, columns []exportColumn) exporter {
+	return &kmlExporter{w: w, encoder: xml.NewEncoder(w), columns: columns}
+}
+
+func (e *kmlExporter) begin() error {
+	_, err := io.WriteString(e.w, xml.Header+`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>`)
+	return err
+}
+
+func (e *kmlExporter) write(r types.Record) error {
+	details := labelledDetails(e.columns, r.Details)
+	labels := make([]string, 0, len(details))
+	for label := range details {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	data := []kmlData{
+		{Name: "shortName", Value: r.ShortName},
+		{Name: "country", Value: r.Location.Country},
+		{Name: "facilities", Value: strings.Join(r.Facilities, ";")},
+	}
+	for _, label := range labels {
+		data = append(data, kmlData{Name: label, Value: detailText(details[label])})
+	}
+
+	return e.encoder.Encode(placemark{
+		ID:          r.ID,
+		Name:        r.Title,
+		Description: detailText(r.Details[types.DescriptionKey]),
+		Data:        data,
+		Coordinates: fmt.Sprintf("%s,%s",
+			strconv.FormatFloat(r.Location.Lng, 'f', -1, 64),
+			strconv.FormatFloat(r.Location.Lat, 'f', -1, 64)),
+	})
+}
+
+func (e *kmlExporter) end() error {
+	_, err := io.WriteString(e.w, "</Document></kml>\n")
+	return err
+}
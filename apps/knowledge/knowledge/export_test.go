@@ -0,0 +1,168 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+var exportRecords = []types.Record{
+	{
+		ID:         "1",
+		Title:      "Leeds Bradford",
+		Facilities: []string{"TAXI", "BUS"},
+		Details:    map[string]interface{}{"description": "Airport", "paintColour": "Red", "runways": 1.0},
+		Version:    2,
+	},
+	{ID: "2", Title: "Leeds Station", Details: map[string]interface{}{}},
+}
+
+func exportDB(passed *types.SearchQuery) *mockDB {
+	return &mockDB{
+		GetFieldsFunc: func() ([]types.Field, error) {
+			return []types.Field{
+				{ID: "2", Value: "Runways", Order: 2, Type: types.FieldNumber},
+				{ID: "1", Value: "Paint Colour", Order: 1},
+			}, nil
+		},
+		ExportFunc: func(query types.SearchQuery, each func(types.Record) error) error {
+			*passed = query
+			for _, r := range exportRecords {
+				r.Location.Lat = 53.86
+				r.Location.Lng = -1.66
+				if err := each(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func TestExportGeoJSONUsesFieldLabels(t *testing.T) {
+	var passed types.SearchQuery
+	service := &WebService{DB: exportDB(&passed)}
+
+	req, err := http.NewRequest("GET", "/record/export?facility=TAXI", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Export()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected OK (200) status to be returned got %d", rr.Code)
+	}
+
+	if rr.Header().Get("Content-Type") != "application/geo+json" {
+		t.Errorf("Expected GeoJSON content type but got %s", rr.Header().Get("Content-Type"))
+	}
+
+	if len(passed.Facilities) != 1 || passed.Facilities[0] != "TAXI" {
+		t.Errorf("Expected the facility filter to be passed to the database but got %v", passed.Facilities)
+	}
+
+	var collection struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Coordinates []float64
+			}
+			Properties map[string]interface{}
+		}
+	}
+	ok(t, json.NewDecoder(rr.Body).Decode(&collection))
+
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 2 {
+		t.Fatalf("Expected a FeatureCollection of 2 features but got %+v", collection)
+	}
+
+	properties := collection.Features[0].Properties
+	if properties["Paint Colour"] != "Red" || properties["Runways"] != 1.0 || properties["Description"] != "Airport" || properties["title"] != "Leeds Bradford" {
+		t.Errorf("Expected details to be labelled by their field but got %v", properties)
+	}
+
+	if coordinates := collection.Features[0].Geometry.Coordinates; coordinates[0] != -1.66 || coordinates[1] != 53.86 {
+		t.Errorf("Expected coordinates -1.66,53.86 but got %v", coordinates)
+	}
+}
+
+func TestExportCSVHasAColumnForEachField(t *testing.T) {
+	var passed types.SearchQuery
+	service := &WebService{DB: exportDB(&passed)}
+
+	req, err := http.NewRequest("GET", "/record/export?format=csv", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Export()).ServeHTTP(rr, req)
+
+	expected := "id,title,shortName,lat,lng,country,facilities,Description,Paint Colour,Runways\n" +
+		"1,Leeds Bradford,,53.86,-1.66,,TAXI;BUS,Airport,Red,1\n" +
+		"2,Leeds Station,,53.86,-1.66,,,,,\n"
+	if rr.Body.String() != expected {
+		t.Errorf("Expected CSV: \n%s\n but got: \n%s", expected, rr.Body.String())
+	}
+}
+
+func TestExportKMLWritesPlacemarks(t *testing.T) {
+	var passed types.SearchQuery
+	service := &WebService{DB: exportDB(&passed)}
+
+	req, err := http.NewRequest("GET", "/record/export?format=kml", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Export()).ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	for _, expected := range []string{
+		`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>`,
+		`<Placemark id="1"><name>Leeds Bradford</name><description>Airport</description>`,
+		`<Data name="Paint Colour"><value>Red</value></Data>`,
+		`<Point><coordinates>-1.66,53.86</coordinates></Point>`,
+		`</Document></kml>`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected KML to contain %s but got %s", expected, body)
+		}
+	}
+}
+
+func TestExportRejectsUnknownFormat(t *testing.T) {
+	var passed types.SearchQuery
+	service := &WebService{DB: exportDB(&passed)}
+
+	req, err := http.NewRequest("GET", "/record/export?format=shp", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Export()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestExportReturnsServerErrorWhenDBFailsBeforeWriting(t *testing.T) {
+	var passed types.SearchQuery
+	db := exportDB(&passed)
+	db.ExportFunc = func(query types.SearchQuery, each func(types.Record) error) error {
+		return errors.New("Database failed")
+	}
+	service := &WebService{DB: db}
+
+	req, err := http.NewRequest("GET", "/record/export?format=csv", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Export()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected Internal Server Error (500) status to be returned got %d", rr.Code)
+	}
+}
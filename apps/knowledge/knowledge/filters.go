@@ -0,0 +1,116 @@
+package knowledge
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/dyninc/qstring"
+)
+
+const detailsPrefix = "details."
+
+// parseDetailFilters reads the details.{key}[{operator}] query params into
+// filters, the operator defaults to eq when it isn't given
+func parseDetailFilters(params url.Values) ([]types.DetailFilter, error) {
+	var names []string
+	for name := range params {
+		if strings.HasPrefix(name, detailsPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var filters []types.DetailFilter
+	for _, name := range names {
+		key := strings.TrimPrefix(name, detailsPrefix)
+		operator := types.OperatorEq
+
+		if open := strings.Index(key, "["); open != -1 && strings.HasSuffix(key, "]") {
+			operator = key[open+1 : len(key)-1]
+			key = key[:open]
+		}
+
+		if key == "" || strings.ContainsAny(key, ".$[]") {
+			return nil, fmt.Errorf("Invalid detail filter %q", name)
+		}
+
+		switch operator {
+		case types.OperatorEq, types.OperatorContains, types.OperatorExists, types.OperatorGt, types.OperatorLt:
+		default:
+			return nil, fmt.Errorf("Unknown operator %q for detail %s, expected eq, contains, exists, gt or lt", operator, key)
+		}
+
+		for _, value := range params[name] {
+			if operator == types.OperatorExists {
+				if _, err := strconv.ParseBool(value); err != nil {
+					return nil, fmt.Errorf("Detail %s exists must be true or false", key)
+				}
+			}
+
+			filters = append(filters, types.DetailFilter{
+				Key:      key,
+				Operator: operator,
+				Value:    value,
+			})
+		}
+	}
+
+	return filters, nil
+}
+
+// resolveDetailFilters checks each filter refers to a defined field, filters
+// can use either the fields key or its id which is converted to the key the
+// value is stored under
+func resolveDetailFilters(fields []types.Field, filters []types.DetailFilter) ([]types.DetailFilter, error) {
+	keys := map[string]string{types.DescriptionKey: types.DescriptionKey}
+	for _, field := range fields {
+		keys[field.Key()] = field.Key()
+		keys[field.ID] = field.Key()
+	}
+
+	resolved := make([]types.DetailFilter, 0, len(filters))
+	for _, filter := range filters {
+		key, ok := keys[filter.Key]
+		if !ok {
+			return nil, fmt.Errorf("Unknown detail field %q", filter.Key)
+		}
+		filter.Key = key
+		resolved = append(resolved, filter)
+	}
+
+	return resolved, nil
+}
+
+// filterQuery reads the filters of a search from the params for requests
+// that work on every matching record rather than an area, the status to
+// respond with is returned along with the error when they're invalid
+func (s *WebService) filterQuery(db database.Database, params url.Values, query *types.SearchQuery) (int, error) {
+	if db == nil {
+		return http.StatusInternalServerError, errors.New("Unable to connect to database")
+	}
+
+	if err := qstring.Unmarshal(params, query); err != nil {
+		return http.StatusBadRequest, errors.New("Unable to parse search parameters")
+	}
+
+	var err error
+	query.Details, err = parseDetailFilters(params)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if query.Radius != 0 {
+		if err := validRadius(*query, params); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	return s.checkQuery(db, query)
+}
@@ -0,0 +1,77 @@
+package knowledge
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+func TestParseDetailFiltersDefaultsToEq(t *testing.T) {
+	params, _ := url.ParseQuery("query=Leeds&details.description=Airport")
+
+	filters, err := parseDetailFilters(params)
+	ok(t, err)
+
+	expected := []types.DetailFilter{{Key: "description", Operator: types.OperatorEq, Value: "Airport"}}
+	if !reflect.DeepEqual(filters, expected) {
+		t.Errorf("Expected %v but got %v", expected, filters)
+	}
+}
+
+func TestParseDetailFiltersReadsOperators(t *testing.T) {
+	params, _ := url.ParseQuery("details.runways[gt]=1&details.runways[lt]=4&details.website[exists]=true")
+
+	filters, err := parseDetailFilters(params)
+	ok(t, err)
+
+	expected := []types.DetailFilter{
+		{Key: "runways", Operator: types.OperatorGt, Value: "1"},
+		{Key: "runways", Operator: types.OperatorLt, Value: "4"},
+		{Key: "website", Operator: types.OperatorExists, Value: "true"},
+	}
+	if !reflect.DeepEqual(filters, expected) {
+		t.Errorf("Expected %v but got %v", expected, filters)
+	}
+}
+
+func TestParseDetailFiltersRejectsUnknownOperator(t *testing.T) {
+	params, _ := url.ParseQuery("details.runways[between]=1")
+
+	if _, err := parseDetailFilters(params); err == nil {
+		t.Error("Expected an error for an unknown operator")
+	}
+}
+
+func TestParseDetailFiltersRejectsInvalidExists(t *testing.T) {
+	params, _ := url.ParseQuery("details.website[exists]=maybe")
+
+	if _, err := parseDetailFilters(params); err == nil {
+		t.Error("Expected an error for a non boolean exists")
+	}
+}
+
+func TestResolveDetailFiltersConvertsIdsToKeys(t *testing.T) {
+	fields := []types.Field{{ID: "abc-123", Value: "Station Postcode"}}
+	filters := []types.DetailFilter{
+		{Key: "abc-123", Operator: types.OperatorEq, Value: "LS1"},
+		{Key: "stationPostcode", Operator: types.OperatorEq, Value: "LS2"},
+		{Key: "description", Operator: types.OperatorEq, Value: "Airport"},
+	}
+
+	resolved, err := resolveDetailFilters(fields, filters)
+	ok(t, err)
+
+	if resolved[0].Key != "stationPostcode" || resolved[1].Key != "stationPostcode" || resolved[2].Key != "description" {
+		t.Errorf("Expected keys stationPostcode, stationPostcode, description but got %v", resolved)
+	}
+}
+
+func TestResolveDetailFiltersRejectsUnknownFields(t *testing.T) {
+	filters := []types.DetailFilter{{Key: "unknown", Operator: types.OperatorEq, Value: "1"}}
+
+	if _, err := resolveDetailFilters(nil, filters); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
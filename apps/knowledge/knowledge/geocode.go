@@ -0,0 +1,124 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultGeocodeLimit is how many matches /geocode returns when no limit is
+// given
+const defaultGeocodeLimit = 10
+
+// errGeocodingDisabled is returned when an address can't be looked up as no
+// geocoder is set
+var errGeocodingDisabled = errors.New("Geocoding is disabled")
+
+// Geocode finds the places an address could be, best first
+// Path: /geocode
+// Method: GET
+// Parameters:
+//		q - address to look up, such as a postcode or town
+//		limit - (optional) most places to return, defaults to 10
+// Example: /geocode?q=LS16%207LP
+// Each place has its name, lat, lng, countryCode and region when known, and a
+// score from 0 to 1 of how much of the address it accounts for. 404 is
+// returned with an empty list when nothing matches.
+func (s *WebService) Geocode() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "geocode",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		address := r.URL.Query().Get("q")
+		if address == "" {
+			logger.WithFields(log.Fields{
+				"status": 400,
+			}).Warn("No address provided")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "No address provided, set q"})
+			return
+		}
+
+		limit := defaultGeocodeLimit
+		if l := r.URL.Query().Get("limit"); l != "" {
+			var err error
+			limit, err = strconv.Atoi(l)
+			if err != nil || limit < 1 {
+				logger.WithFields(log.Fields{
+					"status": 400,
+					"limit":  l,
+				}).Warn("Invalid limit")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: "Limit must be a number greater than 0"})
+				return
+			}
+		}
+
+		if s.Geocoder == nil {
+			logger.WithFields(log.Fields{
+				"status": 501,
+			}).Warn("Geocoding is disabled")
+			w.WriteHeader(http.StatusNotImplemented)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: errGeocodingDisabled.Error()})
+			return
+		}
+
+		matches, err := s.Geocoder.Forward(address, limit)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status":  500,
+				"address": address,
+				"error":   err.Error(),
+			}).Error("Failed to geocode address")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to geocode address"})
+			return
+		}
+
+		if len(matches) == 0 {
+			logger.WithFields(log.Fields{
+				"status":  404,
+				"address": address,
+			}).Info("Address not found")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(matches)
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status":  200,
+			"address": address,
+			"matches": len(matches),
+		}).Info("Geocoded address")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(matches)
+	}
+}
+
+// resolveAddress places the record at the place named by the address of its
+// location. Only a place matching the whole address is used, rather than
+// guessing from part of it or completing it.
+func (s *WebService) resolveAddress(rec *types.Record) error {
+	if s.Geocoder == nil {
+		return errGeocodingDisabled
+	}
+
+	matches, err := s.Geocoder.Forward(rec.Location.Address, 1)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 || matches[0].Score < 1 {
+		return errors.New("Address not found, it must be a place or postcode /geocode matches exactly: " + rec.Location.Address)
+	}
+
+	rec.Location.Lat = matches[0].Lat
+	rec.Location.Lng = matches[0].Lng
+	return nil
+}
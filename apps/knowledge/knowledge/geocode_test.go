@@ -0,0 +1,102 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/geo"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+// stubGeocoder finds the place it holds for exactly its address
+type stubGeocoder map[string]geo.Match
+
+func (g stubGeocoder) Forward(address string, limit int) ([]geo.Match, error) {
+	matches := []geo.Match{}
+	if m, ok := g[address]; ok {
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+var leeds = stubGeocoder{
+	"LS1 4AP": {Name: "LS1 4AP", Lat: 53.796, Lng: -1.5479, CountryCode: "GB", Score: 1},
+	"Le":      {Name: "Leeds", Lat: 53.7997, Lng: -1.5492, CountryCode: "GB", Score: 0.2},
+}
+
+func TestGeocodeReturnsMatches(t *testing.T) {
+	service := &WebService{Geocoder: leeds}
+
+	req, err := http.NewRequest("GET", "/geocode?q=LS1%204AP", nil)
+	ok(t, err)
+	rr := httptest.NewRecorder()
+	service.Geocode().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var matches []geo.Match
+	ok(t, json.NewDecoder(rr.Body).Decode(&matches))
+	if len(matches) != 1 || matches[0].Lat != 53.796 {
+		t.Errorf("Expected LS1 4AP to be found but got %v", matches)
+	}
+}
+
+func TestGeocodeStatuses(t *testing.T) {
+	tests := []struct {
+		url      string
+		geocoder geo.ForwardGeocoder
+		status   int
+	}{
+		{"/geocode", leeds, http.StatusBadRequest},
+		{"/geocode?q=LS1%204AP&limit=0", leeds, http.StatusBadRequest},
+		{"/geocode?q=Atlantis", leeds, http.StatusNotFound},
+		{"/geocode?q=LS1%204AP", nil, http.StatusNotImplemented},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", test.url, nil)
+		ok(t, err)
+		rr := httptest.NewRecorder()
+		(&WebService{Geocoder: test.geocoder}).Geocode().ServeHTTP(rr, req)
+
+		if rr.Code != test.status {
+			t.Errorf("%s: expected %d but got %d", test.url, test.status, rr.Code)
+		}
+	}
+}
+
+func TestNewRecordResolvesAddress(t *testing.T) {
+	var created types.Record
+	db := &mockDB{
+		CreateFunc: func(r types.Record) (string, error) {
+			created = r
+			return "ABC123", nil
+		},
+	}
+	service := &WebService{DB: db, Geocoder: leeds}
+
+	req, err := http.NewRequest("POST", "/record", bytes.NewBufferString(`{"title":"Leeds","location":{"address":"LS1 4AP"}}`))
+	ok(t, err)
+	rr := httptest.NewRecorder()
+	service.NewRecord().ServeHTTP(rr, req)
+
+	if created.Location.Lat != 53.796 || created.Location.Lng != -1.5479 || created.Location.Address != "LS1 4AP" {
+		t.Errorf("Expected the record to be placed at its address but got %v", created.Location)
+	}
+
+	for _, address := range []string{"Atlantis", "Le"} {
+		created = types.Record{}
+		req, err = http.NewRequest("POST", "/record", bytes.NewBufferString(`{"title":"Somewhere","location":{"address":"`+address+`"}}`))
+		ok(t, err)
+		rr = httptest.NewRecorder()
+		service.NewRecord().ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest || created.Title != "" {
+			t.Errorf("Expected %d for %s, which isn't matched exactly, but got %d", http.StatusBadRequest, address, rr.Code)
+		}
+	}
+}
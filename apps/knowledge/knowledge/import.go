@@ -0,0 +1,529 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxImportSize is the largest file in bytes that can be imported at once
+const MaxImportSize = 10 << 20
+
+// importColumns are the values of a record that can be imported and the
+// names of the columns, or GeoJSON properties, they are read from when no
+// column is given for them. Names are matched ignoring case.
+var importColumns = map[string][]string{
+	"lat":        {"lat", "latitude"},
+	"lng":        {"lng", "lon", "long", "longitude"},
+	"title":      {"title", "name"},
+	"shortName":  {"shortName", "short name", "code"},
+	"country":    {"country"},
+	"facilities": {"facilities"},
+}
+
+// importMapping holds which column each value of a record is read from,
+// details maps detail keys to their column
+type importMapping struct {
+	columns map[string]string
+	details map[string]string
+}
+
+// importRow is a parsed row of an import waiting to be written, rows that
+// couldn't be parsed have an error and blank rows are skipped
+type importRow struct {
+	row    int
+	record types.Record
+	err    error
+	skip   string
+}
+
+// Import creates records in bulk from a CSV file, a GeoJSON FeatureCollection
+// of Points or a JSON array of records, reporting the outcome of each row.
+// Path: /record/import
+// Method: POST
+// Parameters:
+//		format - (optional) csv, geojson or json, taken from the Content-Type
+//				when not given
+//		dryRun - (optional) true to validate the rows without creating records
+//		lat, lng, title, shortName, country, facilities - (optional) the column
+//				or property to read the value from, by default a column with
+//				the same name is used. Facilities are separated by ; or ,
+//		details.{key} - (optional, repeatable) the column or property to read
+//				the detail field from, given by its key or id. Columns named
+//				after a field are read into it by default.
+// Example: /record/import?format=csv&dryRun=true&lat=Latitude&lng=Longitude&title=Station
+// Rows with the same title and location as an existing record are skipped.
+// The report lists how many rows were created, skipped and failed along with
+// the outcome of each row, rows are numbered from 1 not counting the header.
+// Files can be up to 10MB, larger ones get 413.
+func (s *WebService) Import() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "import",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Body == nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+			}).Warn("No body provided")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "No body provided"})
+			return
+		}
+
+		if s.DB == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to connect to database"})
+			logger.WithFields(log.Fields{
+				"status": 500,
+			}).Error("No database set")
+			return
+		}
+
+		fields, err := s.db(r).Fields()
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+			}).Error("Failed to get fields")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to import records"})
+			return
+		}
+
+		query := r.URL.Query()
+		dryRun, _ := strconv.ParseBool(query.Get("dryRun"))
+
+		body := &countingReader{ReadCloser: r.Body}
+		rows, err := parseImport(http.MaxBytesReader(w, body, MaxImportSize), importFormat(r), query, fields)
+		if err != nil && body.n > MaxImportSize {
+			logger.WithFields(log.Fields{
+				"status": 413,
+			}).Warn("Import is too large")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: fmt.Sprintf("Imports must be at most %dMB", MaxImportSize>>20)})
+			return
+		}
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Unable to read import")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		var records []types.Record
+		for _, row := range rows {
+			if row.err == nil && row.skip == "" {
+				records = append(records, row.record)
+			}
+		}
+
+		var results []types.ImportRow
+		if len(records) > 0 {
+			results, err = s.db(r).Import(records, dryRun)
+			if err != nil {
+				logger.WithFields(log.Fields{
+					"status": 500,
+					"error":  err.Error(),
+				}).Error("Failed to import records")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to import records"})
+				return
+			}
+			if len(results) != len(records) {
+				logger.WithFields(log.Fields{
+					"status":  500,
+					"records": len(records),
+					"results": len(results),
+				}).Error("Import didn't return a result for each record")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to import records"})
+				return
+			}
+		}
+
+		report := types.ImportReport{DryRun: dryRun, Rows: []types.ImportRow{}}
+		for _, row := range rows {
+			switch {
+			case row.err != nil:
+				report.Add(types.ImportRow{Row: row.row, Status: types.ImportFailed, Title: row.record.Title, Message: row.err.Error()})
+			case row.skip != "":
+				report.Add(types.ImportRow{Row: row.row, Status: types.ImportSkipped, Message: row.skip})
+			default:
+				result := results[0]
+				results = results[1:]
+				result.Row = row.row
+				report.Add(result)
+			}
+		}
+
+		logger.WithFields(log.Fields{
+			"status":  200,
+			"dryRun":  dryRun,
+			"created": report.Created,
+			"skipped": report.Skipped,
+			"failed":  report.Failed,
+		}).Info("Imported records")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// importFormat returns the format of the import from the format parameter,
+// or the content type of the request
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.ToLower(format)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/geo+json":
+		return "geojson"
+	}
+	return "json"
+}
+
+// parseImport reads the rows of an import in the format
+func parseImport(body io.Reader, format string, query url.Values, fields []types.Field) ([]importRow, error) {
+	switch format {
+	case "csv":
+		return parseCSV(body, query, fields)
+	case "geojson":
+		return parseGeoJSON(body, query, fields)
+	case "json":
+		content, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		content = bytes.TrimSpace(content)
+		if len(content) > 0 && content[0] == '{' {
+			return parseGeoJSON(bytes.NewReader(content), query, fields)
+		}
+		return parseRecords(bytes.NewReader(content))
+	}
+	return nil, fmt.Errorf("Unknown import format %q, expected csv, geojson or json", format)
+}
+
+// parseCSV reads a CSV file with a header row
+func parseCSV(body io.Reader, query url.Values, fields []types.Field) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("Unable to read the CSV header row")
+	}
+
+	mapping, err := newImportMapping(query, headers, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.columns["lat"] == "" || mapping.columns["lng"] == "" {
+		return nil, errors.New("CSV must have latitude and longitude columns, set lat and lng to the names of the columns")
+	}
+
+	index := make(map[string]int)
+	for i, header := range headers {
+		index[header] = i
+	}
+
+	var rows []importRow
+	for n := 1; ; n++ {
+		cells, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); !ok {
+				return nil, err
+			}
+			rows = append(rows, importRow{row: n, err: err})
+			continue
+		}
+
+		if blank(cells) {
+			rows = append(rows, importRow{row: n, skip: "Empty row"})
+			continue
+		}
+
+		get := func(column string) interface{} {
+			i, ok := index[column]
+			if column == "" || !ok || i >= len(cells) {
+				return nil
+			}
+			return cells[i]
+		}
+
+		row := importRow{row: n, record: mapping.record(get)}
+		row.record.Location.Lat, row.record.Location.Lng, row.err = importLocation(get(mapping.columns["lat"]), get(mapping.columns["lng"]))
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// featureCollection is a GeoJSON FeatureCollection
+type featureCollection struct {
+	Type     string `json:"type"`
+	Features []struct {
+		Geometry   *types.Geometry        `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	} `json:"features"`
+}
+
+// parseGeoJSON reads a GeoJSON FeatureCollection of Points. Properties are
+// read the same as CSV columns, a details property holding an object is read
+// straight into the details.
+func parseGeoJSON(body io.Reader, query url.Values, fields []types.Field) ([]importRow, error) {
+	var collection featureCollection
+	if err := json.NewDecoder(body).Decode(&collection); err != nil || collection.Type != "FeatureCollection" {
+		return nil, errors.New("Unable to parse GeoJSON, expected a FeatureCollection")
+	}
+
+	var properties []string
+	seen := make(map[string]bool)
+	for _, feature := range collection.Features {
+		for property := range feature.Properties {
+			if !seen[property] {
+				seen[property] = true
+				properties = append(properties, property)
+			}
+		}
+	}
+
+	mapping, err := newImportMapping(query, properties, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]importRow, 0, len(collection.Features))
+	for i, feature := range collection.Features {
+		get := func(property string) interface{} {
+			return feature.Properties[property]
+		}
+
+		row := importRow{row: i + 1, record: mapping.record(get)}
+		if details, ok := feature.Properties["details"].(map[string]interface{}); ok {
+			for key, value := range details {
+				row.record.Details[key] = value
+			}
+		}
+
+		var coordinates []float64
+		if feature.Geometry == nil || feature.Geometry.Type != "Point" || json.Unmarshal(feature.Geometry.Coordinates, &coordinates) != nil || len(coordinates) < 2 {
+			row.err = errors.New("Feature must have a Point geometry")
+		} else {
+			row.record.Location.Lat, row.record.Location.Lng, row.err = importLocation(coordinates[1], coordinates[0])
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseRecords reads a JSON array of records, as returned by a search
+func parseRecords(body io.Reader) ([]importRow, error) {
+	var records []types.Record
+	if err := json.NewDecoder(body).Decode(&records); err != nil {
+		return nil, errors.New("Unable to parse JSON, expected an array of records or a GeoJSON FeatureCollection")
+	}
+
+	rows := make([]importRow, 0, len(records))
+	for i, record := range records {
+		record.ID = ""
+		record.Version = 0
+		row := importRow{row: i + 1, record: record}
+		row.record.Location.Lat, row.record.Location.Lng, row.err = importLocation(record.Location.Lat, record.Location.Lng)
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// newImportMapping works out which column each value of a record is read
+// from, using the columns given in the query before the default names
+func newImportMapping(query url.Values, columns []string, fields []types.Field) (importMapping, error) {
+	mapping := importMapping{columns: make(map[string]string), details: make(map[string]string)}
+
+	find := func(name string) string {
+		for _, column := range columns {
+			if strings.EqualFold(strings.TrimSpace(column), name) {
+				return column
+			}
+		}
+		return ""
+	}
+
+	used := make(map[string]bool)
+	for value, defaults := range importColumns {
+		if name := query.Get(value); name != "" {
+			column := find(name)
+			if column == "" {
+				return mapping, fmt.Errorf("Column %q for %s is not in the import", name, value)
+			}
+			mapping.columns[value] = column
+			used[column] = true
+			continue
+		}
+
+		for _, name := range defaults {
+			if column := find(name); column != "" {
+				mapping.columns[value] = column
+				used[column] = true
+				break
+			}
+		}
+	}
+
+	keys := map[string]string{types.DescriptionKey: types.DescriptionKey}
+	for _, field := range fields {
+		keys[field.Key()] = field.Key()
+		keys[field.ID] = field.Key()
+	}
+
+	for param, names := range query {
+		if !strings.HasPrefix(param, "details.") {
+			continue
+		}
+		key, ok := keys[strings.TrimPrefix(param, "details.")]
+		if !ok {
+			return mapping, fmt.Errorf("Unknown detail field %q", strings.TrimPrefix(param, "details."))
+		}
+		column := find(names[0])
+		if column == "" {
+			return mapping, fmt.Errorf("Column %q for %s is not in the import", names[0], param)
+		}
+		mapping.details[key] = column
+		used[column] = true
+	}
+
+	for _, column := range columns {
+		if used[column] {
+			continue
+		}
+		key := types.FieldKey(strings.TrimSpace(column))
+		for _, field := range fields {
+			if strings.EqualFold(field.Value, strings.TrimSpace(column)) || strings.EqualFold(field.Key(), key) {
+				key = field.Key()
+				break
+			}
+		}
+		if mapped, ok := keys[key]; ok {
+			if _, taken := mapping.details[mapped]; !taken {
+				mapping.details[mapped] = column
+			}
+		}
+	}
+
+	return mapping, nil
+}
+
+// record builds a record from a row, get returns the value of a column
+func (m importMapping) record(get func(column string) interface{}) types.Record {
+	r := types.Record{
+		Title:     text(get(m.columns["title"])),
+		ShortName: text(get(m.columns["shortName"])),
+		Details:   make(map[string]interface{}),
+	}
+	r.Location.Country = text(get(m.columns["country"]))
+
+	switch facilities := get(m.columns["facilities"]).(type) {
+	case []interface{}:
+		for _, facility := range facilities {
+			if f := strings.ToUpper(text(facility)); f != "" {
+				r.Facilities = append(r.Facilities, f)
+			}
+		}
+	case string:
+		for _, facility := range strings.FieldsFunc(facilities, func(c rune) bool { return c == ';' || c == ',' }) {
+			if f := strings.ToUpper(strings.TrimSpace(facility)); f != "" {
+				r.Facilities = append(r.Facilities, f)
+			}
+		}
+	}
+
+	for key, column := range m.details {
+		value := get(column)
+		if s, ok := value.(string); ok {
+			value = strings.TrimSpace(s)
+		}
+		if value != nil && value != "" {
+			r.Details[key] = value
+		}
+	}
+
+	return r
+}
+
+// importLocation reads and checks the latitude and longitude of a row
+func importLocation(latValue, lngValue interface{}) (float64, float64, error) {
+	lat, err := number(latValue)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Latitude %s", err.Error())
+	}
+
+	lng, err := number(lngValue)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Longitude %s", err.Error())
+	}
+
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return 0, 0, errors.New("Latitude must be between -90 and 90 and longitude between -180 and 180")
+	}
+
+	return lat, lng, nil
+}
+
+func number(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return n, nil
+		}
+	case nil:
+		return 0, errors.New("is missing")
+	}
+	return 0, errors.New("must be a number")
+}
+
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64, bool:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func blank(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
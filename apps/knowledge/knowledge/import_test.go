@@ -0,0 +1,227 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+var importFields = []types.Field{
+	{ID: "1", Value: "Paint Colour"},
+	{ID: "2", Value: "Runways", Type: types.FieldNumber},
+}
+
+func importDB(passed *[]types.Record, passedDryRun *bool) *mockDB {
+	return &mockDB{
+		GetFieldsFunc: func() ([]types.Field, error) {
+			return importFields, nil
+		},
+		ImportFunc: func(records []types.Record, dryRun bool) ([]types.ImportRow, error) {
+			*passed = records
+			*passedDryRun = dryRun
+			results := make([]types.ImportRow, len(records))
+			for i, r := range records {
+				results[i] = types.ImportRow{Status: types.ImportCreated, Title: r.Title}
+			}
+			return results, nil
+		},
+	}
+}
+
+func TestImportCSVMapsColumnsOntoRecords(t *testing.T) {
+	var passed []types.Record
+	var dryRun bool
+	service := &WebService{DB: importDB(&passed, &dryRun)}
+
+	csv := "Station,Latitude,Longitude,Facilities,Paint Colour,Notes\n" +
+		"Leeds,53.7955,-1.5475,taxi;trn,Red,Busy\n" +
+		",,,,,\n" +
+		"York,north,-1.0933,,,\n"
+	req, err := http.NewRequest("POST", "/record/import?title=Station&lat=Latitude&lng=Longitude&dryRun=true", bytes.NewBufferString(csv))
+	ok(t, err)
+	req.Header.Set("Content-Type", "text/csv")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Import()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected OK (200) status to be returned got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if !dryRun {
+		t.Error("Expected the import to be a dry run")
+	}
+
+	if len(passed) != 1 {
+		t.Fatalf("Expected 1 valid record to be passed to the database but got %d", len(passed))
+	}
+
+	leeds := passed[0]
+	if leeds.Title != "Leeds" || leeds.Location.Lat != 53.7955 || leeds.Location.Lng != -1.5475 {
+		t.Errorf("Expected Leeds at 53.7955,-1.5475 but got %+v", leeds)
+	}
+
+	if len(leeds.Facilities) != 2 || leeds.Facilities[0] != "TAXI" || leeds.Facilities[1] != "TRN" {
+		t.Errorf("Expected facilities TAXI and TRN but got %v", leeds.Facilities)
+	}
+
+	if leeds.Details["paintColour"] != "Red" || len(leeds.Details) != 1 {
+		t.Errorf("Expected only the paint colour detail to be read but got %v", leeds.Details)
+	}
+
+	var report types.ImportReport
+	ok(t, json.NewDecoder(rr.Body).Decode(&report))
+
+	if report.Created != 1 || report.Skipped != 1 || report.Failed != 1 || len(report.Rows) != 3 {
+		t.Errorf("Expected 1 created, 1 skipped and 1 failed row but got %+v", report)
+	}
+
+	if report.Rows[2].Row != 3 || report.Rows[2].Status != types.ImportFailed {
+		t.Errorf("Expected row 3 to fail but got %+v", report.Rows[2])
+	}
+}
+
+func TestImportCSVRequiresLocationColumns(t *testing.T) {
+	var passed []types.Record
+	var dryRun bool
+	service := &WebService{DB: importDB(&passed, &dryRun)}
+
+	req, err := http.NewRequest("POST", "/record/import?format=csv", bytes.NewBufferString("title,country\nLeeds,UK\n"))
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Import()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestImportGeoJSONReadsPointsAndProperties(t *testing.T) {
+	var passed []types.Record
+	var dryRun bool
+	service := &WebService{DB: importDB(&passed, &dryRun)}
+
+	geojson := `{"type":"FeatureCollection","features":[
+		{"type":"Feature","geometry":{"type":"Point","coordinates":[-1.66,53.87]},
+			"properties":{"name":"Leeds Bradford","facilities":["bus"],"details":{"runways":"1"}}},
+		{"type":"Feature","geometry":{"type":"LineString","coordinates":[[0,0],[1,1]]},"properties":{"name":"Road"}}
+	]}`
+	req, err := http.NewRequest("POST", "/record/import", bytes.NewBufferString(geojson))
+	ok(t, err)
+	req.Header.Set("Content-Type", "application/geo+json")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Import()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected OK (200) status to be returned got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if dryRun {
+		t.Error("Expected the import not to be a dry run")
+	}
+
+	if len(passed) != 1 {
+		t.Fatalf("Expected 1 valid record to be passed to the database but got %d", len(passed))
+	}
+
+	if passed[0].Title != "Leeds Bradford" || passed[0].Location.Lat != 53.87 || passed[0].Location.Lng != -1.66 {
+		t.Errorf("Expected Leeds Bradford at 53.87,-1.66 but got %+v", passed[0])
+	}
+
+	if passed[0].Details["runways"] != "1" || passed[0].Facilities[0] != "BUS" {
+		t.Errorf("Expected details and facilities to be read but got %+v", passed[0])
+	}
+
+	var report types.ImportReport
+	ok(t, json.NewDecoder(rr.Body).Decode(&report))
+	if report.Created != 1 || report.Failed != 1 {
+		t.Errorf("Expected 1 created and 1 failed row but got %+v", report)
+	}
+}
+
+func TestImportJSONArrayOfRecords(t *testing.T) {
+	var passed []types.Record
+	var dryRun bool
+	service := &WebService{DB: importDB(&passed, &dryRun)}
+
+	records := `[{"id":"5b3b5238f228aa0d9ced5f1c","title":"London Gatwick","location":{"lat":51.15,"lng":-0.18}}]`
+	req, err := http.NewRequest("POST", "/record/import", bytes.NewBufferString(records))
+	ok(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Import()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected OK (200) status to be returned got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if len(passed) != 1 || passed[0].Title != "London Gatwick" || passed[0].ID != "" {
+		t.Errorf("Expected London Gatwick to be passed without its id but got %+v", passed)
+	}
+}
+
+func TestImportWithoutAResultForEachRecordIsAnError(t *testing.T) {
+	db := &mockDB{
+		GetFieldsFunc: func() ([]types.Field, error) {
+			return importFields, nil
+		},
+		ImportFunc: func(records []types.Record, dryRun bool) ([]types.ImportRow, error) {
+			return nil, nil
+		},
+	}
+	service := &WebService{DB: db}
+
+	records := `[{"title":"London Gatwick","location":{"lat":51.15,"lng":-0.18}}]`
+	req, err := http.NewRequest("POST", "/record/import", bytes.NewBufferString(records))
+	ok(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Import()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected Internal Server Error (500) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestImportOverTheLimitIsTooLarge(t *testing.T) {
+	var passed []types.Record
+	var dryRun bool
+	service := &WebService{DB: importDB(&passed, &dryRun)}
+
+	body := "title,lat,lng\n" + strings.Repeat("Leeds,53.8,-1.5\n", MaxImportSize/16+1)
+	req, err := http.NewRequest("POST", "/record/import", bytes.NewBufferString(body))
+	ok(t, err)
+	req.Header.Set("Content-Type", "text/csv")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(service.Import()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected Request Entity Too Large (413) status to be returned got %d", rr.Code)
+	}
+	if len(passed) != 0 {
+		t.Errorf("Expected nothing to be imported but got %d records", len(passed))
+	}
+}
+
+func TestImportMappingRejectsUnknownColumns(t *testing.T) {
+	for _, query := range []url.Values{
+		{"lat": {"Northing"}},
+		{"details.unknown": {"Notes"}},
+		{"details.paintColour": {"Colour"}},
+	} {
+		if _, err := newImportMapping(query, []string{"lat", "lng", "Notes"}, importFields); err == nil {
+			t.Errorf("Expected %v to be rejected", query)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// noteError writes the response for an error from the notes of a record,
+// 404 when the record or note doesn't exist otherwise 500
+func noteError(w http.ResponseWriter, logger *log.Entry, err error, id, message string) {
+	_, missingRecord := err.(*types.RecordNotFoundError)
+	_, missingNote := err.(*types.NoteNotFoundError)
+	if missingRecord || missingNote {
+		logger.WithFields(log.Fields{
+			"status": 404,
+			"id":     id,
+			"error":  err.Error(),
+		}).Warn("Record or note not found")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	logger.WithFields(log.Fields{
+		"status": 500,
+		"id":     id,
+		"error":  err.Error(),
+	}).Error(message)
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(&ErrorResponse{Message: message})
+}
+
+// readNote reads the note from the body, writing the response when it's
+// missing or invalid
+func readNote(w http.ResponseWriter, r *http.Request, logger *log.Entry) (types.Note, bool) {
+	var note types.Note
+
+	if r.Body == nil {
+		logger.WithFields(log.Fields{
+			"status": 400,
+		}).Warn("No body provided")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "No body provided"})
+		return note, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"status": 400,
+		}).Error("Unable to parse JSON")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to parse JSON"})
+		return note, false
+	}
+
+	if err := note.Validate(); err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"status": 400,
+		}).Warn("Invalid note")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+		return note, false
+	}
+
+	return note, true
+}
+
+// canChangeNote reports whether the request can edit or delete the note,
+// only its author and admins can
+func canChangeNote(r *http.Request, note types.Note) bool {
+	p, ok := auth.FromContext(r.Context())
+	return ok && (p.Name == note.Author || p.HasRole(auth.RoleAdmin))
+}
+
+// Notes lists the notes left on a record, oldest first
+// Path: /record/{id}/note
+// Method: GET
+// Example: /record/12345/note
+// The notes of a deleted record are hidden until it is restored.
+func (s *WebService) Notes() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "notes",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := getRecordID(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Issue with ID")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		notes, err := s.db(r).Notes(id)
+		if err != nil {
+			noteError(w, logger, err, id, "Failed to get notes")
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"id":     id,
+			"count":  len(notes),
+		}).Info("Listed notes")
+		json.NewEncoder(w).Encode(notes)
+	}
+}
+
+// AddNote leaves a note on a record, written by whoever made the request
+// Path: /record/{id}/note
+// Method: POST
+// Example: /record/12345/note
+//		Body: {
+//					"text": "Runway 2 closed for resurfacing until March",
+//					"url": "https://example.com/notices/123"
+//				}
+// The url is optional, it must be an http or https URL.
+func (s *WebService) AddNote() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "addNote",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := getRecordID(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Issue with ID")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		note, ok := readNote(w, r, logger)
+		if !ok {
+			return
+		}
+
+		note, err = s.db(r).AddNote(id, note)
+		if err != nil {
+			noteError(w, logger, err, id, "Failed to add note")
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 201,
+			"id":     id,
+			"note":   note.ID,
+		}).Info("Added note")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(note)
+	}
+}
+
+// UpdateNote changes the text and URL of a note, only its author or an admin
+// can
+// Path: /record/{id}/note/{note}
+// Method: PUT
+// Example: /record/12345/note/67890
+//		Body: {
+//					"text": "Runway 2 reopened"
+//				}
+func (s *WebService) UpdateNote() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "updateNote",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := getRecordID(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Issue with ID")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+		noteID := mux.Vars(r)["note"]
+
+		changes, ok := readNote(w, r, logger)
+		if !ok {
+			return
+		}
+
+		db := s.db(r)
+		note, err := db.GetNote(id, noteID)
+		if err != nil {
+			noteError(w, logger, err, id, "Failed to get note")
+			return
+		}
+
+		if !canChangeNote(r, note) {
+			logger.WithFields(log.Fields{
+				"status": 403,
+				"id":     id,
+				"note":   noteID,
+				"author": note.Author,
+			}).Warn("Only the author can edit a note")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Only the author of a note or an admin can edit it"})
+			return
+		}
+
+		changes.ID = noteID
+		note, err = db.UpdateNote(id, changes)
+		if err != nil {
+			noteError(w, logger, err, id, "Failed to update note")
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"id":     id,
+			"note":   noteID,
+		}).Info("Updated note")
+		json.NewEncoder(w).Encode(note)
+	}
+}
+
+// DeleteNote removes a note from a record, only its author or an admin can
+// Path: /record/{id}/note/{note}
+// Method: DELETE
+// Example: /record/12345/note/67890
+func (s *WebService) DeleteNote() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "deleteNote",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := getRecordID(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Issue with ID")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+		noteID := mux.Vars(r)["note"]
+
+		db := s.db(r)
+		note, err := db.GetNote(id, noteID)
+		if err != nil {
+			noteError(w, logger, err, id, "Failed to get note")
+			return
+		}
+
+		if !canChangeNote(r, note) {
+			logger.WithFields(log.Fields{
+				"status": 403,
+				"id":     id,
+				"note":   noteID,
+				"author": note.Author,
+			}).Warn("Only the author can delete a note")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Only the author of a note or an admin can delete it"})
+			return
+		}
+
+		if err := db.DeleteNote(id, noteID); err != nil {
+			noteError(w, logger, err, id, "Failed to delete note")
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"id":     id,
+			"note":   noteID,
+		}).Info("Deleted note")
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,128 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/gorilla/mux"
+)
+
+func noteRouter(s *WebService) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/record/{id}/note", s.Notes()).Methods("GET")
+	router.HandleFunc("/record/{id}/note", s.AddNote()).Methods("POST")
+	router.HandleFunc("/record/{id}/note/{note}", s.UpdateNote()).Methods("PUT")
+	router.HandleFunc("/record/{id}/note/{note}", s.DeleteNote()).Methods("DELETE")
+	return router
+}
+
+func noteDB() *mockDB {
+	return &mockDB{
+		NotesFunc: func(recordID string) ([]types.Note, error) {
+			if recordID != "12345" {
+				return nil, &types.RecordNotFoundError{ID: recordID, Message: "Record does not exist in the database."}
+			}
+			return []types.Note{{ID: "1", RecordID: recordID, Text: "Runway closed", Author: "sam"}}, nil
+		},
+		GetNoteFunc: func(recordID, noteID string) (types.Note, error) {
+			if noteID != "1" {
+				return types.Note{}, &types.NoteNotFoundError{ID: noteID, RecordID: recordID, Message: "Note does not exist in the database."}
+			}
+			return types.Note{ID: "1", RecordID: recordID, Text: "Runway closed", Author: "sam"}, nil
+		},
+		UpdateNoteFunc: func(recordID string, n types.Note) (types.Note, error) {
+			n.RecordID = recordID
+			n.Author = "sam"
+			return n, nil
+		},
+		DeleteNoteFunc: func(recordID, noteID string) error {
+			return nil
+		},
+	}
+}
+
+func TestAddNoteIsWrittenByTheActor(t *testing.T) {
+	var added types.Note
+	db := noteDB()
+	db.AddNoteFunc = func(recordID string, n types.Note) (types.Note, error) {
+		added = n
+		n.ID = "2"
+		n.Author = db.Actor
+		return n, nil
+	}
+
+	req, err := http.NewRequest("POST", "/record/12345/note", bytes.NewBufferString(`{"text":"Runway reopened","url":"https://example.com/notices/1"}`))
+	ok(t, err)
+	req.Header.Set("X-Actor", "alex")
+
+	rr := httptest.NewRecorder()
+	noteRouter(&WebService{DB: db}).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected Created (201) status to be returned got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var note types.Note
+	ok(t, json.NewDecoder(rr.Body).Decode(&note))
+	if added.Text != "Runway reopened" || added.URL != "https://example.com/notices/1" || note.Author != "alex" {
+		t.Errorf("Expected note to be added by alex but added %+v and returned %+v", added, note)
+	}
+}
+
+func TestInvalidNotesAreRejected(t *testing.T) {
+	for _, body := range []string{`{"text":"  "}`, `{"text":"See","url":"javascript:alert(1)"}`, `{"text":"See","url":"/relative"}`, `{`} {
+		req, err := http.NewRequest("POST", "/record/12345/note", bytes.NewBufferString(body))
+		ok(t, err)
+
+		rr := httptest.NewRecorder()
+		noteRouter(&WebService{DB: noteDB()}).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected Bad Request (400) for %s got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestNotesOfMissingRecordAreNotFound(t *testing.T) {
+	req, err := http.NewRequest("GET", "/record/67890/note", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	noteRouter(&WebService{DB: noteDB()}).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestOnlyAuthorOrAdminCanChangeNote(t *testing.T) {
+	tests := []struct {
+		principal auth.Principal
+		method    string
+		body      string
+		status    int
+	}{
+		{auth.Principal{Name: "alex", Roles: []string{auth.RoleEditor}}, "PUT", `{"text":"Changed"}`, http.StatusForbidden},
+		{auth.Principal{Name: "alex", Roles: []string{auth.RoleEditor}}, "DELETE", "", http.StatusForbidden},
+		{auth.Principal{Name: "sam", Roles: []string{auth.RoleEditor}}, "PUT", `{"text":"Changed"}`, http.StatusOK},
+		{auth.Principal{Name: "kim", Roles: []string{auth.RoleAdmin}}, "DELETE", "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, "/record/12345/note/1", bytes.NewBufferString(test.body))
+		ok(t, err)
+		req = req.WithContext(auth.NewContext(req.Context(), test.principal))
+
+		rr := httptest.NewRecorder()
+		noteRouter(&WebService{DB: noteDB()}).ServeHTTP(rr, req)
+
+		if rr.Code != test.status {
+			t.Errorf("Expected %d for %s by %s got %d", test.status, test.method, test.principal.Name, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MergePatchType is the content type of a JSON Merge Patch (RFC 7396)
+	MergePatchType = "application/merge-patch+json"
+	// JSONPatchType is the content type of a JSON Patch (RFC 6902)
+	JSONPatchType = "application/json-patch+json"
+)
+
+// Patch changes part of a record, leaving the rest of it as it is. The body
+// is a JSON Merge Patch, or a JSON Patch when sent with the content type
+// application/json-patch+json.
+// Path: /record/{id}
+// Method: PATCH
+// Headers: Content-Type: application/merge-patch+json
+//		If-Match: "3" - (optional) only patch this version of the record
+// Example: /record/12345
+//		Body: {
+//					"title": "A New Name",
+//					"details": {
+//						"description": null
+//					}
+//				}
+//		Body: [
+//					{ "op": "replace", "path": "/details/colour", "value": "Red" }
+//				]
+// The patched record is returned with its ETag. Without If-Match only the
+// patched fields are written, whatever else has changed since, so a detail
+// can be changed without reading the record first. If the details are
+// invalid, the location or its lat or lng is removed, or a JSON Patch
+// replaces or removes a field the record doesn't have, 422 is returned. If the record has changed since If-Match 409 is
+// returned with the current record.
+func (s *WebService) Patch() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "patch",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := getRecordID(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Issue with ID")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		if r.Body == nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+			}).Warn("No body provided")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "No body provided"})
+			return
+		}
+
+		patch, err := readPatch(r)
+		if err == nil && len(patch.Changes) == 0 {
+			err = &types.PatchError{Message: "Patch has no changes"}
+		}
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Invalid patch")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		if r.Header.Get("If-Match") != "" {
+			version, err := ifMatchVersion(r)
+			if err != nil {
+				logger.WithFields(log.Fields{
+					"status": 400,
+					"error":  err.Error(),
+				}).Warn("Invalid If-Match header")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+			patch.Version = &version
+		}
+
+		if s.DB == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to connect to database"})
+			logger.WithFields(log.Fields{
+				"status": 500,
+			}).Error("No database set")
+			return
+		}
+
+		record, err := s.db(r).Patch(id, patch)
+		if err != nil {
+			switch e := err.(type) {
+			case *types.PatchError:
+				logger.WithFields(log.Fields{
+					"status": 400,
+					"error":  err.Error(),
+				}).Warn("Patch can't be applied")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			case *types.ValidationError:
+				logger.WithFields(log.Fields{
+					"status": 422,
+					"error":  err.Error(),
+				}).Warn("Record details are invalid")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(e)
+			case *types.RecordNotFoundError:
+				logger.WithFields(log.Fields{
+					"status": 404,
+					"id":     id,
+				}).Warn("Couldn't find record to patch")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			case *types.ConflictError:
+				logger.WithFields(log.Fields{
+					"status":  409,
+					"id":      id,
+					"current": e.Current.Version,
+				}).Warn("Record has been changed since it was read")
+				w.Header().Set("ETag", etag(e.Current.Version))
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(e.Current)
+			default:
+				logger.WithFields(log.Fields{
+					"status": 500,
+					"error":  err.Error(),
+				}).Error("Failed to patch record")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to patch record"})
+			}
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status":  200,
+			"id":      id,
+			"changed": patch.Paths(),
+		}).Info("Patched record")
+		w.Header().Set("ETag", etag(record.Version))
+		json.NewEncoder(w).Encode(record)
+	}
+}
+
+// readPatch decodes the body of the request into a patch based on its
+// content type
+func readPatch(r *http.Request) (types.Patch, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == JSONPatchType {
+		var ops []types.PatchOperation
+		if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+			return types.Patch{}, &types.PatchError{Message: "Unable to parse JSON Patch, expected an array of operations"}
+		}
+		return types.JSONPatch(ops)
+	}
+
+	var doc map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		return types.Patch{}, &types.PatchError{Message: "Unable to parse JSON Merge Patch, expected an object"}
+	}
+	return types.MergePatch(doc)
+}
@@ -0,0 +1,144 @@
+package knowledge
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/gorilla/mux"
+)
+
+func patchRouter(service *WebService) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/record/{id}", service.Patch())
+	return r
+}
+
+func TestPatchPassesMergePatchToDatabase(t *testing.T) {
+	var passed types.Patch
+	db := mockDB{
+		PatchFunc: func(id string, p types.Patch) (types.Record, error) {
+			passed = p
+			return types.Record{ID: id, Title: "Leeds Bradford", Version: 4}, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PATCH", "/record/12345", bytes.NewBufferString(`{"title":"Leeds Bradford"}`))
+	ok(t, err)
+	req.Header.Set("Content-Type", MergePatchType)
+
+	rr := httptest.NewRecorder()
+	patchRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected OK (200) status to be returned got %d", rr.Code)
+	}
+
+	if len(passed.Changes) != 1 || passed.Changes[0].Path != "title" || passed.Changes[0].Value != "Leeds Bradford" {
+		t.Errorf("Expected title change to be passed to the database but got %+v", passed.Changes)
+	}
+
+	if passed.Version != nil {
+		t.Errorf("Expected no version without If-Match but got %d", *passed.Version)
+	}
+
+	if rr.Header().Get("ETag") != `"4"` {
+		t.Errorf("Expected ETag of the patched record \"4\" but got %s", rr.Header().Get("ETag"))
+	}
+}
+
+func TestPatchAcceptsJSONPatchWithIfMatch(t *testing.T) {
+	var passed types.Patch
+	db := mockDB{
+		PatchFunc: func(id string, p types.Patch) (types.Record, error) {
+			passed = p
+			return types.Record{ID: id, Version: 3}, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	body := `[{"op":"remove","path":"/details/colour"}]`
+	req, err := http.NewRequest("PATCH", "/record/12345", bytes.NewBufferString(body))
+	ok(t, err)
+	req.Header.Set("Content-Type", JSONPatchType)
+	req.Header.Set("If-Match", `"2"`)
+
+	rr := httptest.NewRecorder()
+	patchRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected OK (200) status to be returned got %d", rr.Code)
+	}
+
+	if len(passed.Changes) != 1 || passed.Changes[0].Path != "details.colour" || !passed.Changes[0].Remove {
+		t.Errorf("Expected removal of details.colour to be passed to the database but got %+v", passed.Changes)
+	}
+
+	if passed.Version == nil || *passed.Version != 2 {
+		t.Error("Expected version 2 from If-Match to be passed to the database")
+	}
+}
+
+func TestPatchRejectsInvalidPatches(t *testing.T) {
+	for _, body := range []string{`{}`, `{"deletedAt":null}`, `[]`, `{"title":`} {
+		service := &WebService{DB: &mockDB{}}
+
+		req, err := http.NewRequest("PATCH", "/record/12345", bytes.NewBufferString(body))
+		ok(t, err)
+		req.Header.Set("Content-Type", MergePatchType)
+
+		rr := httptest.NewRecorder()
+		patchRouter(service).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected Bad Request (400) status to be returned for %s got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestPatchReturnsConflictWithCurrentRecord(t *testing.T) {
+	db := mockDB{
+		PatchFunc: func(id string, p types.Patch) (types.Record, error) {
+			current := types.Record{ID: id, Version: 5}
+			return current, &types.ConflictError{ID: id, Message: "Record has been changed since it was read.", Current: current}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PATCH", "/record/12345", bytes.NewBufferString(`{"title":"Leeds"}`))
+	ok(t, err)
+	req.Header.Set("If-Match", `"4"`)
+
+	rr := httptest.NewRecorder()
+	patchRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected Conflict (409) status to be returned got %d", rr.Code)
+	}
+
+	if rr.Header().Get("ETag") != `"5"` {
+		t.Errorf("Expected ETag of the current record \"5\" but got %s", rr.Header().Get("ETag"))
+	}
+}
+
+func TestPatchReturnsUnprocessableEntityForInvalidDetails(t *testing.T) {
+	db := mockDB{
+		PatchFunc: func(id string, p types.Patch) (types.Record, error) {
+			return types.Record{}, &types.ValidationError{Message: "Record details do not match the fields"}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PATCH", "/record/12345", bytes.NewBufferString(`{"details":{"runways":"many"}}`))
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	patchRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected Unprocessable Entity (422) status to be returned got %d", rr.Code)
+	}
+}
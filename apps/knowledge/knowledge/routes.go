@@ -2,71 +2,320 @@ package knowledge
 
 import (
 	"net/http"
+	"strings"
 
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
 	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
 	"github.com/gorilla/mux"
 )
 
 // Route type description
 type Route struct {
-	Name        string
-	Method      string
-	Pattern     string
+	Name    string
+	Method  string
+	Pattern string
+	// Role needed to request the route, viewer, editor or admin
+	Role        string
 	HandlerFunc http.HandlerFunc
 }
 
+// publicRole is given to routes that can be requested without authenticating
+const publicRole = ""
+
 // Routes contains all routes
 type Routes []Route
 
 var routes []Route
 
+// routeRoles holds the role needed for each route by its name
+var routeRoles map[string]string
+
+// unscopedRoutes aren't part of a workspace, every other route works on the
+// records and fields of the workspace in its path, /w/{workspace}/..., or of
+// the principal
+var unscopedRoutes = map[string]bool{
+	"HealthCheck":     true,
+	"ListWorkspaces":  true,
+	"CreateWorkspace": true,
+	"Geocode":         true,
+}
+
 func initRoutes(service types.Service) {
 	routes = Routes{
 		Route{
 			"HealthCheck",
 			"GET",
 			"/",
+			publicRole,
 			service.HealthCheck(),
 		},
 		Route{
 			"CreateRecord",
 			"POST",
 			"/record",
+			auth.RoleEditor,
 			service.NewRecord(),
 		}, Route{
 			"SearchRecord",
 			"GET",
 			"/record",
+			auth.RoleViewer,
 			service.Search(),
+		}, Route{
+			"SearchRecordByGeometry",
+			"POST",
+			"/record/search",
+			auth.RoleViewer,
+			service.SearchWithin(),
+		}, Route{
+			"ImportRecords",
+			"POST",
+			"/record/import",
+			auth.RoleEditor,
+			service.Import(),
+		}, Route{
+			"BulkRecords",
+			"POST",
+			"/record/bulk",
+			auth.RoleEditor,
+			service.Bulk(),
+		}, Route{
+			"ExportRecords",
+			"GET",
+			"/record/export",
+			auth.RoleViewer,
+			service.Export(),
+		}, Route{
+			"RecordClusters",
+			"GET",
+			"/record/clusters",
+			auth.RoleViewer,
+			service.Clusters(),
+		}, Route{
+			"DeletedRecords",
+			"GET",
+			"/record/deleted",
+			auth.RoleViewer,
+			service.DeletedRecords(),
+		}, Route{
+			"GetRecord",
+			"GET",
+			"/record/{id}",
+			auth.RoleViewer,
+			service.Get(),
 		}, Route{
 			"UpdateRecord",
 			"PUT",
 			"/record/{id}",
+			auth.RoleEditor,
 			service.Update(),
+		}, Route{
+			"PatchRecord",
+			"PATCH",
+			"/record/{id}",
+			auth.RoleEditor,
+			service.Patch(),
 		}, Route{
 			"DeleteRecord",
 			"DELETE",
 			"/record/{id}",
+			auth.RoleAdmin,
 			service.Delete(),
+		}, Route{
+			"RecordHistory",
+			"GET",
+			"/record/{id}/history",
+			auth.RoleViewer,
+			service.History(),
+		}, Route{
+			"RecordRevision",
+			"GET",
+			"/record/{id}/history/{rev}",
+			auth.RoleViewer,
+			service.Revision(),
+		}, Route{
+			"RestoreRecord",
+			"POST",
+			"/record/{id}/restore",
+			auth.RoleEditor,
+			service.Restore(),
+		}, Route{
+			"RecordAttachments",
+			"GET",
+			"/record/{id}/attachment",
+			auth.RoleViewer,
+			service.Attachments(),
+		}, Route{
+			"AddAttachment",
+			"POST",
+			"/record/{id}/attachment",
+			auth.RoleEditor,
+			service.AddAttachment(),
+		}, Route{
+			"GetAttachment",
+			"GET",
+			"/record/{id}/attachment/{attachment}",
+			auth.RoleViewer,
+			service.GetAttachment(),
+		}, Route{
+			"DeleteAttachment",
+			"DELETE",
+			"/record/{id}/attachment/{attachment}",
+			auth.RoleEditor,
+			service.DeleteAttachment(),
+		}, Route{
+			"RecordNotes",
+			"GET",
+			"/record/{id}/note",
+			auth.RoleViewer,
+			service.Notes(),
+		}, Route{
+			"AddNote",
+			"POST",
+			"/record/{id}/note",
+			auth.RoleEditor,
+			service.AddNote(),
+		}, Route{
+			"UpdateNote",
+			"PUT",
+			"/record/{id}/note/{note}",
+			auth.RoleEditor,
+			service.UpdateNote(),
+		}, Route{
+			"DeleteNote",
+			"DELETE",
+			"/record/{id}/note/{note}",
+			auth.RoleEditor,
+			service.DeleteNote(),
 		}, Route{
 			"GetFields",
 			"GET",
 			"/field",
+			auth.RoleViewer,
 			service.GetFields(),
+		}, Route{
+			"DeletedFields",
+			"GET",
+			"/field/deleted",
+			auth.RoleViewer,
+			service.DeletedFields(),
 		}, Route{
 			"UpdateFields",
 			"PUT",
 			"/field",
+			auth.RoleAdmin,
 			service.UpdateFields(),
 		}, Route{
 			"DeleteField",
 			"DELETE",
 			"/field/{id}",
+			auth.RoleAdmin,
 			service.DeleteField(),
+		}, Route{
+			"RestoreField",
+			"POST",
+			"/field/{id}/restore",
+			auth.RoleAdmin,
+			service.RestoreField(),
+		}, Route{
+			"Events",
+			"GET",
+			"/events",
+			auth.RoleViewer,
+			service.Events(),
+		}, Route{
+			"ListWebhooks",
+			"GET",
+			"/webhook",
+			auth.RoleAdmin,
+			service.Webhooks(),
+		}, Route{
+			"CreateWebhook",
+			"POST",
+			"/webhook",
+			auth.RoleAdmin,
+			service.CreateWebhook(),
+		}, Route{
+			"DeadDeliveries",
+			"GET",
+			"/webhook/dead-letter",
+			auth.RoleAdmin,
+			service.DeadDeliveries(),
+		}, Route{
+			"GetWebhook",
+			"GET",
+			"/webhook/{id}",
+			auth.RoleAdmin,
+			service.GetWebhook(),
+		}, Route{
+			"UpdateWebhook",
+			"PUT",
+			"/webhook/{id}",
+			auth.RoleAdmin,
+			service.UpdateWebhook(),
+		}, Route{
+			"DeleteWebhook",
+			"DELETE",
+			"/webhook/{id}",
+			auth.RoleAdmin,
+			service.DeleteWebhook(),
+		}, Route{
+			"WebhookDeliveries",
+			"GET",
+			"/webhook/{id}/delivery",
+			auth.RoleAdmin,
+			service.WebhookDeliveries(),
+		}, Route{
+			"RetryDelivery",
+			"POST",
+			"/webhook/{id}/delivery/{delivery}/retry",
+			auth.RoleAdmin,
+			service.RetryDelivery(),
+		}, Route{
+			"Geocode",
+			"GET",
+			"/geocode",
+			auth.RoleViewer,
+			service.Geocode(),
+		}, Route{
+			"ListWorkspaces",
+			"GET",
+			"/workspace",
+			auth.RoleViewer,
+			service.Workspaces(),
+		}, Route{
+			"CreateWorkspace",
+			"POST",
+			"/workspace",
+			auth.RoleAdmin,
+			service.CreateWorkspace(),
 		},
 	}
 }
 
+// Public reports whether the route a request was matched to can be requested
+// without authenticating
+func Public(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	role, ok := routeRoles[route.GetName()]
+	return ok && role == publicRole
+}
+
+// QueryToken reports whether the request is for the event stream, which can
+// be sent the bearer token in its query params as browsers' EventSource can't
+// set the Authorization header
+func QueryToken(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil || r.Method != http.MethodGet {
+		return false
+	}
+	pattern, err := route.GetPathTemplate()
+	return err == nil && strings.HasSuffix(pattern, "/events")
+}
+
 // NewRouter takes a Service and creates an mux.Router
 // Is uses the methods of the Service to associate the handlers
 // to their implementations
@@ -75,9 +324,22 @@ func NewRouter(s types.Service) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
 
 	sub := router.PathPrefix("/v1").Subrouter()
+	scoped := sub.PathPrefix("/w/{workspace}").Subrouter()
 
+	routeRoles = make(map[string]string, len(routes))
 	for _, route := range routes {
-		sub.HandleFunc(route.Pattern, route.HandlerFunc).Name(route.Name).Methods(route.Method)
+		routeRoles[route.Name] = route.Role
+		handler := route.HandlerFunc
+		if !unscopedRoutes[route.Name] {
+			handler = s.InWorkspace(handler)
+		}
+		if route.Role != publicRole {
+			handler = authorize(route.Name, route.Role, handler)
+		}
+		sub.HandleFunc(route.Pattern, handler).Name(route.Name).Methods(route.Method)
+		if !unscopedRoutes[route.Name] {
+			scoped.HandleFunc(route.Pattern, handler).Methods(route.Method)
+		}
 	}
 	return router
 }
@@ -3,9 +3,17 @@ package knowledge
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/blob"
 	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/events"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/geo"
 	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
 	"github.com/dyninc/qstring"
 	"github.com/gorilla/mux"
@@ -15,8 +23,25 @@ import (
 // WebService provides any dependencies needed by the service
 type WebService struct {
 	DB database.Database
+
+	// Blobs stores the files attached to records
+	Blobs blob.Store
+
+	// Broker passes changes made through DB on to clients streaming events
+	Broker *events.Broker
+
+	// Geocoder finds where addresses are, it's nil when geocoding is disabled
+	Geocoder geo.ForwardGeocoder
 }
 
+const (
+	// DefaultPageSize is the number of records returned by a search when no
+	// page size is requested
+	DefaultPageSize = 100
+	// MaxPageSize is the largest page of records a search will return
+	MaxPageSize = 500
+)
+
 // ErrorResponse is returned for non 200 status'
 type ErrorResponse struct {
 	Message string
@@ -50,6 +75,10 @@ func (s *WebService) HealthCheck() http.HandlerFunc {
 //					"lat": "53.862309546682600"
 //					}
 //				}
+// The details are checked against the fields, if any are invalid 422 is
+// returned listing each offending field. A location without a lat and lng
+// can instead have an address, a place or postcode, which is placed where
+// /geocode finds it, 400 being returned unless it matches exactly.
 func (s *WebService) NewRecord() http.HandlerFunc {
 	logger := log.WithFields(log.Fields{
 		"event": "create",
@@ -58,9 +87,6 @@ func (s *WebService) NewRecord() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		var rec types.Record
-		decoder := json.NewDecoder(r.Body)
-
 		if r.Body == nil {
 			logger.WithFields(log.Fields{
 				"status": 400,
@@ -70,6 +96,9 @@ func (s *WebService) NewRecord() http.HandlerFunc {
 			return
 		}
 
+		var rec types.Record
+		decoder := json.NewDecoder(r.Body)
+
 		if err := decoder.Decode(&rec); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to parse JSON"})
@@ -80,6 +109,19 @@ func (s *WebService) NewRecord() http.HandlerFunc {
 			return
 		}
 
+		if rec.Location.Lat == 0 && rec.Location.Lng == 0 && rec.Location.Address != "" {
+			if err := s.resolveAddress(&rec); err != nil {
+				logger.WithFields(log.Fields{
+					"status":  400,
+					"address": rec.Location.Address,
+					"error":   err.Error(),
+				}).Warn("Unable to find address")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+		}
+
 		if s.DB == nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to connect to database"})
@@ -89,8 +131,18 @@ func (s *WebService) NewRecord() http.HandlerFunc {
 			return
 		}
 
-		id, err := s.DB.Create(rec)
+		id, err := s.db(r).Create(rec)
 		if err != nil {
+			if invalid, ok := err.(*types.ValidationError); ok {
+				logger.WithFields(log.Fields{
+					"status": 422,
+					"error":  err.Error(),
+				}).Warn("Record details are invalid")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(invalid)
+				return
+			}
+
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to create new record"})
 			logger.WithFields(log.Fields{
@@ -113,12 +165,32 @@ func (s *WebService) NewRecord() http.HandlerFunc {
 // Path: /record
 // Method: GET
 // Parameters:
-//		query - string to search for
+//		query - text to search for, matched against the title, short name,
+//				country, facilities and details. Results are ordered by relevance.
 //		minLat - minimum latitude of the location
 //		maxLat - maximum latitude of the location
 //		minLng - mininum longitude of the location
 //		maxLng - maximum longitude of the location
-// Example: /record?query=Leeds&minLat=-1.23423423&maxLat=0.12321321&minLng=54.4564523&maxLng=55.2342809
+//		lat, lng, radius - (alternative to the bounds) search within radius
+//				metres of the point, results are ordered nearest first and
+//				include their distance in metres. Cannot be used with query.
+//		facility - (optional, repeatable) only return records with the facility
+//		facilityMatch - (optional) any (default) to match records with any of
+//				the facilities or all to match records with every facility
+//		countryCode - (optional, repeatable) only return records in the
+//				country, given by its ISO 3166-1 alpha-2 code, e.g. GB
+//		details.{key}[{operator}] - (optional, repeatable) filter on a detail
+//				field, given by its key or id. Operators are eq (default),
+//				contains, exists (true or false), gt and lt.
+//		page - (optional) page of results to return, starting at 1
+//		pageSize - (optional) number of results per page, defaults to 100 and
+//				is capped at 500
+// Headers: X-Total-Count holds the total number of matching records,
+//		X-Page and X-Page-Size the page that was returned
+// Example: /record?query=Leeds&minLat=-1.23423423&maxLat=0.12321321&minLng=54.4564523&maxLng=55.2342809&page=2
+//		/record?lat=53.7997&lng=-1.5492&radius=25000
+//		/record?lat=53.7997&lng=-1.5492&radius=25000&facility=TAXI&facility=TRN&facilityMatch=all
+//		/record?minLat=53.63&maxLat=53.84&minLng=-1.97&maxLng=-1.11&details.description[contains]=airport
 func (s *WebService) Search() http.HandlerFunc {
 	logger := log.WithFields(log.Fields{
 		"event": "search",
@@ -156,7 +228,28 @@ func (s *WebService) Search() http.HandlerFunc {
 			return
 		}
 
-		if !boundsPresent(*query) {
+		query.Details, err = parseDetailFilters(r.URL.Query())
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Error("Invalid detail filter")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		if query.Radius != 0 {
+			if err := validRadius(*query, r.URL.Query()); err != nil {
+				logger.WithFields(log.Fields{
+					"status": 400,
+					"error":  err.Error(),
+				}).Error("Invalid radius search")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+		} else if !boundsPresent(*query) {
 			logger.WithFields(log.Fields{
 				"status": 400,
 			}).Error("No location bounds provided")
@@ -165,56 +258,255 @@ func (s *WebService) Search() http.HandlerFunc {
 			return
 		}
 
-		if len(query.Query) > 100 {
+		s.search(w, logger, s.db(r), query)
+	}
+
+}
+
+// SearchWithin finds the records inside a GeoJSON Polygon or MultiPolygon
+// passed as the body. The same query parameters as Search can be used to
+// filter and page the results, apart from the bounds and radius.
+// Path: /record/search
+// Method: POST
+// Example: /record/search?query=Airport&pageSize=50
+//		Body: {
+//					"type": "Polygon",
+//					"coordinates": [[
+//						[-1.97, 53.63], [-1.11, 53.63], [-1.11, 53.84],
+//						[-1.97, 53.84], [-1.97, 53.63]
+//					]]
+//				}
+func (s *WebService) SearchWithin() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "searchWithin",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Body == nil {
 			logger.WithFields(log.Fields{
 				"status": 400,
-			}).Error("Query string too long")
+			}).Warn("No body provided")
 			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Query string must be less than 100 characters"})
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "No body provided"})
 			return
 		}
 
-		records, err := s.DB.Search(*query)
+		var geometry types.Geometry
+		if err := json.NewDecoder(r.Body).Decode(&geometry); err != nil {
+			logger.WithFields(log.Fields{
+				"error":  err.Error(),
+				"status": 400,
+			}).Error("Unable to parse JSON")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to parse JSON"})
+			return
+		}
+
+		polygons, err := geometry.MultiPolygon()
 		if err != nil {
 			logger.WithFields(log.Fields{
-				"error":  err,
-				"status": 500,
-				"query":  query,
-			}).Error("Unable to search database")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to search database"})
+				"error":  err.Error(),
+				"status": 400,
+			}).Error("Invalid geometry")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
 			return
 		}
 
-		if len(records) == 0 {
+		query := &types.SearchQuery{}
+		if err := qstring.Unmarshal(r.URL.Query(), query); err != nil {
 			logger.WithFields(log.Fields{
-				"status":   404,
-				"response": records,
-			}).Info("No results found")
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("{}"))
+				"status": 400,
+				"error":  err.Error(),
+			}).Error("Unable to unmarshal query params")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to parse search parameters"})
 			return
 		}
 
+		query.Details, err = parseDetailFilters(r.URL.Query())
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Error("Invalid detail filter")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		query.Polygons = polygons
+		s.search(w, logger, s.db(r), query)
+	}
+}
+
+// search runs a query whose area has already been checked against the
+// database and writes the page of matching records
+func (s *WebService) search(w http.ResponseWriter, logger *log.Entry, db database.Database, query *types.SearchQuery) {
+	if status, err := s.checkQuery(db, query); err != nil {
+		logger.WithFields(log.Fields{
+			"status": status,
+			"error":  err.Error(),
+		}).Error("Invalid search")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	if query.Page < 0 || query.PageSize < 0 {
 		logger.WithFields(log.Fields{
-			"status":         200,
-			"numberReturned": len(records),
-		}).Info("Results returned")
-		json.NewEncoder(w).Encode(records)
+			"status":   400,
+			"page":     query.Page,
+			"pageSize": query.PageSize,
+		}).Error("Invalid page requested")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "Page and page size must be positive"})
+		return
 	}
+	setPage(query)
+
+	records, total, err := db.Search(*query)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err,
+			"status": 500,
+			"query":  query,
+		}).Error("Unable to search database")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to search database"})
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Set("X-Page", strconv.Itoa(query.Page))
+	w.Header().Set("X-Page-Size", strconv.Itoa(query.PageSize))
+
+	if len(records) == 0 {
+		logger.WithFields(log.Fields{
+			"status":   404,
+			"response": records,
+		}).Info("No results found")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("{}"))
+		return
+	}
+
+	logger.WithFields(log.Fields{
+		"status":         200,
+		"numberReturned": len(records),
+		"total":          total,
+	}).Info("Results returned")
+	json.NewEncoder(w).Encode(records)
+}
+
+// checkQuery checks the filters of a search, resolving any detail filters
+// against the fields. The status to respond with is returned along with the
+// error when they're invalid.
+func (s *WebService) checkQuery(db database.Database, query *types.SearchQuery) (int, error) {
+	if len(query.Query) > 100 {
+		return http.StatusBadRequest, errors.New("Query string must be less than 100 characters")
+	}
+
+	if query.FacilityMatch != "" && query.FacilityMatch != "any" && query.FacilityMatch != "all" {
+		return http.StatusBadRequest, errors.New("Facility match must be any or all")
+	}
+
+	if len(query.Details) > 0 {
+		fields, err := db.Fields()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Failed to get fields")
+			return http.StatusInternalServerError, errors.New("Failed to get fields")
+		}
+
+		query.Details, err = resolveDetailFilters(fields, query.Details)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+// Get retrieves a single record by its ID
+// Path: /record/{id}
+// Method: GET
+// Example: /record/12345
+// The ETag header holds the version of the record, to be sent back in
+// If-Match when updating it.
+func (s *WebService) Get() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "get",
+	})
 
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := getRecordID(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Issue with ID")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		record, err := s.db(r).Get(id)
+		if err != nil {
+			if _, ok := err.(*types.RecordNotFoundError); ok {
+				logger.WithFields(log.Fields{
+					"status": 404,
+					"id":     id,
+				}).Info("Record not found")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+				"id":     id,
+			}).Error("Failed to get record")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to get record"})
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"id":     id,
+		}).Info("Returning record")
+		w.Header().Set("ETag", etag(record.Version))
+		json.NewEncoder(w).Encode(record)
+	}
 }
 
-// Update takes a record and writes any changes to the database
+// Update takes a record and writes it to the database in place of the
+// current one, use Patch to change part of a record
 // Path: /record
 // Method: PUT
 // Example: /record/12345
 //		Body: {
+//					"title": "A Location",
 //					"location": {
 //					"lng": "-5.619060757481970",
 //					"lat": "52.862309546682600"
-//					}
+//					},
+//					"facilities": [],
+//					"details": {}
 //				}
+// Headers: If-Match: "3"
+// The details are checked against the fields, if any are invalid 422 is
+// returned listing each offending field.
+// If-Match must hold the ETag of the record being changed, if it is missing
+// 428 is returned and if the record has since changed 409 is returned with
+// the current record.
 func (s *WebService) Update() http.HandlerFunc {
 	logger := log.WithFields(log.Fields{
 		"event": "update",
@@ -265,11 +557,66 @@ func (s *WebService) Update() http.HandlerFunc {
 			return
 		}
 
+		if r.Header.Get("If-Match") == "" {
+			logger.WithFields(log.Fields{
+				"status": 428,
+				"id":     id,
+			}).Warn("No If-Match header provided")
+			w.WriteHeader(http.StatusPreconditionRequired)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "If-Match header with the ETag of the record is required"})
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Invalid If-Match header")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
 		rec.ID = id
+		rec.Version = version
 
-		err = s.DB.Update(id, rec)
+		err = s.db(r).Update(id, rec)
 
 		if err != nil {
+			if conflict, ok := err.(*types.ConflictError); ok {
+				logger.WithFields(log.Fields{
+					"status":  409,
+					"id":      id,
+					"version": version,
+					"current": conflict.Current.Version,
+				}).Warn("Record has been changed since it was read")
+				w.Header().Set("ETag", etag(conflict.Current.Version))
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(conflict.Current)
+				return
+			}
+
+			if invalid, ok := err.(*types.ValidationError); ok {
+				logger.WithFields(log.Fields{
+					"status": 422,
+					"error":  err.Error(),
+				}).Warn("Record details are invalid")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(invalid)
+				return
+			}
+
+			if _, ok := err.(*types.RecordNotFoundError); ok {
+				logger.WithFields(log.Fields{
+					"status": 404,
+					"id":     id,
+				}).Warn("Couldn't find record to update")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to update record"})
 			logger.WithFields(log.Fields{
@@ -283,6 +630,7 @@ func (s *WebService) Update() http.HandlerFunc {
 			"status": 200,
 			"id":     id,
 		}).Info("Updated record")
+		w.Header().Set("ETag", etag(version+1))
 		w.WriteHeader(http.StatusOK)
 	}
 }
@@ -310,8 +658,18 @@ func (s *WebService) Delete() http.HandlerFunc {
 			return
 		}
 
-		err = s.DB.Delete(id)
+		err = s.db(r).Delete(id)
 		if err != nil {
+			if _, ok := err.(*types.RecordNotFoundError); ok {
+				logger.WithFields(log.Fields{
+					"status": 404,
+					"id":     id,
+				}).Warn("Couldn't find record to delete")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to delete record"})
 			logger.WithFields(log.Fields{
@@ -330,6 +688,129 @@ func (s *WebService) Delete() http.HandlerFunc {
 
 }
 
+// History lists the revisions of a record, newest first, without the
+// snapshots of the record
+// Path: /record/{id}/history
+// Method: GET
+// Example: /record/12345/history
+func (s *WebService) History() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "history",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := getRecordID(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Issue with ID")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		revisions, err := s.db(r).History(id)
+		if err != nil {
+			if _, ok := err.(*types.RecordNotFoundError); ok {
+				logger.WithFields(log.Fields{
+					"status": 404,
+					"id":     id,
+				}).Info("No history found")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+				"id":     id,
+			}).Error("Failed to get history")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to get history"})
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status":    200,
+			"id":        id,
+			"revisions": len(revisions),
+		}).Info("Returning history")
+		json.NewEncoder(w).Encode(revisions)
+	}
+}
+
+// Revision retrieves a single revision of a record, including the snapshot
+// of the record as it was after the change
+// Path: /record/{id}/history/{rev}
+// Method: GET
+// Example: /record/12345/history/2
+func (s *WebService) Revision() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "revision",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := getRecordID(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Issue with ID")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		rev, err := strconv.Atoi(mux.Vars(r)["rev"])
+		if err != nil || rev < 1 {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"rev":    mux.Vars(r)["rev"],
+			}).Warn("Invalid revision")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Revision must be a number greater than 0"})
+			return
+		}
+
+		revision, err := s.db(r).Revision(id, rev)
+		if err != nil {
+			if _, ok := err.(*types.RevisionNotFoundError); ok {
+				logger.WithFields(log.Fields{
+					"status": 404,
+					"id":     id,
+					"rev":    rev,
+				}).Info("Revision not found")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+				"id":     id,
+				"rev":    rev,
+			}).Error("Failed to get revision")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to get revision"})
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"id":     id,
+			"rev":    rev,
+		}).Info("Returning revision")
+		json.NewEncoder(w).Encode(revision)
+	}
+}
+
 // GetFields retrieves the fields that the user can enter from the database
 // Path: /field
 // Method: GET
@@ -343,7 +824,7 @@ func (s *WebService) GetFields() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		fields, err := s.DB.Fields()
+		fields, err := s.db(r).Fields()
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to get fields"})
@@ -380,9 +861,14 @@ func (s *WebService) GetFields() http.HandlerFunc {
 //				{
 //					"id": "123456",
 //					"value": "Field Name",
-//					"order": 0
+//					"order": 0,
+//					"type": "enum",
+//					"required": true,
+//					"options": ["Open", "Closed"]
 //				}
 //			]
+// Fields can be of type text (the default), number, date, url, enum or
+// boolean, enum fields must list their options.
 func (s *WebService) UpdateFields() http.HandlerFunc {
 	logger := log.WithFields(log.Fields{
 		"event": "UpdateFields",
@@ -412,7 +898,20 @@ func (s *WebService) UpdateFields() http.HandlerFunc {
 			return
 		}
 
-		err := s.DB.UpdateFields(fields)
+		for _, field := range fields {
+			if err := field.Check(); err != nil {
+				logger.WithFields(log.Fields{
+					"error":  err.Error(),
+					"status": 400,
+					"id":     field.ID,
+				}).Warn("Invalid field")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+		}
+
+		err := s.db(r).UpdateFields(fields)
 		if err != nil {
 			logger.WithFields(log.Fields{
 				"error":  err.Error(),
@@ -453,7 +952,7 @@ func (s *WebService) DeleteField() http.HandlerFunc {
 			return
 		}
 
-		err = s.DB.DeleteField(id)
+		err = s.db(r).DeleteField(id)
 		if err != nil {
 
 			_, ok := err.(*types.FieldNotFoundError)
@@ -517,6 +1016,73 @@ func getRecordID(r *http.Request) (string, error) {
 	return strID, nil
 }
 
+// actor returns who is making the request so changes can be attributed to
+// them, taken from the authenticated principal or, when authentication is
+// disabled, the X-Actor header
+func actor(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p.Name
+	}
+	if actor := r.Header.Get("X-Actor"); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
+
+// etag formats the version of a record as an ETag
+func etag(version int) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// ifMatchVersion reads the version of the record the request was made
+// against from the If-Match header
+func ifMatchVersion(r *http.Request) (int, error) {
+	tag := strings.TrimPrefix(r.Header.Get("If-Match"), "W/")
+	version, err := strconv.Atoi(strings.Trim(tag, "\""))
+	if err != nil || version < 0 {
+		return 0, errors.New("If-Match must be the ETag of the record")
+	}
+	return version, nil
+}
+
+// setPage fills in the default page and page size, limiting the page size to
+// MaxPageSize
+func setPage(query *types.SearchQuery) {
+	if query.Page == 0 {
+		query.Page = 1
+	}
+
+	if query.PageSize == 0 {
+		query.PageSize = DefaultPageSize
+	}
+
+	if query.PageSize > MaxPageSize {
+		query.PageSize = MaxPageSize
+	}
+}
+
+// validRadius checks the centre and radius of a radius search, the centre
+// has to be given in the params as 0 is a valid lat and lng
+func validRadius(query types.SearchQuery, params url.Values) error {
+	if query.Radius < 0 {
+		return errors.New("Radius must be a positive number of metres")
+	}
+
+	if params.Get("lat") == "" || params.Get("lng") == "" {
+		return errors.New("Radius search requires a lat and lng to search around")
+	}
+
+	if query.Lat < -90 || query.Lat > 90 || query.Lng < -180 || query.Lng > 180 {
+		return errors.New("Radius search requires a lat between -90 and 90 and a lng between -180 and 180")
+	}
+
+	if query.Query != "" {
+		return errors.New("Query cannot be combined with a radius search")
+	}
+
+	return nil
+}
+
 func boundsPresent(query types.SearchQuery) bool {
 	if query.MinLat >= query.MaxLat || query.MinLng >= query.MaxLng {
 		return false
@@ -9,10 +9,14 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
 	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
@@ -35,32 +39,158 @@ func ok(tb testing.TB, err error) {
 }
 
 type mockDB struct {
-	SearchQuery      types.SearchQuery
-	CreateFunc       func(r types.Record) (string, error)
-	SearchFunc       func(query types.SearchQuery) ([]types.Record, error)
-	UpdateFunc       func(id string, r types.Record) error
-	DeleteFunc       func(id string) error
-	GetFieldsFunc    func() ([]types.Field, error)
-	UpdateFieldsFunc func(f []types.Field) error
-	DeleteFieldFunc  func(id string) error
+	SearchQuery          types.SearchQuery
+	Actor                string
+	Workspace            string
+	CreateFunc           func(r types.Record) (string, error)
+	SearchFunc           func(query types.SearchQuery) ([]types.Record, int, error)
+	ExportFunc           func(query types.SearchQuery, each func(types.Record) error) error
+	ClustersFunc         func(query types.SearchQuery, zoom int) ([]types.Cluster, error)
+	GetFunc              func(id string) (types.Record, error)
+	UpdateFunc           func(id string, r types.Record) error
+	PatchFunc            func(id string, p types.Patch) (types.Record, error)
+	DeleteFunc           func(id string) error
+	BulkFunc             func(op types.BulkOperation) ([]types.BulkResult, error)
+	ImportFunc           func(records []types.Record, dryRun bool) ([]types.ImportRow, error)
+	HistoryFunc          func(id string) ([]types.Revision, error)
+	RevisionFunc         func(id string, rev int) (types.Revision, error)
+	DeletedFunc          func(page, pageSize int) ([]types.Record, int, error)
+	RestoreFunc          func(id string) error
+	AddAttachmentFunc    func(id string, a types.Attachment) (types.Attachment, error)
+	DeleteAttachmentFunc func(id, attachmentID string) (types.Attachment, error)
+	NotesFunc            func(recordID string) ([]types.Note, error)
+	GetNoteFunc          func(recordID, noteID string) (types.Note, error)
+	AddNoteFunc          func(recordID string, n types.Note) (types.Note, error)
+	UpdateNoteFunc       func(recordID string, n types.Note) (types.Note, error)
+	DeleteNoteFunc       func(recordID, noteID string) error
+	GetFieldsFunc        func() ([]types.Field, error)
+	UpdateFieldsFunc     func(f []types.Field) error
+	DeleteFieldFunc      func(id string) error
+	DeletedFieldsFunc    func() ([]types.Field, error)
+	RestoreFieldFunc     func(id string) error
+
+	WorkspacesFunc      func() ([]types.Workspace, error)
+	GetWorkspaceFunc    func(name string) (types.Workspace, error)
+	CreateWorkspaceFunc func(w types.Workspace) (types.Workspace, error)
+
+	WebhooksFunc       func() ([]types.Webhook, error)
+	GetWebhookFunc     func(id string) (types.Webhook, error)
+	CreateWebhookFunc  func(h types.Webhook) (types.Webhook, error)
+	UpdateWebhookFunc  func(h types.Webhook) (types.Webhook, error)
+	DeleteWebhookFunc  func(id string) error
+	DeliveriesFunc     func(webhookID, status string, page, pageSize int) ([]types.Delivery, int, error)
+	AddDeliveryFunc    func(d types.Delivery) (types.Delivery, error)
+	ClaimDeliveryFunc  func(lease time.Duration) (types.Delivery, error)
+	UpdateDeliveryFunc func(d types.Delivery) error
+	RetryDeliveryFunc  func(webhookID, id string) (types.Delivery, error)
 }
 
 func (db *mockDB) Create(r types.Record) (string, error) {
 	return db.CreateFunc(r)
 }
 
-func (db *mockDB) Search(query types.SearchQuery) ([]types.Record, error) {
+func (db *mockDB) Search(query types.SearchQuery) ([]types.Record, int, error) {
 	return db.SearchFunc(query)
 }
 
+func (db *mockDB) Export(query types.SearchQuery, each func(types.Record) error) error {
+	return db.ExportFunc(query, each)
+}
+
+func (db *mockDB) Clusters(query types.SearchQuery, zoom int) ([]types.Cluster, error) {
+	return db.ClustersFunc(query, zoom)
+}
+
+func (db *mockDB) Get(id string) (types.Record, error) {
+	return db.GetFunc(id)
+}
+
 func (db *mockDB) Update(id string, r types.Record) error {
 	return db.UpdateFunc(id, r)
 }
 
+func (db *mockDB) Patch(id string, p types.Patch) (types.Record, error) {
+	return db.PatchFunc(id, p)
+}
+
+func (db *mockDB) Import(records []types.Record, dryRun bool) ([]types.ImportRow, error) {
+	return db.ImportFunc(records, dryRun)
+}
+
+func (db *mockDB) Bulk(op types.BulkOperation) ([]types.BulkResult, error) {
+	return db.BulkFunc(op)
+}
+
 func (db *mockDB) Delete(id string) error {
 	return db.DeleteFunc(id)
 }
 
+func (db *mockDB) History(id string) ([]types.Revision, error) {
+	return db.HistoryFunc(id)
+}
+
+func (db *mockDB) Revision(id string, rev int) (types.Revision, error) {
+	return db.RevisionFunc(id, rev)
+}
+
+func (db *mockDB) Deleted(page, pageSize int) ([]types.Record, int, error) {
+	return db.DeletedFunc(page, pageSize)
+}
+
+func (db *mockDB) Restore(id string) error {
+	return db.RestoreFunc(id)
+}
+
+func (db *mockDB) As(actor string) database.Database {
+	db.Actor = actor
+	return db
+}
+
+func (db *mockDB) In(workspace string) database.Database {
+	db.Workspace = workspace
+	return db
+}
+
+func (db *mockDB) Workspaces() ([]types.Workspace, error) {
+	return db.WorkspacesFunc()
+}
+
+func (db *mockDB) GetWorkspace(name string) (types.Workspace, error) {
+	return db.GetWorkspaceFunc(name)
+}
+
+func (db *mockDB) CreateWorkspace(w types.Workspace) (types.Workspace, error) {
+	return db.CreateWorkspaceFunc(w)
+}
+
+func (db *mockDB) AddAttachment(id string, a types.Attachment) (types.Attachment, error) {
+	return db.AddAttachmentFunc(id, a)
+}
+
+func (db *mockDB) DeleteAttachment(id, attachmentID string) (types.Attachment, error) {
+	return db.DeleteAttachmentFunc(id, attachmentID)
+}
+
+func (db *mockDB) Notes(recordID string) ([]types.Note, error) {
+	return db.NotesFunc(recordID)
+}
+
+func (db *mockDB) GetNote(recordID, noteID string) (types.Note, error) {
+	return db.GetNoteFunc(recordID, noteID)
+}
+
+func (db *mockDB) AddNote(recordID string, n types.Note) (types.Note, error) {
+	return db.AddNoteFunc(recordID, n)
+}
+
+func (db *mockDB) UpdateNote(recordID string, n types.Note) (types.Note, error) {
+	return db.UpdateNoteFunc(recordID, n)
+}
+
+func (db *mockDB) DeleteNote(recordID, noteID string) error {
+	return db.DeleteNoteFunc(recordID, noteID)
+}
+
 func (db *mockDB) Fields() ([]types.Field, error) {
 	return db.GetFieldsFunc()
 }
@@ -73,6 +203,54 @@ func (db *mockDB) DeleteField(id string) error {
 	return db.DeleteFieldFunc(id)
 }
 
+func (db *mockDB) DeletedFields() ([]types.Field, error) {
+	return db.DeletedFieldsFunc()
+}
+
+func (db *mockDB) RestoreField(id string) error {
+	return db.RestoreFieldFunc(id)
+}
+
+func (db *mockDB) Webhooks() ([]types.Webhook, error) {
+	return db.WebhooksFunc()
+}
+
+func (db *mockDB) GetWebhook(id string) (types.Webhook, error) {
+	return db.GetWebhookFunc(id)
+}
+
+func (db *mockDB) CreateWebhook(h types.Webhook) (types.Webhook, error) {
+	return db.CreateWebhookFunc(h)
+}
+
+func (db *mockDB) UpdateWebhook(h types.Webhook) (types.Webhook, error) {
+	return db.UpdateWebhookFunc(h)
+}
+
+func (db *mockDB) DeleteWebhook(id string) error {
+	return db.DeleteWebhookFunc(id)
+}
+
+func (db *mockDB) Deliveries(webhookID, status string, page, pageSize int) ([]types.Delivery, int, error) {
+	return db.DeliveriesFunc(webhookID, status, page, pageSize)
+}
+
+func (db *mockDB) AddDelivery(d types.Delivery) (types.Delivery, error) {
+	return db.AddDeliveryFunc(d)
+}
+
+func (db *mockDB) ClaimDelivery(lease time.Duration) (types.Delivery, error) {
+	return db.ClaimDeliveryFunc(lease)
+}
+
+func (db *mockDB) UpdateDelivery(d types.Delivery) error {
+	return db.UpdateDeliveryFunc(d)
+}
+
+func (db *mockDB) RetryDelivery(webhookID, id string) (types.Delivery, error) {
+	return db.RetryDeliveryFunc(webhookID, id)
+}
+
 var called bool
 
 var jsonReq = []byte(`{
@@ -196,6 +374,34 @@ func TestReturnsCreatedStatusOnSuccess(t *testing.T) {
 	}
 }
 
+func TestNewRecordReturnsUnprocessableEntityForInvalidDetails(t *testing.T) {
+	db := mockDB{
+		CreateFunc: func(r types.Record) (string, error) {
+			return "", &types.ValidationError{
+				Message: "Record details do not match the fields",
+				Fields:  []types.FieldError{{Field: "question1", Message: "must be a number"}},
+			}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("POST", "/record", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.NewRecord())
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected Unprocessable Entity (422) status to be returned got %d", rr.Code)
+	}
+
+	expected := `{"Message":"Record details do not match the fields","Fields":[{"Field":"question1","Message":"must be a number"}]}`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("Expected response to be: \n %s \n but got: \n %s", expected, rr.Body.String())
+	}
+}
+
 func TestSearchWithNoQueryParamsErrors(t *testing.T) {
 	db := mockDB{}
 	service := &WebService{DB: &db}
@@ -227,9 +433,9 @@ func TestSearchQueryTooLong(t *testing.T) {
 func TestSearchQueryIsPassedToDB(t *testing.T) {
 	var passedQuery string
 	db := mockDB{
-		SearchFunc: func(s types.SearchQuery) ([]types.Record, error) {
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
 			passedQuery = s.Query
-			return []types.Record{}, nil
+			return []types.Record{}, 0, nil
 		},
 	}
 	service := &WebService{DB: &db}
@@ -249,8 +455,8 @@ func TestSearchQueryIsPassedToDB(t *testing.T) {
 
 func TestSearchReturnsServerErrorWhenDBSearchFails(t *testing.T) {
 	db := mockDB{
-		SearchFunc: func(query types.SearchQuery) ([]types.Record, error) {
-			return []types.Record{}, errors.New("Unable to search")
+		SearchFunc: func(query types.SearchQuery) ([]types.Record, int, error) {
+			return []types.Record{}, 0, errors.New("Unable to search")
 		},
 	}
 	service := &WebService{DB: &db}
@@ -266,14 +472,14 @@ func TestSearchReturnsServerErrorWhenDBSearchFails(t *testing.T) {
 }
 
 func TestSuccessfulSearchReturnsResults(t *testing.T) {
-	expectedResults := `[{"id":"","title":"Holy Trinity Church","location":{"type":"","Coordinates":null,"lat":53.8623095466826,"lng":-1.61906075748197,"country":""},"shortName":"","facilities":null,"details":null}]`
+	expectedResults := `[{"id":"","title":"Holy Trinity Church","location":{"type":"","Coordinates":null,"lat":53.8623095466826,"lng":-1.61906075748197,"country":""},"shortName":"","facilities":null,"details":null,"version":2}]`
 	db := mockDB{
-		SearchFunc: func(query types.SearchQuery) ([]types.Record, error) {
+		SearchFunc: func(query types.SearchQuery) ([]types.Record, int, error) {
 			var records []types.Record
 			buf := bytes.NewBuffer([]byte(expectedResults))
 			err := json.NewDecoder(buf).Decode(&records)
 			ok(t, err)
-			return records, nil
+			return records, len(records), nil
 		},
 	}
 	service := &WebService{DB: &db}
@@ -296,9 +502,9 @@ func TestSuccessfulSearchReturnsResults(t *testing.T) {
 
 func TestSearchReturnsNotFoundOnNoResults(t *testing.T) {
 	db := mockDB{
-		SearchFunc: func(query types.SearchQuery) ([]types.Record, error) {
+		SearchFunc: func(query types.SearchQuery) ([]types.Record, int, error) {
 			var records []types.Record
-			return records, nil
+			return records, len(records), nil
 		},
 	}
 	service := &WebService{DB: &db}
@@ -321,9 +527,9 @@ func TestSearchReturnsNotFoundOnNoResults(t *testing.T) {
 func TestSearchCanTakeMapBounds(t *testing.T) {
 	var passedQuery types.SearchQuery
 	db := mockDB{
-		SearchFunc: func(s types.SearchQuery) ([]types.Record, error) {
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
 			passedQuery = s
-			return []types.Record{}, nil
+			return []types.Record{}, 0, nil
 		},
 	}
 	service := &WebService{DB: &db}
@@ -358,215 +564,932 @@ func TestSearchCanTakeMapBounds(t *testing.T) {
 	}
 }
 
-func TestIfBoundsAreNotProvidedErrorIsReturned(t *testing.T) {
-	db := mockDB{}
+const leedsBounds = "minLat=53.635682044465476&maxLat=53.840373979032805&minLng=-1.9713592529296877&maxLng=-1.1144256591796877"
+
+func TestSearchUsesDefaultPageSize(t *testing.T) {
+	var passedQuery types.SearchQuery
+	db := mockDB{
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
+			passedQuery = s
+			return []types.Record{}, 0, nil
+		},
+	}
 	service := &WebService{DB: &db}
-	req, err := http.NewRequest("GET", "/record?query=Leeds", nil)
+
+	req, err := http.NewRequest("GET", "/record?"+leedsBounds, nil)
 	ok(t, err)
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(service.Search())
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+
+	if passedQuery.Page != 1 || passedQuery.PageSize != DefaultPageSize {
+		t.Errorf("Expected page 1 of size %d but got page %d of size %d", DefaultPageSize, passedQuery.Page, passedQuery.PageSize)
 	}
 }
 
-func TestMinLatCannotBeGreaterThanMaxLatReturnsError(t *testing.T) {
+func TestSearchPageSizeIsCappedAtMaximum(t *testing.T) {
+	var passedQuery types.SearchQuery
 	db := mockDB{
-		SearchFunc: func(s types.SearchQuery) ([]types.Record, error) {
-			return []types.Record{}, nil
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
+			passedQuery = s
+			return []types.Record{}, 0, nil
 		},
 	}
 	service := &WebService{DB: &db}
-	req, err := http.NewRequest("GET", "/record?minLat=53.840373979032805&maxLat=53.635682044465476&minLng=-1.9713592529296877&maxLng=-1.1144256591796877", nil)
+
+	req, err := http.NewRequest("GET", "/record?page=3&pageSize=100000&"+leedsBounds, nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Search())
+	handler.ServeHTTP(rr, req)
+
+	if passedQuery.Page != 3 {
+		t.Errorf("Expected page 3 but got %d", passedQuery.Page)
+	}
+
+	if passedQuery.PageSize != MaxPageSize {
+		t.Errorf("Expected page size to be capped at %d but got %d", MaxPageSize, passedQuery.PageSize)
+	}
+}
+
+func TestSearchWithNegativePageReturnsError(t *testing.T) {
+	db := mockDB{}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record?page=-1&"+leedsBounds, nil)
 	ok(t, err)
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(service.Search())
 	handler.ServeHTTP(rr, req)
+
 	if rr.Code != http.StatusBadRequest {
 		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
 	}
 }
 
-func TestMinLngCannotBeGreaterThanMaxLngReturnsError(t *testing.T) {
+func TestSearchReturnsTotalCountHeader(t *testing.T) {
 	db := mockDB{
-		SearchFunc: func(s types.SearchQuery) ([]types.Record, error) {
-			return []types.Record{}, nil
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
+			return []types.Record{types.Record{ID: "1"}}, 1234, nil
 		},
 	}
 	service := &WebService{DB: &db}
-	req, err := http.NewRequest("GET", "/record?minLat=53.635682044465476&maxLat=53.840373979032805&minLng=-1.1144256591796877&maxLng=-1.9713592529296877", nil)
+
+	req, err := http.NewRequest("GET", "/record?page=2&pageSize=1&"+leedsBounds, nil)
 	ok(t, err)
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(service.Search())
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+
+	if rr.Header().Get("X-Total-Count") != "1234" {
+		t.Errorf("Expected X-Total-Count of 1234 but got %s", rr.Header().Get("X-Total-Count"))
+	}
+
+	if rr.Header().Get("X-Page") != "2" || rr.Header().Get("X-Page-Size") != "1" {
+		t.Errorf("Expected page 2 of size 1 but got page %s of size %s", rr.Header().Get("X-Page"), rr.Header().Get("X-Page-Size"))
 	}
 }
 
-func TestUpdateReturnsErrorOnEmptyBody(t *testing.T) {
-	db := mockDB{}
+func TestSearchCanTakeRadius(t *testing.T) {
+	var passedQuery types.SearchQuery
+	db := mockDB{
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
+			passedQuery = s
+			return []types.Record{}, 0, nil
+		},
+	}
 	service := &WebService{DB: &db}
 
-	req, err := http.NewRequest("PUT", "/record/12345", nil)
+	req, err := http.NewRequest("GET", "/record?lat=53.7997&lng=-1.5492&radius=25000", nil)
 	ok(t, err)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(service.Update())
+	handler := http.HandlerFunc(service.Search())
 	handler.ServeHTTP(rr, req)
-	if rr.Code != 400 {
-		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+
+	if passedQuery.Lat != 53.7997 || passedQuery.Lng != -1.5492 || passedQuery.Radius != 25000 {
+		t.Errorf("Expected radius search around 53.7997,-1.5492 of 25000m but got %v,%v of %vm", passedQuery.Lat, passedQuery.Lng, passedQuery.Radius)
 	}
 }
 
-func TestUpdateReturnsErrorOnInvalidJSON(t *testing.T) {
-	db := mockDB{}
+func TestSuccessfulRadiusSearchReturnsDistance(t *testing.T) {
+	distance := 1234.5
+	db := mockDB{
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
+			return []types.Record{types.Record{ID: "1", Distance: &distance}}, 1, nil
+		},
+	}
 	service := &WebService{DB: &db}
 
-	badJSON := []byte(`{"abc":123, def:123}`)
-	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(badJSON))
+	req, err := http.NewRequest("GET", "/record?lat=53.7997&lng=-1.5492&radius=25000", nil)
 	ok(t, err)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(service.Update())
+	handler := http.HandlerFunc(service.Search())
 	handler.ServeHTTP(rr, req)
-	if rr.Code != 400 {
-		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+
+	if !strings.Contains(rr.Body.String(), `"distance":1234.5`) {
+		t.Errorf("Expected the distance to be returned but got: \n %s", rr.Body.String())
 	}
 }
 
-func TestUpdateReturnsServerErrorWhenNoDB(t *testing.T) {
-	service := &WebService{}
-	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+func TestRadiusSearchWithInvalidLatReturnsError(t *testing.T) {
+	db := mockDB{}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record?lat=95&lng=-1.5492&radius=25000", nil)
 	ok(t, err)
 
 	rr := httptest.NewRecorder()
-	updateRouter(service).ServeHTTP(rr, req)
-	if rr.Code != http.StatusInternalServerError {
-		t.Errorf("Expected internal server error status to be returned got %d", rr.Code)
+	handler := http.HandlerFunc(service.Search())
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
 	}
 }
 
-func TestUpdateReturnsErrorIfNoIdIsProvided(t *testing.T) {
+func TestRadiusSearchWithoutCentreReturnsError(t *testing.T) {
+	for _, params := range []string{"radius=25000", "lat=53.7997&radius=25000", "lng=-1.5492&radius=25000"} {
+		service := &WebService{DB: &mockDB{}}
 
-	service := &WebService{}
+		req, err := http.NewRequest("GET", "/record?"+params, nil)
+		ok(t, err)
 
-	req, err := http.NewRequest("PUT", "/record", bytes.NewBuffer(jsonReq))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(service.Search())
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected Bad Request (400) status to be returned for %s got %d", params, rr.Code)
+		}
+	}
+}
+
+func TestRadiusSearchCannotBeCombinedWithQuery(t *testing.T) {
+	db := mockDB{}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record?query=Leeds&lat=53.7997&lng=-1.5492&radius=25000", nil)
 	ok(t, err)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(service.Update())
+	handler := http.HandlerFunc(service.Search())
 	handler.ServeHTTP(rr, req)
-	if rr.Code != 400 {
+
+	if rr.Code != http.StatusBadRequest {
 		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
 	}
 }
 
-func updateRouter(service *WebService) *mux.Router {
-	r := mux.NewRouter()
-	r.HandleFunc("/record/{id}", service.Update())
-	return r
-}
+var polygonReq = []byte(`{
+	"type": "Polygon",
+	"coordinates": [[
+		[-1.97, 53.63], [-1.11, 53.63], [-1.11, 53.84], [-1.97, 53.84], [-1.97, 53.63]
+	]]
+}`)
 
-func TestUpdateCallsDatabaseWithRecordAndId(t *testing.T) {
-	called = false
-	var passedID string
+func TestSearchWithinPassesPolygonsToDB(t *testing.T) {
+	var passedQuery types.SearchQuery
 	db := mockDB{
-		UpdateFunc: func(id string, r types.Record) error {
-			called = true
-			passedID = id
-			return nil
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
+			passedQuery = s
+			return []types.Record{types.Record{ID: "1"}}, 1, nil
 		},
 	}
 	service := &WebService{DB: &db}
 
-	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	req, err := http.NewRequest("POST", "/record/search?query=Airport", bytes.NewBuffer(polygonReq))
 	ok(t, err)
 
 	rr := httptest.NewRecorder()
-	updateRouter(service).ServeHTTP(rr, req)
+	handler := http.HandlerFunc(service.SearchWithin())
+	handler.ServeHTTP(rr, req)
 
-	if !called {
-		t.Error("Expected database update method to be called")
-		t.FailNow()
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected OK (200) status to be returned got %d", rr.Code)
 	}
 
-	if passedID != "12345" {
-		t.Errorf("Expected id: %s \n Got Id: %s \n", "12345", passedID)
+	if len(passedQuery.Polygons) != 1 || len(passedQuery.Polygons[0][0]) != 5 {
+		t.Errorf("Expected one polygon with five positions to be passed but got: %v", passedQuery.Polygons)
 	}
-}
 
-func TestUpdateReturnsErrorWhenDBUpdateFails(t *testing.T) {
+	if passedQuery.Query != "Airport" {
+		t.Errorf("Expected query Airport but got: %s", passedQuery.Query)
+	}
+}
 
+func TestSearchWithinReturnsRecordArray(t *testing.T) {
 	db := mockDB{
-		UpdateFunc: func(id string, r types.Record) error {
-			return errors.New("Database failed")
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
+			return []types.Record{types.Record{ID: "1"}}, 1, nil
 		},
 	}
 	service := &WebService{DB: &db}
 
-	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	req, err := http.NewRequest("POST", "/record/search", bytes.NewBuffer(polygonReq))
 	ok(t, err)
 
 	rr := httptest.NewRecorder()
-	updateRouter(service).ServeHTTP(rr, req)
+	handler := http.HandlerFunc(service.SearchWithin())
+	handler.ServeHTTP(rr, req)
 
-	if rr.Code != 500 {
-		t.Errorf("Expected Internal Server Error (500) status to be returned got %d", rr.Code)
+	var records []types.Record
+	ok(t, json.NewDecoder(rr.Body).Decode(&records))
+	if len(records) != 1 || records[0].ID != "1" {
+		t.Errorf("Expected an array with one record but got: %v", records)
 	}
 }
 
-func TestUpdateReturnsOkIfRecordIsUpdated(t *testing.T) {
-	db := mockDB{
-		UpdateFunc: func(id string, r types.Record) error {
-			return nil
-		},
-	}
+func TestSearchWithinReturnsErrorOnInvalidGeometry(t *testing.T) {
+	db := mockDB{}
 	service := &WebService{DB: &db}
 
-	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	req, err := http.NewRequest("POST", "/record/search", bytes.NewBufferString(`{"type":"Point","coordinates":[-1.97,53.63]}`))
 	ok(t, err)
 
 	rr := httptest.NewRecorder()
-	updateRouter(service).ServeHTTP(rr, req)
+	handler := http.HandlerFunc(service.SearchWithin())
+	handler.ServeHTTP(rr, req)
 
-	if rr.Code != 200 {
-		t.Errorf("Expected OK (200) status to be returned got %d", rr.Code)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
 	}
 }
 
-func TestDeleteReturnsErrorIfNoIdProvided(t *testing.T) {
-	service := &WebService{}
+func TestSearchWithinReturnsErrorOnEmptyBody(t *testing.T) {
+	db := mockDB{}
+	service := &WebService{DB: &db}
 
-	req, err := http.NewRequest("DELETE", "/record", nil)
+	req, err := http.NewRequest("POST", "/record/search", nil)
 	ok(t, err)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(service.Delete())
+	handler := http.HandlerFunc(service.SearchWithin())
 	handler.ServeHTTP(rr, req)
-	if rr.Code != 400 {
+
+	if rr.Code != http.StatusBadRequest {
 		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
 	}
 }
 
-func deleteRouter(service *WebService) *mux.Router {
-	r := mux.NewRouter()
-	r.HandleFunc("/record/{id}", service.Delete())
-	return r
-}
-
-func TestDeleteCallsDatabaseDelete(t *testing.T) {
-	called = false
-	var passedID string
+func TestSearchPassesRepeatedFacilities(t *testing.T) {
+	var passedQuery types.SearchQuery
 	db := mockDB{
-		DeleteFunc: func(id string) error {
-			called = true
-			passedID = id
-			return nil
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
+			passedQuery = s
+			return []types.Record{}, 0, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record?facility=TAXI&facility=TRN&facilityMatch=all&"+leedsBounds, nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Search())
+	handler.ServeHTTP(rr, req)
+
+	if len(passedQuery.Facilities) != 2 || passedQuery.Facilities[0] != "TAXI" || passedQuery.Facilities[1] != "TRN" {
+		t.Errorf("Expected facilities [TAXI TRN] but got %v", passedQuery.Facilities)
+	}
+
+	if passedQuery.FacilityMatch != "all" {
+		t.Errorf("Expected facility match all but got %s", passedQuery.FacilityMatch)
+	}
+}
+
+func TestSearchWithInvalidFacilityMatchReturnsError(t *testing.T) {
+	db := mockDB{}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record?facility=TAXI&facilityMatch=some&"+leedsBounds, nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Search())
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestSearchPassesResolvedDetailFilters(t *testing.T) {
+	var passedQuery types.SearchQuery
+	db := mockDB{
+		GetFieldsFunc: func() ([]types.Field, error) {
+			return []types.Field{types.Field{ID: "1", Value: "Runways"}}, nil
+		},
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
+			passedQuery = s
+			return []types.Record{}, 0, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record?details.1[gt]=2&"+leedsBounds, nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Search())
+	handler.ServeHTTP(rr, req)
+
+	expected := []types.DetailFilter{{Key: "runways", Operator: types.OperatorGt, Value: "2"}}
+	if !reflect.DeepEqual(passedQuery.Details, expected) {
+		t.Errorf("Expected detail filters %v but got %v", expected, passedQuery.Details)
+	}
+}
+
+func TestSearchWithUnknownDetailFieldReturnsError(t *testing.T) {
+	db := mockDB{
+		GetFieldsFunc: func() ([]types.Field, error) {
+			return []types.Field{types.Field{ID: "1", Value: "Runways"}}, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record?details.colour=red&"+leedsBounds, nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Search())
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestIfBoundsAreNotProvidedErrorIsReturned(t *testing.T) {
+	db := mockDB{}
+	service := &WebService{DB: &db}
+	req, err := http.NewRequest("GET", "/record?query=Leeds", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Search())
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestMinLatCannotBeGreaterThanMaxLatReturnsError(t *testing.T) {
+	db := mockDB{
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
+			return []types.Record{}, 0, nil
+		},
+	}
+	service := &WebService{DB: &db}
+	req, err := http.NewRequest("GET", "/record?minLat=53.840373979032805&maxLat=53.635682044465476&minLng=-1.9713592529296877&maxLng=-1.1144256591796877", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Search())
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestMinLngCannotBeGreaterThanMaxLngReturnsError(t *testing.T) {
+	db := mockDB{
+		SearchFunc: func(s types.SearchQuery) ([]types.Record, int, error) {
+			return []types.Record{}, 0, nil
+		},
+	}
+	service := &WebService{DB: &db}
+	req, err := http.NewRequest("GET", "/record?minLat=53.635682044465476&maxLat=53.840373979032805&minLng=-1.1144256591796877&maxLng=-1.9713592529296877", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Search())
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func getRouter(service *WebService) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/record/{id}", service.Get())
+	return r
+}
+
+func TestGetReturnsErrorIfNoIdProvided(t *testing.T) {
+	service := &WebService{}
+
+	req, err := http.NewRequest("GET", "/record", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Get())
+	handler.ServeHTTP(rr, req)
+	if rr.Code != 400 {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestGetCallsDatabaseWithId(t *testing.T) {
+	var passedID string
+	db := mockDB{
+		GetFunc: func(id string) (types.Record, error) {
+			passedID = id
+			return types.Record{ID: id}, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record/12345", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	getRouter(service).ServeHTTP(rr, req)
+
+	if passedID != "12345" {
+		t.Errorf("Expected id: %s \n Got Id: %s \n", "12345", passedID)
+	}
+}
+
+func TestGetReturnsRecord(t *testing.T) {
+	expectedResult := `{"id":"12345","title":"Holy Trinity Church","location":{"type":"","Coordinates":null,"lat":53.8623095466826,"lng":-1.61906075748197,"country":""},"shortName":"","facilities":null,"details":null,"version":3}`
+	db := mockDB{
+		GetFunc: func(id string) (types.Record, error) {
+			var record types.Record
+			err := json.NewDecoder(bytes.NewBufferString(expectedResult)).Decode(&record)
+			ok(t, err)
+			return record, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record/12345", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	getRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected OK (200) status to be returned got %d", rr.Code)
+	}
+
+	if strings.TrimSpace(rr.Body.String()) != expectedResult {
+		t.Errorf("Expected response to be: \n %s \n but got: \n %s", expectedResult, rr.Body.String())
+	}
+
+	if rr.Header().Get("ETag") != `"3"` {
+		t.Errorf("Expected ETag of the record version \"3\" but got %s", rr.Header().Get("ETag"))
+	}
+}
+
+func TestGetReturnsNotFoundForUnknownRecord(t *testing.T) {
+	db := mockDB{
+		GetFunc: func(id string) (types.Record, error) {
+			return types.Record{}, &types.RecordNotFoundError{ID: id, Message: "Record does not exist in the database."}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record/12345", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	getRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestGetReturnsServerErrorWhenDBFails(t *testing.T) {
+	db := mockDB{
+		GetFunc: func(id string) (types.Record, error) {
+			return types.Record{}, errors.New("Database failed")
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record/12345", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	getRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected Internal Server Error (500) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestUpdateReturnsErrorOnEmptyBody(t *testing.T) {
+	db := mockDB{}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Update())
+	handler.ServeHTTP(rr, req)
+	if rr.Code != 400 {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestUpdateReturnsErrorOnInvalidJSON(t *testing.T) {
+	db := mockDB{}
+	service := &WebService{DB: &db}
+
+	badJSON := []byte(`{"abc":123, def:123}`)
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(badJSON))
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Update())
+	handler.ServeHTTP(rr, req)
+	if rr.Code != 400 {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestUpdateReturnsServerErrorWhenNoDB(t *testing.T) {
+	service := &WebService{}
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected internal server error status to be returned got %d", rr.Code)
+	}
+}
+
+func TestUpdateReturnsErrorIfNoIdIsProvided(t *testing.T) {
+
+	service := &WebService{}
+
+	req, err := http.NewRequest("PUT", "/record", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Update())
+	handler.ServeHTTP(rr, req)
+	if rr.Code != 400 {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func updateRouter(service *WebService) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/record/{id}", service.Update())
+	return r
+}
+
+func TestUpdateCallsDatabaseWithRecordAndId(t *testing.T) {
+	called = false
+	var passedID string
+	db := mockDB{
+		UpdateFunc: func(id string, r types.Record) error {
+			called = true
+			passedID = id
+			return nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+	req.Header.Set("If-Match", `"1"`)
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+
+	if !called {
+		t.Error("Expected database update method to be called")
+		t.FailNow()
+	}
+
+	if passedID != "12345" {
+		t.Errorf("Expected id: %s \n Got Id: %s \n", "12345", passedID)
+	}
+}
+
+func TestUpdateReturnsErrorWhenDBUpdateFails(t *testing.T) {
+
+	db := mockDB{
+		UpdateFunc: func(id string, r types.Record) error {
+			return errors.New("Database failed")
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+	req.Header.Set("If-Match", `"1"`)
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != 500 {
+		t.Errorf("Expected Internal Server Error (500) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestUpdateReturnsOkIfRecordIsUpdated(t *testing.T) {
+	db := mockDB{
+		UpdateFunc: func(id string, r types.Record) error {
+			return nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+	req.Header.Set("If-Match", `"1"`)
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != 200 {
+		t.Errorf("Expected OK (200) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestUpdateReturnsUnprocessableEntityForInvalidDetails(t *testing.T) {
+	db := mockDB{
+		UpdateFunc: func(id string, r types.Record) error {
+			return &types.ValidationError{Message: "Record details do not match the fields"}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+	req.Header.Set("If-Match", `"1"`)
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected Unprocessable Entity (422) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestUpdateReturnsNotFoundForUnknownRecord(t *testing.T) {
+	db := mockDB{
+		UpdateFunc: func(id string, r types.Record) error {
+			return &types.RecordNotFoundError{ID: id, Message: "Record does not exist in the database."}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+	req.Header.Set("If-Match", `"1"`)
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestUpdateIsAttributedToTheActor(t *testing.T) {
+	db := mockDB{
+		UpdateFunc: func(id string, r types.Record) error {
+			return nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+	req.Header.Set("If-Match", `"1"`)
+	req.Header.Set("X-Actor", "sam")
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+
+	if db.Actor != "sam" {
+		t.Errorf("Expected update to be made as sam but was made as %s", db.Actor)
+	}
+}
+
+func TestUpdateIsAttributedToTheAuthenticatedPrincipal(t *testing.T) {
+	db := mockDB{
+		UpdateFunc: func(id string, r types.Record) error {
+			return nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+	req.Header.Set("If-Match", `"1"`)
+	req.Header.Set("X-Actor", "someone-else")
+	req = req.WithContext(auth.NewContext(req.Context(), auth.Principal{Subject: "1234", Name: "sam@example.com"}))
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+
+	if db.Actor != "sam@example.com" {
+		t.Errorf("Expected update to be made as sam@example.com but was made as %s", db.Actor)
+	}
+}
+
+func TestOnlyHealthCheckIsPublic(t *testing.T) {
+	router := NewRouter(&WebService{DB: &mockDB{}})
+
+	var public bool
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			public = Public(r)
+		})
+	})
+
+	tests := map[string]bool{
+		"GET /v1/":            true,
+		"GET /v1/record":      false,
+		"POST /v1/record":     false,
+		"GET /v1/field":       false,
+		"DELETE /v1/field/12": false,
+	}
+
+	for test, expected := range tests {
+		parts := strings.SplitN(test, " ", 2)
+		req, err := http.NewRequest(parts[0], parts[1], nil)
+		ok(t, err)
+
+		public = !expected
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		if public != expected {
+			t.Errorf("Expected %s public to be %v", test, expected)
+		}
+	}
+}
+
+func TestOnlyEventsTakeQueryTokens(t *testing.T) {
+	router := NewRouter(&WebService{DB: &mockDB{}})
+
+	var query bool
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query = QueryToken(r)
+		})
+	})
+
+	tests := map[string]bool{
+		"GET /v1/events":          true,
+		"GET /v1/w/team-a/events": true,
+		"GET /v1/record":          false,
+		"POST /v1/record/bulk":    false,
+		"GET /v1/webhook":         false,
+	}
+
+	for test, expected := range tests {
+		parts := strings.SplitN(test, " ", 2)
+		req, err := http.NewRequest(parts[0], parts[1], nil)
+		ok(t, err)
+
+		query = !expected
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		if query != expected {
+			t.Errorf("Expected %s to take query tokens to be %v", test, expected)
+		}
+	}
+}
+
+func TestUpdateRequiresIfMatch(t *testing.T) {
+	called = false
+	db := mockDB{
+		UpdateFunc: func(id string, r types.Record) error {
+			called = true
+			return nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected Precondition Required (428) status to be returned got %d", rr.Code)
+	}
+
+	if called {
+		t.Error("Expected database update method not to be called")
+	}
+}
+
+func TestUpdateRejectsInvalidIfMatch(t *testing.T) {
+	db := mockDB{}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+	req.Header.Set("If-Match", "latest")
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestUpdatePassesVersionFromIfMatch(t *testing.T) {
+	var passedVersion int
+	db := mockDB{
+		UpdateFunc: func(id string, r types.Record) error {
+			passedVersion = r.Version
+			return nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+	req.Header.Set("If-Match", `W/"4"`)
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+
+	if passedVersion != 4 {
+		t.Errorf("Expected version 4 to be passed to the database but got %d", passedVersion)
+	}
+
+	if rr.Header().Get("ETag") != `"5"` {
+		t.Errorf("Expected ETag of the new version \"5\" but got %s", rr.Header().Get("ETag"))
+	}
+}
+
+func TestUpdateReturnsConflictWithCurrentRecord(t *testing.T) {
+	current := types.Record{ID: "12345", Title: "Changed elsewhere", Version: 3}
+	db := mockDB{
+		UpdateFunc: func(id string, r types.Record) error {
+			return &types.ConflictError{ID: id, Message: "Record has been changed since it was read.", Current: current}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("PUT", "/record/12345", bytes.NewBuffer(jsonReq))
+	ok(t, err)
+	req.Header.Set("If-Match", `"2"`)
+
+	rr := httptest.NewRecorder()
+	updateRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected Conflict (409) status to be returned got %d", rr.Code)
+	}
+
+	if rr.Header().Get("ETag") != `"3"` {
+		t.Errorf("Expected ETag of the current version \"3\" but got %s", rr.Header().Get("ETag"))
+	}
+
+	var returned types.Record
+	ok(t, json.NewDecoder(rr.Body).Decode(&returned))
+	if returned.Title != current.Title || returned.Version != current.Version {
+		t.Errorf("Expected the current record to be returned but got %+v", returned)
+	}
+}
+
+func TestDeleteReturnsErrorIfNoIdProvided(t *testing.T) {
+	service := &WebService{}
+
+	req, err := http.NewRequest("DELETE", "/record", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.Delete())
+	handler.ServeHTTP(rr, req)
+	if rr.Code != 400 {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func deleteRouter(service *WebService) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/record/{id}", service.Delete())
+	return r
+}
+
+func TestDeleteCallsDatabaseDelete(t *testing.T) {
+	called = false
+	var passedID string
+	db := mockDB{
+		DeleteFunc: func(id string) error {
+			called = true
+			passedID = id
+			return nil
 		},
 	}
 	service := &WebService{DB: &db}
@@ -626,6 +1549,140 @@ func TestSuccessfulDeleteOkIsReturned(t *testing.T) {
 	}
 }
 
+func TestDeleteReturnsNotFoundForUnknownRecord(t *testing.T) {
+	db := mockDB{
+		DeleteFunc: func(id string) error {
+			return &types.RecordNotFoundError{ID: id, Message: "Record does not exist in the database."}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("DELETE", "/record/12345", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	deleteRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) status to be returned got %d", rr.Code)
+	}
+}
+
+func historyRouter(service *WebService) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/record/{id}/history", service.History())
+	r.HandleFunc("/record/{id}/history/{rev}", service.Revision())
+	return r
+}
+
+func TestHistoryReturnsRevisions(t *testing.T) {
+	var passedID string
+	db := mockDB{
+		HistoryFunc: func(id string) ([]types.Revision, error) {
+			passedID = id
+			return []types.Revision{
+				{RecordID: id, Rev: 2, Action: types.ActionUpdated, Actor: "sam", Changed: []string{"title"}},
+				{RecordID: id, Rev: 1, Action: types.ActionCreated, Actor: "sam", Changed: []string{"title"}},
+			}, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record/12345/history", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	historyRouter(service).ServeHTTP(rr, req)
+
+	if passedID != "12345" {
+		t.Errorf("Expected id: %s \n Got Id: %s \n", "12345", passedID)
+	}
+
+	var revisions []types.Revision
+	ok(t, json.NewDecoder(rr.Body).Decode(&revisions))
+	if len(revisions) != 2 || revisions[0].Rev != 2 {
+		t.Errorf("Expected two revisions newest first but got %v", revisions)
+	}
+}
+
+func TestHistoryReturnsNotFoundForUnknownRecord(t *testing.T) {
+	db := mockDB{
+		HistoryFunc: func(id string) ([]types.Revision, error) {
+			return nil, &types.RecordNotFoundError{ID: id, Message: "Record has no history."}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record/12345/history", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	historyRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestRevisionPassesRevToDB(t *testing.T) {
+	var passedRev int
+	db := mockDB{
+		RevisionFunc: func(id string, rev int) (types.Revision, error) {
+			passedRev = rev
+			return types.Revision{RecordID: id, Rev: rev, Record: &types.Record{ID: id}}, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record/12345/history/3", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	historyRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected OK (200) status to be returned got %d", rr.Code)
+	}
+
+	if passedRev != 3 {
+		t.Errorf("Expected revision 3 but got %d", passedRev)
+	}
+}
+
+func TestRevisionMustBeANumber(t *testing.T) {
+	db := mockDB{}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record/12345/history/latest", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	historyRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestRevisionReturnsNotFoundForUnknownRevision(t *testing.T) {
+	db := mockDB{
+		RevisionFunc: func(id string, rev int) (types.Revision, error) {
+			return types.Revision{}, &types.RevisionNotFoundError{ID: id, Rev: rev, Message: "Revision does not exist in the database."}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record/12345/history/9", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	historyRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) status to be returned got %d", rr.Code)
+	}
+}
+
 func TestGetFieldsCallsGetFieldsOnTheDatabase(t *testing.T) {
 	called = false
 
@@ -785,6 +1842,34 @@ func TestUpdateFieldCallsDBWithFields(t *testing.T) {
 	}
 }
 
+func TestUpdateFieldsRejectsEnumWithoutOptions(t *testing.T) {
+	called = false
+	db := mockDB{
+		UpdateFieldsFunc: func(f []types.Field) error {
+			called = true
+			return nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	fieldJSON := []byte(`[{"id":"1","value":"Status","order":1,"type":"enum"}]`)
+
+	req, err := http.NewRequest("PUT", "/field", bytes.NewBuffer(fieldJSON))
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(service.UpdateFields())
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected Bad Request (400) status to be returned got %d", rr.Code)
+	}
+
+	if called {
+		t.Error("Expected invalid fields not to be written to the database")
+	}
+}
+
 func TestStatus500ReturnedIfUnableToWriteFieldsToDatabase(t *testing.T) {
 
 	db := mockDB{
@@ -0,0 +1,218 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/dyninc/qstring"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeletedRecords lists the records that have been deleted, most recently
+// deleted first
+// Path: /record/deleted
+// Method: GET
+// Parameters:
+//		page - (optional) page of results to return, starting at 1
+//		pageSize - (optional) number of results per page, defaults to 100 and
+//				is capped at 500
+// Example: /record/deleted?page=2
+func (s *WebService) DeletedRecords() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "deletedRecords",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		query := &types.SearchQuery{}
+		err := qstring.Unmarshal(r.URL.Query(), query)
+		if err != nil || query.Page < 0 || query.PageSize < 0 {
+			logger.WithFields(log.Fields{
+				"status": 400,
+			}).Error("Invalid page requested")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Page and page size must be positive numbers"})
+			return
+		}
+		setPage(query)
+
+		records, total, err := s.db(r).Deleted(query.Page, query.PageSize)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+			}).Error("Failed to get deleted records")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to get deleted records"})
+			return
+		}
+
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		w.Header().Set("X-Page", strconv.Itoa(query.Page))
+		w.Header().Set("X-Page-Size", strconv.Itoa(query.PageSize))
+
+		if len(records) == 0 {
+			logger.WithFields(log.Fields{
+				"status": 404,
+			}).Info("No deleted records found")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("[]"))
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status":         200,
+			"numberReturned": len(records),
+			"total":          total,
+		}).Info("Returning deleted records")
+		json.NewEncoder(w).Encode(records)
+	}
+}
+
+// Restore brings back a deleted record
+// Path: /record/{id}/restore
+// Method: POST
+// Example: /record/12345/restore
+func (s *WebService) Restore() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "restore",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := getRecordID(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Issue with ID")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		err = s.db(r).Restore(id)
+		if err != nil {
+			if _, ok := err.(*types.RecordNotFoundError); ok {
+				logger.WithFields(log.Fields{
+					"status": 404,
+					"id":     id,
+				}).Warn("Couldn't find deleted record to restore")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+				"id":     id,
+			}).Error("Failed to restore record")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to restore record"})
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"id":     id,
+		}).Info("Restored record")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// DeletedFields lists the fields that have been deleted
+// Path: /field/deleted
+// Method: GET
+// Example: /field/deleted
+func (s *WebService) DeletedFields() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "deletedFields",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		fields, err := s.db(r).DeletedFields()
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+			}).Error("Failed to get deleted fields")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to get deleted fields"})
+			return
+		}
+
+		if len(fields) == 0 {
+			logger.WithFields(log.Fields{
+				"status": 404,
+			}).Info("No deleted fields found")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("[]"))
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"fieldCount": len(fields),
+		}).Info("Returning deleted fields")
+		json.NewEncoder(w).Encode(fields)
+	}
+}
+
+// RestoreField brings back a deleted field
+// Path: /field/{id}/restore
+// Method: POST
+// Example: /field/12345/restore
+func (s *WebService) RestoreField() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "restoreField",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := getRecordID(r)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+				"error":  err.Error(),
+			}).Warn("Issue with ID")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		err = s.db(r).RestoreField(id)
+		if err != nil {
+			if _, ok := err.(*types.FieldNotFoundError); ok {
+				logger.WithFields(log.Fields{
+					"status": 404,
+					"id":     id,
+				}).Warn("Couldn't find deleted field to restore")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+				return
+			}
+
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+				"id":     id,
+			}).Error("Failed to restore field")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to restore field"})
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"id":     id,
+		}).Info("Restored field")
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,176 @@
+package knowledge
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/gorilla/mux"
+)
+
+func trashRouter(service *WebService) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/record/deleted", service.DeletedRecords())
+	r.HandleFunc("/record/{id}/restore", service.Restore())
+	r.HandleFunc("/field/deleted", service.DeletedFields())
+	r.HandleFunc("/field/{id}/restore", service.RestoreField())
+	return r
+}
+
+func TestDeletedRecordsArePaged(t *testing.T) {
+	var passedPage, passedPageSize int
+	deletedAt := time.Now()
+	db := mockDB{
+		DeletedFunc: func(page, pageSize int) ([]types.Record, int, error) {
+			passedPage, passedPageSize = page, pageSize
+			return []types.Record{{ID: "1", DeletedAt: &deletedAt}}, 1, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record/deleted?page=2", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	trashRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected OK (200) status to be returned got %d", rr.Code)
+	}
+
+	if passedPage != 2 || passedPageSize != DefaultPageSize {
+		t.Errorf("Expected page 2 of size %d but got page %d of size %d", DefaultPageSize, passedPage, passedPageSize)
+	}
+
+	if rr.Header().Get("X-Total-Count") != "1" {
+		t.Errorf("Expected X-Total-Count of 1 but got %s", rr.Header().Get("X-Total-Count"))
+	}
+}
+
+func TestNoDeletedRecordsReturnsNotFound(t *testing.T) {
+	db := mockDB{
+		DeletedFunc: func(page, pageSize int) ([]types.Record, int, error) {
+			return nil, 0, nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/record/deleted", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	trashRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestRestoreCallsDatabaseWithId(t *testing.T) {
+	var passedID string
+	db := mockDB{
+		RestoreFunc: func(id string) error {
+			passedID = id
+			return nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("POST", "/record/12345/restore", nil)
+	ok(t, err)
+	req.Header.Set("X-Actor", "sam")
+
+	rr := httptest.NewRecorder()
+	trashRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected OK (200) status to be returned got %d", rr.Code)
+	}
+
+	if passedID != "12345" {
+		t.Errorf("Expected id: %s \n Got Id: %s \n", "12345", passedID)
+	}
+
+	if db.Actor != "sam" {
+		t.Errorf("Expected restore to be made as sam but was made as %s", db.Actor)
+	}
+}
+
+func TestRestoreReturnsNotFoundWhenNotDeleted(t *testing.T) {
+	db := mockDB{
+		RestoreFunc: func(id string) error {
+			return &types.RecordNotFoundError{ID: id, Message: "Deleted record does not exist in the database."}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("POST", "/record/12345/restore", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	trashRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestDeletedFieldsReturnsServerErrorWhenDBFails(t *testing.T) {
+	db := mockDB{
+		DeletedFieldsFunc: func() ([]types.Field, error) {
+			return nil, errors.New("Database failed")
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("GET", "/field/deleted", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	trashRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected Internal Server Error (500) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestRestoreFieldReturnsNotFoundWhenNotDeleted(t *testing.T) {
+	db := mockDB{
+		RestoreFieldFunc: func(id string) error {
+			return &types.FieldNotFoundError{ID: id, Message: "Deleted field does not exist in the database."}
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("POST", "/field/12345/restore", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	trashRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestRestoreFieldReturnsOkOnSuccess(t *testing.T) {
+	db := mockDB{
+		RestoreFieldFunc: func(id string) error {
+			return nil
+		},
+	}
+	service := &WebService{DB: &db}
+
+	req, err := http.NewRequest("POST", "/field/12345/restore", nil)
+	ok(t, err)
+
+	rr := httptest.NewRecorder()
+	trashRouter(service).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected OK (200) status to be returned got %d", rr.Code)
+	}
+}
@@ -0,0 +1,382 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/events"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/webhook"
+	"github.com/dyninc/qstring"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// webhookError writes the response for an error from the webhooks, 404 when
+// the webhook or delivery doesn't exist otherwise 500
+func webhookError(w http.ResponseWriter, logger *log.Entry, err error, id, message string) {
+	_, missingWebhook := err.(*types.WebhookNotFoundError)
+	_, missingDelivery := err.(*types.DeliveryNotFoundError)
+	if missingWebhook || missingDelivery {
+		logger.WithFields(log.Fields{
+			"status": 404,
+			"id":     id,
+			"error":  err.Error(),
+		}).Warn("Webhook or delivery not found")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	logger.WithFields(log.Fields{
+		"status": 500,
+		"id":     id,
+		"error":  err.Error(),
+	}).Error(message)
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(&ErrorResponse{Message: message})
+}
+
+// readWebhook reads the webhook from the body, writing the response when
+// it's missing or invalid
+func readWebhook(w http.ResponseWriter, r *http.Request, logger *log.Entry) (types.Webhook, bool) {
+	var h types.Webhook
+
+	if r.Body == nil {
+		logger.WithFields(log.Fields{
+			"status": 400,
+		}).Warn("No body provided")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "No body provided"})
+		return h, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"status": 400,
+		}).Error("Unable to parse JSON")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to parse JSON"})
+		return h, false
+	}
+
+	err := h.Validate()
+	for _, e := range h.Events {
+		if err == nil && !events.ValidType(e) {
+			err = fmt.Errorf("Unknown event %s", e)
+		}
+	}
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error":  err.Error(),
+			"status": 400,
+		}).Warn("Invalid webhook")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+		return h, false
+	}
+
+	if h.Events == nil {
+		h.Events = []string{}
+	}
+
+	return h, true
+}
+
+// listDeliveries writes a page of the deliveries to a webhook, or to every
+// webhook when id is empty, with the paging headers
+func (s *WebService) listDeliveries(w http.ResponseWriter, r *http.Request, logger *log.Entry, id, status string) {
+	query := &types.SearchQuery{}
+	err := qstring.Unmarshal(r.URL.Query(), query)
+	if err != nil || query.Page < 0 || query.PageSize < 0 {
+		logger.WithFields(log.Fields{
+			"status": 400,
+		}).Error("Invalid page requested")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "Page and page size must be positive numbers"})
+		return
+	}
+	setPage(query)
+
+	if status != "" && status != types.DeliveryPending && status != types.DeliveryDelivered && status != types.DeliveryDead {
+		logger.WithFields(log.Fields{
+			"status": 400,
+		}).Warn("Invalid delivery status")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&ErrorResponse{Message: "Status must be pending, delivered or dead"})
+		return
+	}
+
+	db := s.db(r)
+	if id != "" {
+		if _, err := db.GetWebhook(id); err != nil {
+			webhookError(w, logger, err, id, "Failed to get webhook")
+			return
+		}
+	}
+
+	deliveries, total, err := db.Deliveries(id, status, query.Page, query.PageSize)
+	if err != nil {
+		webhookError(w, logger, err, id, "Failed to get deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []types.Delivery{}
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Set("X-Page", strconv.Itoa(query.Page))
+	w.Header().Set("X-Page-Size", strconv.Itoa(query.PageSize))
+
+	logger.WithFields(log.Fields{
+		"status":         200,
+		"id":             id,
+		"numberReturned": len(deliveries),
+		"total":          total,
+	}).Info("Returning deliveries")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// Webhooks lists the webhooks of the workspace, without their secrets
+// Path: /webhook
+// Method: GET
+func (s *WebService) Webhooks() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "webhooks",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		webhooks, err := s.db(r).Webhooks()
+		if err != nil {
+			webhookError(w, logger, err, "", "Failed to get webhooks")
+			return
+		}
+
+		for i := range webhooks {
+			webhooks[i].Secret = ""
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"count":  len(webhooks),
+		}).Info("Listed webhooks")
+		json.NewEncoder(w).Encode(webhooks)
+	}
+}
+
+// CreateWebhook subscribes a URL to changes to the records and fields of the
+// workspace
+// Path: /webhook
+// Method: POST
+// Example: /webhook
+//		Body: {
+//					"url": "https://tickets.example.com/hooks/knowledge",
+//					"events": ["record.created", "record.deleted"]
+//				}
+// Events are sent to the URL as JSON, signed in the X-Hub-Signature-256
+// header. Leave out events to be sent all of them. A secret to sign them
+// with is generated unless one is given, it is only returned in this
+// response.
+func (s *WebService) CreateWebhook() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "createWebhook",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		h, ok := readWebhook(w, r, logger)
+		if !ok {
+			return
+		}
+
+		if h.Secret == "" {
+			secret, err := webhook.NewSecret()
+			if err != nil {
+				webhookError(w, logger, err, "", "Failed to create webhook secret")
+				return
+			}
+			h.Secret = secret
+		}
+
+		created, err := s.db(r).CreateWebhook(h)
+		if err != nil {
+			webhookError(w, logger, err, "", "Failed to create webhook")
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 201,
+			"id":     created.ID,
+			"url":    created.URL,
+		}).Info("Created webhook")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// GetWebhook returns a webhook, without its secret
+// Path: /webhook/{id}
+// Method: GET
+func (s *WebService) GetWebhook() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "getWebhook",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id := mux.Vars(r)["id"]
+		h, err := s.db(r).GetWebhook(id)
+		if err != nil {
+			webhookError(w, logger, err, id, "Failed to get webhook")
+			return
+		}
+		h.Secret = ""
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"id":     id,
+		}).Info("Returning webhook")
+		json.NewEncoder(w).Encode(h)
+	}
+}
+
+// UpdateWebhook changes the URL and events of a webhook
+// Path: /webhook/{id}
+// Method: PUT
+// Example: /webhook/5c1a...
+//		Body: {
+//					"url": "https://tickets.example.com/hooks/knowledge",
+//					"events": []
+//				}
+// The secret is kept unless a new one is given.
+func (s *WebService) UpdateWebhook() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "updateWebhook",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		h, ok := readWebhook(w, r, logger)
+		if !ok {
+			return
+		}
+		h.ID = mux.Vars(r)["id"]
+
+		updated, err := s.db(r).UpdateWebhook(h)
+		if err != nil {
+			webhookError(w, logger, err, h.ID, "Failed to update webhook")
+			return
+		}
+		updated.Secret = ""
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"id":     h.ID,
+		}).Info("Updated webhook")
+		json.NewEncoder(w).Encode(updated)
+	}
+}
+
+// DeleteWebhook stops sending events to a webhook and removes its deliveries
+// Path: /webhook/{id}
+// Method: DELETE
+func (s *WebService) DeleteWebhook() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "deleteWebhook",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id := mux.Vars(r)["id"]
+		if err := s.db(r).DeleteWebhook(id); err != nil {
+			webhookError(w, logger, err, id, "Failed to delete webhook")
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"id":     id,
+		}).Info("Deleted webhook")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// WebhookDeliveries lists the deliveries made to a webhook, newest first,
+// with every attempt to send them. Deliveries are kept for 30 days.
+// Path: /webhook/{id}/delivery
+// Method: GET
+// Parameters:
+//		status - (optional) only list deliveries that are pending, delivered or
+//				dead
+//		page - (optional) page of results to return, starting at 1
+//		pageSize - (optional) number of results per page, defaults to 100 and
+//				is capped at 500
+// Example: /webhook/5c1a.../delivery?status=pending
+func (s *WebService) WebhookDeliveries() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "webhookDeliveries",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		s.listDeliveries(w, r, logger, mux.Vars(r)["id"], r.URL.Query().Get("status"))
+	}
+}
+
+// DeadDeliveries lists the deliveries to any webhook of the workspace that
+// have been given up on, newest first
+// Path: /webhook/dead-letter
+// Method: GET
+// Parameters:
+//		page - (optional) page of results to return, starting at 1
+//		pageSize - (optional) number of results per page, defaults to 100 and
+//				is capped at 500
+func (s *WebService) DeadDeliveries() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "deadDeliveries",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		s.listDeliveries(w, r, logger, "", types.DeliveryDead)
+	}
+}
+
+// RetryDelivery sends a dead delivery again straight away, it's given up on
+// again if that attempt fails
+// Path: /webhook/{id}/delivery/{delivery}/retry
+// Method: POST
+func (s *WebService) RetryDelivery() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "retryDelivery",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id := mux.Vars(r)["id"]
+		deliveryID := mux.Vars(r)["delivery"]
+
+		delivery, err := s.db(r).RetryDelivery(id, deliveryID)
+		if err != nil {
+			webhookError(w, logger, err, deliveryID, "Failed to retry delivery")
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status":   200,
+			"id":       id,
+			"delivery": deliveryID,
+		}).Info("Retrying delivery")
+		json.NewEncoder(w).Encode(delivery)
+	}
+}
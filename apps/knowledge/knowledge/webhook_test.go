@@ -0,0 +1,140 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/gorilla/mux"
+)
+
+func webhookRouter(s *WebService) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/webhook", s.Webhooks()).Methods("GET")
+	router.HandleFunc("/webhook", s.CreateWebhook()).Methods("POST")
+	router.HandleFunc("/webhook/dead-letter", s.DeadDeliveries()).Methods("GET")
+	router.HandleFunc("/webhook/{id}", s.GetWebhook()).Methods("GET")
+	router.HandleFunc("/webhook/{id}/delivery", s.WebhookDeliveries()).Methods("GET")
+	router.HandleFunc("/webhook/{id}/delivery/{delivery}/retry", s.RetryDelivery()).Methods("POST")
+	return router
+}
+
+func TestCreateWebhookReturnsAGeneratedSecretOnce(t *testing.T) {
+	var created types.Webhook
+	db := &mockDB{
+		CreateWebhookFunc: func(h types.Webhook) (types.Webhook, error) {
+			h.ID = "hook-1"
+			created = h
+			return h, nil
+		},
+		GetWebhookFunc: func(id string) (types.Webhook, error) {
+			return created, nil
+		},
+	}
+	router := webhookRouter(&WebService{DB: db})
+
+	req, err := http.NewRequest("POST", "/webhook", bytes.NewBufferString(`{"url":"https://tickets.example.com/hooks","events":["record.created"]}`))
+	ok(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected %d but got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var response types.Webhook
+	ok(t, json.NewDecoder(rr.Body).Decode(&response))
+	if !strings.HasPrefix(response.Secret, "whsec_") || response.Secret != created.Secret {
+		t.Errorf("Expected the generated secret to be returned but got %q", response.Secret)
+	}
+
+	req, err = http.NewRequest("GET", "/webhook/hook-1", nil)
+	ok(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if strings.Contains(rr.Body.String(), created.Secret) {
+		t.Errorf("Expected the secret not to be returned again but got %s", rr.Body.String())
+	}
+}
+
+func TestCreateWebhookRejectsUnknownEvents(t *testing.T) {
+	router := webhookRouter(&WebService{DB: &mockDB{}})
+
+	for _, body := range []string{
+		`{"url":"https://tickets.example.com/hooks","events":["record.moved"]}`,
+		`{"url":"ftp://tickets.example.com/hooks"}`,
+		`{"url":"https://tickets.example.com/hooks","secret":"short"}`,
+	} {
+		req, err := http.NewRequest("POST", "/webhook", bytes.NewBufferString(body))
+		ok(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected %d for %s but got %d", http.StatusBadRequest, body, rr.Code)
+		}
+	}
+}
+
+func TestDeadDeliveriesListsDeadDeliveriesOfEveryWebhook(t *testing.T) {
+	var webhookID, status string
+	db := &mockDB{
+		DeliveriesFunc: func(id, s string, page, pageSize int) ([]types.Delivery, int, error) {
+			webhookID, status = id, s
+			return []types.Delivery{{ID: "delivery-1", WebhookID: "hook-1", Status: types.DeliveryDead}}, 1, nil
+		},
+	}
+
+	req, err := http.NewRequest("GET", "/webhook/dead-letter", nil)
+	ok(t, err)
+	rr := httptest.NewRecorder()
+	webhookRouter(&WebService{DB: db}).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected %d but got %d", http.StatusOK, rr.Code)
+	}
+	if webhookID != "" || status != types.DeliveryDead {
+		t.Errorf("Expected dead deliveries of any webhook but got %q of %q", status, webhookID)
+	}
+	if rr.Header().Get("X-Total-Count") != "1" {
+		t.Errorf("Expected the total to be returned but got %s", rr.Header().Get("X-Total-Count"))
+	}
+}
+
+func TestWebhookDeliveriesOfMissingWebhookReturns404(t *testing.T) {
+	db := &mockDB{
+		GetWebhookFunc: func(id string) (types.Webhook, error) {
+			return types.Webhook{}, &types.WebhookNotFoundError{ID: id, Message: "Webhook does not exist in the database."}
+		},
+	}
+
+	req, err := http.NewRequest("GET", "/webhook/hook-2/delivery?status=pending", nil)
+	ok(t, err)
+	rr := httptest.NewRecorder()
+	webhookRouter(&WebService{DB: db}).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected %d but got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestRetryDeliveryThatIsNotDeadReturns404(t *testing.T) {
+	db := &mockDB{
+		RetryDeliveryFunc: func(webhookID, id string) (types.Delivery, error) {
+			return types.Delivery{}, &types.DeliveryNotFoundError{ID: id, Message: "Dead delivery does not exist in the database."}
+		},
+	}
+
+	req, err := http.NewRequest("POST", "/webhook/hook-1/delivery/delivery-1/retry", nil)
+	ok(t, err)
+	rr := httptest.NewRecorder()
+	webhookRouter(&WebService{DB: db}).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected %d but got %d", http.StatusNotFound, rr.Code)
+	}
+}
@@ -0,0 +1,242 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+type workspaceKey struct{}
+
+// workspace returns the workspace the request was scoped to by InWorkspace
+func workspace(r *http.Request) string {
+	if name, ok := r.Context().Value(workspaceKey{}).(string); ok {
+		return name
+	}
+	return types.DefaultWorkspace
+}
+
+// db returns the database scoped to the workspace of the request, recording
+// changes as made by whoever made it
+func (s *WebService) db(r *http.Request) database.Database {
+	if s.DB == nil {
+		return nil
+	}
+	return s.DB.In(workspace(r)).As(actor(r))
+}
+
+// InWorkspace scopes requests to the workspace named in their path, or the
+// workspace of the principal when they don't name one, before passing them
+// on. Requests for workspaces that don't exist get 404 and principals limited
+// to a workspace get 403 for any other.
+func (s *WebService) InWorkspace(next http.HandlerFunc) http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "workspace",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["workspace"]
+		p, _ := auth.FromContext(r.Context())
+
+		if name == "" {
+			name = p.Workspace
+			if name == "" {
+				name = types.DefaultWorkspace
+			}
+		} else if p.Workspace != "" && p.Workspace != name {
+			logger.WithFields(log.Fields{
+				"status":    403,
+				"workspace": name,
+				"principal": p.Name,
+			}).Warn("Principal is limited to another workspace")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Not allowed to use this workspace"})
+			return
+		}
+
+		if s.DB != nil && name != types.DefaultWorkspace {
+			if _, err := s.DB.GetWorkspace(name); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				if _, ok := err.(*types.WorkspaceNotFoundError); ok {
+					logger.WithFields(log.Fields{
+						"status":    404,
+						"workspace": name,
+					}).Warn("Workspace not found")
+					w.WriteHeader(http.StatusNotFound)
+					json.NewEncoder(w).Encode(&ErrorResponse{Message: "Workspace not found"})
+					return
+				}
+
+				logger.WithFields(log.Fields{
+					"status":    500,
+					"workspace": name,
+					"error":     err.Error(),
+				}).Error("Failed to get workspace")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to get workspace"})
+				return
+			}
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), workspaceKey{}, name)))
+	}
+}
+
+// Workspaces lists the workspaces, principals limited to a workspace only see
+// their own
+// Path: /workspace
+// Method: GET
+func (s *WebService) Workspaces() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "workspaces",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if s.DB == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to connect to database"})
+			logger.WithFields(log.Fields{
+				"status": 500,
+			}).Error("No database set")
+			return
+		}
+
+		workspaces, err := s.DB.Workspaces()
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+			}).Error("Failed to list workspaces")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to list workspaces"})
+			return
+		}
+
+		if p, ok := auth.FromContext(r.Context()); ok && p.Workspace != "" {
+			var own []types.Workspace
+			for _, ws := range workspaces {
+				if ws.Name == p.Workspace {
+					own = append(own, ws)
+				}
+			}
+			workspaces = own
+		}
+
+		if workspaces == nil {
+			workspaces = []types.Workspace{}
+		}
+
+		logger.WithFields(log.Fields{
+			"status": 200,
+			"count":  len(workspaces),
+		}).Info("Listed workspaces")
+		json.NewEncoder(w).Encode(workspaces)
+	}
+}
+
+// CreateWorkspace adds a workspace with its own records and fields, which
+// can then be used through /w/{workspace}/...
+// Path: /workspace
+// Method: POST
+// Example: /workspace
+//		Body: {
+//					"name": "team-a",
+//					"title": "Team A"
+//				}
+// The name must be lower case letters, numbers and hyphens, up to 32 long. If
+// it's already taken 409 is returned.
+func (s *WebService) CreateWorkspace() http.HandlerFunc {
+	logger := log.WithFields(log.Fields{
+		"event": "createWorkspace",
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if p, ok := auth.FromContext(r.Context()); ok && p.Workspace != "" {
+			logger.WithFields(log.Fields{
+				"status":    403,
+				"principal": p.Name,
+			}).Warn("Principal is limited to a workspace")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Not allowed to create workspaces"})
+			return
+		}
+
+		if r.Body == nil {
+			logger.WithFields(log.Fields{
+				"status": 400,
+			}).Warn("No body provided")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "No body provided"})
+			return
+		}
+
+		var ws types.Workspace
+		if err := json.NewDecoder(r.Body).Decode(&ws); err != nil {
+			logger.WithFields(log.Fields{
+				"error":  err.Error(),
+				"status": 400,
+			}).Error("Unable to parse JSON")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to parse JSON"})
+			return
+		}
+
+		if !types.ValidWorkspaceName(ws.Name) {
+			logger.WithFields(log.Fields{
+				"status":    400,
+				"workspace": ws.Name,
+			}).Warn("Invalid workspace name")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Workspace name must be lower case letters, numbers and hyphens, up to 32 long, and not system, admin, local or config"})
+			return
+		}
+
+		if s.DB == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Unable to connect to database"})
+			logger.WithFields(log.Fields{
+				"status": 500,
+			}).Error("No database set")
+			return
+		}
+
+		created, err := s.DB.As(actor(r)).CreateWorkspace(ws)
+		if err != nil {
+			if _, ok := err.(*types.WorkspaceExistsError); ok {
+				logger.WithFields(log.Fields{
+					"status":    409,
+					"workspace": ws.Name,
+				}).Warn("Workspace already exists")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(&ErrorResponse{Message: "Workspace already exists"})
+				return
+			}
+
+			logger.WithFields(log.Fields{
+				"status": 500,
+				"error":  err.Error(),
+			}).Error("Failed to create workspace")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&ErrorResponse{Message: "Failed to create workspace"})
+			return
+		}
+
+		logger.WithFields(log.Fields{
+			"status":    201,
+			"workspace": created.Name,
+		}).Info("Created workspace")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
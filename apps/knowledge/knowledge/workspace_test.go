@@ -0,0 +1,144 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/auth"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+func workspaceDB() *mockDB {
+	return &mockDB{
+		GetFieldsFunc: func() ([]types.Field, error) {
+			return []types.Field{{ID: "1", Value: "Description"}}, nil
+		},
+		GetWorkspaceFunc: func(name string) (types.Workspace, error) {
+			if name != "team-a" && name != "team-b" {
+				return types.Workspace{}, &types.WorkspaceNotFoundError{Name: name, Message: "Workspace does not exist."}
+			}
+			return types.Workspace{Name: name}, nil
+		},
+		WorkspacesFunc: func() ([]types.Workspace, error) {
+			return []types.Workspace{{Name: "default"}, {Name: "team-a"}, {Name: "team-b"}}, nil
+		},
+	}
+}
+
+func requestIn(db *mockDB, workspace, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req = req.WithContext(auth.NewContext(req.Context(), auth.Principal{
+		Name:      "sam",
+		Roles:     []string{auth.RoleAdmin},
+		Workspace: workspace,
+	}))
+
+	rr := httptest.NewRecorder()
+	NewRouter(&WebService{DB: db}).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRequestsAreScopedToTheWorkspaceInThePath(t *testing.T) {
+	db := workspaceDB()
+
+	rr := requestIn(db, "", "GET", "/v1/w/team-a/field", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected OK (200) status to be returned got %d: %s", rr.Code, rr.Body.String())
+	}
+	if db.Workspace != "team-a" {
+		t.Errorf("Expected fields to be read from team-a but were read from %q", db.Workspace)
+	}
+
+	requestIn(db, "", "GET", "/v1/field", "")
+	if db.Workspace != types.DefaultWorkspace {
+		t.Errorf("Expected fields to be read from the default workspace but were read from %q", db.Workspace)
+	}
+}
+
+func TestRequestsAreScopedToTheWorkspaceOfThePrincipal(t *testing.T) {
+	db := workspaceDB()
+
+	requestIn(db, "team-b", "GET", "/v1/field", "")
+	if db.Workspace != "team-b" {
+		t.Errorf("Expected fields to be read from team-b but were read from %q", db.Workspace)
+	}
+
+	db.Workspace = ""
+	rr := requestIn(db, "team-b", "GET", "/v1/w/team-a/field", "")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected Forbidden (403) for another workspace got %d", rr.Code)
+	}
+	if db.Workspace != "" {
+		t.Errorf("Expected the database not to be used but it was scoped to %q", db.Workspace)
+	}
+}
+
+func TestUnknownWorkspaceIsNotFound(t *testing.T) {
+	rr := requestIn(workspaceDB(), "", "GET", "/v1/w/nope/field", "")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected Not Found (404) status to be returned got %d", rr.Code)
+	}
+}
+
+func TestWorkspacesAreLimitedForPrincipals(t *testing.T) {
+	rr := requestIn(workspaceDB(), "team-a", "GET", "/v1/workspace", "")
+
+	var workspaces []types.Workspace
+	ok(t, json.NewDecoder(rr.Body).Decode(&workspaces))
+	if len(workspaces) != 1 || workspaces[0].Name != "team-a" {
+		t.Errorf("Expected only team-a to be listed but got %+v", workspaces)
+	}
+
+	rr = requestIn(workspaceDB(), "", "GET", "/v1/workspace", "")
+	workspaces = nil
+	ok(t, json.NewDecoder(rr.Body).Decode(&workspaces))
+	if len(workspaces) != 3 {
+		t.Errorf("Expected every workspace to be listed but got %+v", workspaces)
+	}
+}
+
+func TestCreateWorkspace(t *testing.T) {
+	db := workspaceDB()
+	db.CreateWorkspaceFunc = func(w types.Workspace) (types.Workspace, error) {
+		if w.Name == "team-a" {
+			return types.Workspace{}, &types.WorkspaceExistsError{Name: w.Name, Message: "Workspace already exists."}
+		}
+		w.CreatedBy = db.Actor
+		return w, nil
+	}
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"name":"team-c","title":"Team C"}`, http.StatusCreated},
+		{`{"name":"team-a"}`, http.StatusConflict},
+		{`{"name":"Team C"}`, http.StatusBadRequest},
+		{`{"name":""}`, http.StatusBadRequest},
+		{`{"name":"system"}`, http.StatusBadRequest},
+		{`{"name":"admin"}`, http.StatusBadRequest},
+		{`{`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/workspace", bytes.NewBufferString(test.body))
+		req.Header.Set("X-Actor", "sam")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc((&WebService{DB: db}).CreateWorkspace()).ServeHTTP(rr, req)
+
+		if rr.Code != test.status {
+			t.Errorf("Expected %d for %s got %d", test.status, test.body, rr.Code)
+		}
+	}
+
+	if db.Actor != "sam" {
+		t.Errorf("Expected workspace to be created by sam but was created by %s", db.Actor)
+	}
+
+	if rr := requestIn(db, "team-a", "POST", "/v1/workspace", `{"name":"team-d"}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected Forbidden (403) for a principal limited to a workspace got %d", rr.Code)
+	}
+}
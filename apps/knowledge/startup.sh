@@ -3,4 +3,5 @@ docker rm knowledge
 docker run -d --name=knowledge \
     -p 8000:8000 \
     -e MONGODB_URI=http://localhost:27017 \
+    -e AUTH_DISABLED=true \
     knowledge:0.0.9
\ No newline at end of file
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// Attachment describes a file attached to a record, such as a photo or floor
+// plan. The file itself is kept in a blob store, the record only holds its
+// metadata.
+type Attachment struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"contentType"`
+	Checksum    string    `json:"checksum"`
+	Created     time.Time `json:"created"`
+	CreatedBy   string    `json:"createdBy"`
+}
+
+// AttachmentKey is where the file of an attachment of a record in the
+// workspace is kept in the blob store
+func AttachmentKey(workspace, recordID, attachmentID string) string {
+	return workspace + "/" + recordID + "/" + attachmentID
+}
+
+// Attachment returns the attachment of the record with the ID
+func (r Record) Attachment(id string) (Attachment, bool) {
+	for _, a := range r.Attachments {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return Attachment{}, false
+}
+
+// AttachmentNotFoundError is returned when a record has no attachment with
+// the ID
+type AttachmentNotFoundError struct {
+	ID       string
+	RecordID string
+	Message  string
+}
+
+func (anf AttachmentNotFoundError) Error() string {
+	return fmt.Sprintf("%s : %s attachment %s", anf.Message, anf.RecordID, anf.ID)
+}
@@ -0,0 +1,84 @@
+package types
+
+import "fmt"
+
+// Operations that can be made to records in bulk
+const (
+	BulkSetDetail      = "setDetail"
+	BulkAddFacility    = "addFacility"
+	BulkRemoveFacility = "removeFacility"
+	BulkDelete         = "delete"
+)
+
+// Outcomes of a bulk operation on a single record
+const (
+	BulkUpdated   = "updated"
+	BulkDeleted   = "deleted"
+	BulkUnchanged = "unchanged"
+	BulkNotFound  = "notFound"
+	BulkFailed    = "failed"
+)
+
+// BulkOperation is a change made to many records at once, picked either by
+// their IDs or by a search filter
+type BulkOperation struct {
+	IDs []string `json:"ids,omitempty"`
+
+	// Filter holds search parameters in the same form as the query string
+	// of a search, e.g. facility=TAXI&details.region=North
+	Filter string `json:"filter,omitempty"`
+
+	// Query is the parsed Filter, it is set by the service
+	Query *SearchQuery `json:"-"`
+
+	Operation string      `json:"operation"`
+	Key       string      `json:"key,omitempty"`
+	Value     interface{} `json:"value,omitempty"`
+	Facility  string      `json:"facility,omitempty"`
+}
+
+// BulkResult is the outcome of a bulk operation on a single record
+type BulkResult struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// BulkReport summarises a bulk operation along with the outcome for each
+// record
+type BulkReport struct {
+	Updated   int          `json:"updated"`
+	Deleted   int          `json:"deleted"`
+	Unchanged int          `json:"unchanged"`
+	NotFound  int          `json:"notFound"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
+
+// Add records the outcome for a record in the report
+func (br *BulkReport) Add(result BulkResult) {
+	switch result.Status {
+	case BulkUpdated:
+		br.Updated++
+	case BulkDeleted:
+		br.Deleted++
+	case BulkUnchanged:
+		br.Unchanged++
+	case BulkNotFound:
+		br.NotFound++
+	case BulkFailed:
+		br.Failed++
+	}
+	br.Results = append(br.Results, result)
+}
+
+// BulkLimitError is returned when a bulk operation would change more records
+// than are allowed at once
+type BulkLimitError struct {
+	Matched int
+	Limit   int
+}
+
+func (ble BulkLimitError) Error() string {
+	return fmt.Sprintf("Bulk operations can change at most %d records at once, %d matched", ble.Limit, ble.Matched)
+}
@@ -0,0 +1,26 @@
+package types
+
+// MaxClusterZoom is the deepest map zoom level records can be clustered at
+const MaxClusterZoom = 22
+
+// MaxClusters is the most clusters returned for an area, the largest being
+// kept
+const MaxClusters = 10000
+
+// Cluster summarises the records in one cell of a grid over the map
+type Cluster struct {
+	// Lat and Lng are the centroid of the records
+	Lat   float64 `json:"lat"`
+	Lng   float64 `json:"lng"`
+	Count int     `json:"count"`
+
+	// The bounds of the records, zooming the map to them shows every record
+	// in the cluster
+	MinLat float64 `json:"minLat"`
+	MaxLat float64 `json:"maxLat"`
+	MinLng float64 `json:"minLng"`
+	MaxLng float64 `json:"maxLng"`
+
+	// ID is the id of the record when the cluster only has one
+	ID string `json:"id,omitempty"`
+}
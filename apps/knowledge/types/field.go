@@ -1,11 +1,56 @@
 package types
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DescriptionKey is the key of the description detail every record can have
+// without a field being defined for it
+const DescriptionKey = "description"
+
+// Types of value a field can hold, fields without a type hold text
+const (
+	FieldText    = "text"
+	FieldNumber  = "number"
+	FieldDate    = "date"
+	FieldURL     = "url"
+	FieldEnum    = "enum"
+	FieldBoolean = "boolean"
+)
 
 type Field struct {
 	ID    string `json:"id"`
 	Value string `json:"value"`
 	Order int    `json:"order"`
+
+	// Type is the type of value the field holds, one of text, number, date,
+	// url, enum or boolean. Enum fields only accept one of their Options.
+	Type     string   `json:"type,omitempty"`
+	Required bool     `json:"required,omitempty"`
+	Options  []string `json:"options,omitempty"`
+
+	// DeletedAt is when the field was deleted, it is only set on fields that
+	// have been deleted
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:",omitempty"`
+}
+
+// Key returns the key a fields value is stored under in a records details,
+// this is the fields name with the first letter lower cased and any white
+// space removed, e.g. "Station Postcode" is stored as "stationPostcode"
+func (f Field) Key() string {
+	return FieldKey(f.Value)
+}
+
+// FieldKey converts a field name into the key used in a records details
+func FieldKey(name string) string {
+	if r, size := utf8.DecodeRuneInString(name); size > 0 {
+		name = string(unicode.ToLower(r)) + name[size:]
+	}
+	return strings.Join(strings.Fields(name), "")
 }
 
 type FieldNotFoundError struct {
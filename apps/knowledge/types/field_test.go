@@ -0,0 +1,109 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFieldKeyMatchesTheHub(t *testing.T) {
+	tests := map[string]string{
+		"Station Postcode": "stationPostcode",
+		"Description":      "description",
+		"number of Gates":  "numberofGates",
+		"":                 "",
+	}
+
+	for name, expected := range tests {
+		if key := FieldKey(name); key != expected {
+			t.Errorf("Expected %q to have key %q but got %q", name, expected, key)
+		}
+	}
+}
+
+func TestFieldCheckRequiresEnumOptions(t *testing.T) {
+	if err := (Field{Value: "Status", Type: FieldEnum}).Check(); err == nil {
+		t.Error("Expected an error for an enum without options")
+	}
+}
+
+func TestFieldCheckRejectsUnknownType(t *testing.T) {
+	if err := (Field{Value: "Status", Type: "colour"}).Check(); err == nil {
+		t.Error("Expected an error for an unknown type")
+	}
+}
+
+func TestFieldCheckAcceptsUntypedField(t *testing.T) {
+	if err := (Field{Value: "Question 1"}).Check(); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+}
+
+func TestValidateDetailsConvertsValues(t *testing.T) {
+	fields := []Field{
+		{Value: "Runways", Type: FieldNumber},
+		{Value: "Open", Type: FieldBoolean},
+	}
+	details := map[string]interface{}{"runways": "2", "open": "true"}
+
+	if err := ValidateDetails(fields, details); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if details["runways"] != 2.0 || details["open"] != true {
+		t.Errorf("Expected runways 2 and open true but got %v", details)
+	}
+}
+
+func TestValidateDetailsListsEachInvalidField(t *testing.T) {
+	fields := []Field{
+		{Value: "Runways", Type: FieldNumber},
+		{Value: "Opened", Type: FieldDate},
+		{Value: "Website", Type: FieldURL},
+		{Value: "Status", Type: FieldEnum, Options: []string{"Open", "Closed"}},
+		{Value: "Manager", Required: true},
+		{Value: "Notes"},
+	}
+	details := map[string]interface{}{
+		"runways": "two",
+		"opened":  "last year",
+		"website": "not a url",
+		"status":  "Demolished",
+		"notes":   "Anything goes",
+		"other":   "Not a field",
+	}
+
+	err := ValidateDetails(fields, details)
+	invalid, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a *ValidationError but got %v", err)
+	}
+
+	var invalidFields []string
+	for _, f := range invalid.Fields {
+		invalidFields = append(invalidFields, f.Field)
+	}
+
+	expected := "manager,opened,runways,status,website"
+	if strings.Join(invalidFields, ",") != expected {
+		t.Errorf("Expected invalid fields %s but got %v", expected, invalidFields)
+	}
+}
+
+func TestValidateDetailsAcceptsValidValues(t *testing.T) {
+	fields := []Field{
+		{Value: "Opened", Type: FieldDate},
+		{Value: "Website", Type: FieldURL},
+		{Value: "Status", Type: FieldEnum, Options: []string{"Open", "Closed"}},
+		{Value: "Manager", Required: true},
+	}
+	details := map[string]interface{}{
+		"opened":  "2018-06-01",
+		"website": "https://example.com",
+		"status":  "Open",
+		"manager": "Sam",
+	}
+
+	if err := ValidateDetails(fields, details); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+}
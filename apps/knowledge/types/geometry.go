@@ -0,0 +1,74 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Geometry is a GeoJSON Polygon or MultiPolygon describing an area to search
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// MultiPolygon checks the geometry is a valid Polygon or MultiPolygon and
+// returns its coordinates as a MultiPolygon
+func (g Geometry) MultiPolygon() ([][][][]float64, error) {
+	var polygons [][][][]float64
+
+	switch g.Type {
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &polygon); err != nil {
+			return nil, errors.New("Polygon coordinates must be an array of linear rings")
+		}
+		polygons = append(polygons, polygon)
+	case "MultiPolygon":
+		if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return nil, errors.New("MultiPolygon coordinates must be an array of polygons")
+		}
+	default:
+		return nil, fmt.Errorf("Geometry type must be Polygon or MultiPolygon, got %q", g.Type)
+	}
+
+	if len(polygons) == 0 {
+		return nil, errors.New("Geometry must contain at least one polygon")
+	}
+
+	for _, polygon := range polygons {
+		if err := validPolygon(polygon); err != nil {
+			return nil, err
+		}
+	}
+
+	return polygons, nil
+}
+
+func validPolygon(polygon [][][]float64) error {
+	if len(polygon) == 0 {
+		return errors.New("Polygon must have an exterior ring")
+	}
+
+	for _, ring := range polygon {
+		if len(ring) < 4 {
+			return errors.New("Polygon rings must have at least four positions")
+		}
+
+		for _, position := range ring {
+			if len(position) < 2 {
+				return errors.New("Positions must have a longitude and latitude")
+			}
+			if position[0] < -180 || position[0] > 180 || position[1] < -90 || position[1] > 90 {
+				return fmt.Errorf("Position %v is outside of the valid longitude and latitude range", position)
+			}
+		}
+
+		first, last := ring[0], ring[len(ring)-1]
+		if first[0] != last[0] || first[1] != last[1] {
+			return errors.New("Polygon rings must be closed, the first and last positions must be the same")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func geometry(t *testing.T, body string) Geometry {
+	var g Geometry
+	if err := json.Unmarshal([]byte(body), &g); err != nil {
+		t.Fatalf("Unable to parse geometry: %s", err.Error())
+	}
+	return g
+}
+
+func TestPolygonIsReturnedAsMultiPolygon(t *testing.T) {
+	g := geometry(t, `{"type":"Polygon","coordinates":[[[-1.97,53.63],[-1.11,53.63],[-1.11,53.84],[-1.97,53.63]]]}`)
+
+	polygons, err := g.MultiPolygon()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if len(polygons) != 1 || len(polygons[0][0]) != 4 {
+		t.Errorf("Expected a single polygon with four positions, got: %v", polygons)
+	}
+}
+
+func TestMultiPolygonIsValid(t *testing.T) {
+	g := geometry(t, `{"type":"MultiPolygon","coordinates":[
+		[[[-1.97,53.63],[-1.11,53.63],[-1.11,53.84],[-1.97,53.63]]],
+		[[[0,0],[1,0],[1,1],[0,0]]]
+	]}`)
+
+	polygons, err := g.MultiPolygon()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if len(polygons) != 2 {
+		t.Errorf("Expected two polygons, got: %v", polygons)
+	}
+}
+
+func TestPointIsNotAValidSearchGeometry(t *testing.T) {
+	g := geometry(t, `{"type":"Point","coordinates":[-1.97,53.63]}`)
+
+	if _, err := g.MultiPolygon(); err == nil {
+		t.Error("Expected an error for a Point geometry")
+	}
+}
+
+func TestUnclosedRingIsInvalid(t *testing.T) {
+	g := geometry(t, `{"type":"Polygon","coordinates":[[[-1.97,53.63],[-1.11,53.63],[-1.11,53.84],[-1.97,53.84]]]}`)
+
+	if _, err := g.MultiPolygon(); err == nil {
+		t.Error("Expected an error for an unclosed ring")
+	}
+}
+
+func TestPositionOutOfRangeIsInvalid(t *testing.T) {
+	g := geometry(t, `{"type":"Polygon","coordinates":[[[-1.97,93.63],[-1.11,53.63],[-1.11,53.84],[-1.97,93.63]]]}`)
+
+	if _, err := g.MultiPolygon(); err == nil {
+		t.Error("Expected an error for a latitude above 90")
+	}
+}
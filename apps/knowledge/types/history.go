@@ -0,0 +1,88 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Actions recorded in a records history
+const (
+	ActionCreated  = "created"
+	ActionUpdated  = "updated"
+	ActionDeleted  = "deleted"
+	ActionRestored = "restored"
+)
+
+// Revision is a snapshot of a record taken every time it is written, along
+// with who wrote it and which keys changed
+type Revision struct {
+	RecordID  string    `json:"recordId"`
+	Rev       int       `json:"rev"`
+	Action    string    `json:"action"`
+	Timestamp time.Time `json:"timestamp"`
+	Actor     string    `json:"actor"`
+	Changed   []string  `json:"changed"`
+	Record    *Record   `json:"record,omitempty"`
+}
+
+type RevisionNotFoundError struct {
+	ID      string
+	Rev     int
+	Message string
+}
+
+func (rnf RevisionNotFoundError) Error() string {
+	return fmt.Sprintf("%s : %s revision %d", rnf.Message, rnf.ID, rnf.Rev)
+}
+
+// ChangedKeys lists the keys that differ between two versions of a record,
+// details are listed individually as details.{key}
+func ChangedKeys(before, after Record) []string {
+	var changed []string
+
+	compare := func(key string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			changed = append(changed, key)
+		}
+	}
+
+	compare("title", before.Title, after.Title)
+	compare("shortName", before.ShortName, after.ShortName)
+	compare("facilities", emptyToNil(before.Facilities), emptyToNil(after.Facilities))
+	compare("location.lat", before.Location.Lat, after.Location.Lat)
+	compare("location.lng", before.Location.Lng, after.Location.Lng)
+	compare("location.country", before.Location.Country, after.Location.Country)
+	compare("attachments", attachmentIDs(before.Attachments), attachmentIDs(after.Attachments))
+
+	var details []string
+	for key, value := range after.Details {
+		if !reflect.DeepEqual(before.Details[key], value) {
+			details = append(details, "details."+key)
+		}
+	}
+	for key := range before.Details {
+		if _, ok := after.Details[key]; !ok {
+			details = append(details, "details."+key)
+		}
+	}
+	sort.Strings(details)
+
+	return append(changed, details...)
+}
+
+func attachmentIDs(attachments []Attachment) []string {
+	var ids []string
+	for _, a := range attachments {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+func emptyToNil(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
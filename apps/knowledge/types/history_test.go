@@ -0,0 +1,42 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangedKeysOfIdenticalRecordsIsEmpty(t *testing.T) {
+	r := Record{Title: "Leeds", Details: map[string]interface{}{"description": "Airport"}}
+
+	if changed := ChangedKeys(r, r); len(changed) != 0 {
+		t.Errorf("Expected no changes but got %v", changed)
+	}
+}
+
+func TestChangedKeysListsChangedFieldsAndDetails(t *testing.T) {
+	before := Record{
+		Title:      "Leeds",
+		Facilities: []string{"TAXI"},
+		Details:    map[string]interface{}{"description": "Airport", "runways": 1.0},
+	}
+	after := Record{
+		Title:      "Leeds Bradford",
+		Facilities: []string{"TAXI", "TRN"},
+		Details:    map[string]interface{}{"description": "Airport", "website": "https://example.com"},
+	}
+	after.Location.Lat = 53.86
+
+	expected := []string{"title", "facilities", "location.lat", "details.runways", "details.website"}
+	if changed := ChangedKeys(before, after); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected %v but got %v", expected, changed)
+	}
+}
+
+func TestChangedKeysFromEmptyRecordListsEverythingSet(t *testing.T) {
+	after := Record{Title: "Leeds", Details: map[string]interface{}{"description": "Airport"}}
+
+	expected := []string{"title", "details.description"}
+	if changed := ChangedKeys(Record{}, after); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected %v but got %v", expected, changed)
+	}
+}
@@ -0,0 +1,42 @@
+package types
+
+// Outcomes of importing a row
+const (
+	ImportCreated = "created"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// ImportRow reports what happened to a single row of an import. Rows are
+// numbered from 1, not counting any header.
+type ImportRow struct {
+	Row     int          `json:"row"`
+	Status  string       `json:"status"`
+	ID      string       `json:"id,omitempty"`
+	Title   string       `json:"title,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// ImportReport summarises an import along with the outcome of every row. On
+// a dry run rows are reported as created without anything being written.
+type ImportReport struct {
+	DryRun  bool        `json:"dryRun"`
+	Created int         `json:"created"`
+	Skipped int         `json:"skipped"`
+	Failed  int         `json:"failed"`
+	Rows    []ImportRow `json:"rows"`
+}
+
+// Add records the outcome of a row in the report
+func (ir *ImportReport) Add(row ImportRow) {
+	switch row.Status {
+	case ImportCreated:
+		ir.Created++
+	case ImportSkipped:
+		ir.Skipped++
+	case ImportFailed:
+		ir.Failed++
+	}
+	ir.Rows = append(ir.Rows, row)
+}
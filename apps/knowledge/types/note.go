@@ -0,0 +1,55 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MaxNoteLength is the most characters the text of a note can have
+const MaxNoteLength = 10000
+
+// Note is a comment or report left on a record, optionally linking to more
+// information elsewhere
+type Note struct {
+	ID       string     `json:"id" bson:"_id"`
+	RecordID string     `json:"recordId"`
+	Text     string     `json:"text"`
+	URL      string     `json:"url,omitempty" bson:",omitempty"`
+	Author   string     `json:"author"`
+	Created  time.Time  `json:"created"`
+	Updated  *time.Time `json:"updated,omitempty" bson:",omitempty"`
+}
+
+// Validate checks the note has text and that its URL, if it has one, is an
+// absolute http or https URL
+func (n Note) Validate() error {
+	if strings.TrimSpace(n.Text) == "" {
+		return errors.New("Note must have some text")
+	}
+	if len([]rune(n.Text)) > MaxNoteLength {
+		return fmt.Errorf("Note must be at most %d characters", MaxNoteLength)
+	}
+
+	if n.URL != "" {
+		u, err := url.Parse(n.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("Note URL must be an http or https URL")
+		}
+	}
+
+	return nil
+}
+
+// NoteNotFoundError is returned when a record has no note with the ID
+type NoteNotFoundError struct {
+	ID       string
+	RecordID string
+	Message  string
+}
+
+func (nnf NoteNotFoundError) Error() string {
+	return fmt.Sprintf("%s : %s note %s", nnf.Message, nnf.RecordID, nnf.ID)
+}
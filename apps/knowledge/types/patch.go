@@ -0,0 +1,260 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Patch is a set of changes to part of a record, built from either a JSON
+// Merge Patch (RFC 7396) or a JSON Patch (RFC 6902)
+type Patch struct {
+	// Version, when set, is the version of the record the patch was made
+	// against, the patch is rejected if the record has since changed
+	Version *int
+
+	Changes []Change
+}
+
+// Change sets or removes a single field of a record. Path is the dotted
+// path of the field in the records JSON e.g. title or details.colour
+type Change struct {
+	Path   string
+	Value  interface{}
+	Remove bool
+
+	// MustExist is set when the field has to be in the record already, as
+	// for the replace and remove operations of a JSON Patch
+	MustExist bool
+}
+
+// PatchOperation is a single operation of a JSON Patch
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// PatchError is returned when a patch can't be applied to a record
+type PatchError struct {
+	Message string
+}
+
+func (pe PatchError) Error() string {
+	return pe.Message
+}
+
+// patchable lists the fields of a record that can be patched and whether
+// they are objects whose own fields can be patched
+var patchable = map[string]bool{
+	"title":      false,
+	"shortName":  false,
+	"facilities": false,
+	"location":   true,
+	"details":    true,
+}
+
+// requiredPaths can't be removed or set to null by a patch, a record always
+// has a location
+var requiredPaths = map[string]bool{
+	"location":     true,
+	"location.lat": true,
+	"location.lng": true,
+}
+
+var locationFields = map[string]bool{
+	"lat":     true,
+	"lng":     true,
+	"country": true,
+}
+
+// Paths returns the paths changed by the patch, without duplicates
+func (p Patch) Paths() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, change := range p.Changes {
+		if !seen[change.Path] {
+			seen[change.Path] = true
+			paths = append(paths, change.Path)
+		}
+	}
+	return paths
+}
+
+// MergePatch builds a patch from a JSON Merge Patch document, null values
+// remove the field and objects are merged into location and details
+func MergePatch(doc map[string]interface{}) (Patch, error) {
+	var patch Patch
+
+	for key, value := range doc {
+		if key == "id" || key == "version" {
+			// Commonly sent back as part of a whole record, they can't change
+			continue
+		}
+
+		nested, known := patchable[key]
+		if !known {
+			return patch, &PatchError{Message: fmt.Sprintf("%s cannot be patched", key)}
+		}
+
+		object, isObject := value.(map[string]interface{})
+		if !nested || !isObject {
+			patch.Changes = append(patch.Changes, Change{Path: key, Value: value, Remove: value == nil})
+			continue
+		}
+
+		for child, childValue := range object {
+			path := key + "." + child
+			if err := checkPath(path); err != nil {
+				return patch, err
+			}
+			patch.Changes = append(patch.Changes, Change{Path: path, Value: childValue, Remove: childValue == nil})
+		}
+	}
+
+	return patch, nil
+}
+
+// JSONPatch builds a patch from the operations of a JSON Patch, only add,
+// replace and remove are supported
+func JSONPatch(ops []PatchOperation) (Patch, error) {
+	var patch Patch
+
+	for _, op := range ops {
+		path, err := pointerToPath(op.Path)
+		if err != nil {
+			return patch, err
+		}
+
+		switch op.Op {
+		case "add":
+			patch.Changes = append(patch.Changes, Change{Path: path, Value: op.Value})
+		case "replace":
+			patch.Changes = append(patch.Changes, Change{Path: path, Value: op.Value, MustExist: true})
+		case "remove":
+			patch.Changes = append(patch.Changes, Change{Path: path, Remove: true, MustExist: true})
+		default:
+			return patch, &PatchError{Message: fmt.Sprintf("Patch operation %q is not supported, expected add, replace or remove", op.Op)}
+		}
+	}
+
+	return patch, nil
+}
+
+// pointerToPath converts a JSON Pointer e.g. /details/colour to a dotted path
+func pointerToPath(pointer string) (string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return "", &PatchError{Message: fmt.Sprintf("Patch path %q must start with /", pointer)}
+	}
+
+	segments := strings.Split(pointer[1:], "/")
+	for i, segment := range segments {
+		segment = strings.Replace(segment, "~1", "/", -1)
+		segments[i] = strings.Replace(segment, "~0", "~", -1)
+	}
+
+	path := strings.Join(segments, ".")
+	return path, checkPath(path)
+}
+
+// checkPath makes sure a path refers to a field of a record that can be
+// patched
+func checkPath(path string) error {
+	segments := strings.SplitN(path, ".", 2)
+
+	nested, known := patchable[segments[0]]
+	if !known {
+		return &PatchError{Message: fmt.Sprintf("%s cannot be patched", segments[0])}
+	}
+
+	if len(segments) == 1 {
+		return nil
+	}
+
+	child := segments[1]
+	if !nested || child == "" || strings.Contains(child, ".") || strings.HasPrefix(child, "$") {
+		return &PatchError{Message: fmt.Sprintf("%s cannot be patched, only whole fields can be changed", path)}
+	}
+
+	if segments[0] == "location" && !locationFields[child] {
+		return &PatchError{Message: fmt.Sprintf("%s cannot be patched, expected lat, lng or country", path)}
+	}
+
+	return nil
+}
+
+// Apply returns a copy of the record with the changes of the patch made to
+// it. ValidationError is returned when a change removes the location, or
+// must replace or remove a field the record doesn't have.
+func (p Patch) Apply(r Record) (Record, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return r, err
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return r, err
+	}
+
+	for _, change := range p.Changes {
+		segments := strings.SplitN(change.Path, ".", 2)
+		if requiredPaths[change.Path] && (change.Remove || change.Value == nil) {
+			return r, &ValidationError{
+				Message: "Patch removes the location of the record",
+				Fields:  []FieldError{{Field: change.Path, Message: "is required"}},
+			}
+		}
+		if change.MustExist && !hasPath(doc, segments) {
+			return r, &ValidationError{
+				Message: "Patch changes a field the record does not have",
+				Fields:  []FieldError{{Field: change.Path, Message: "does not exist"}},
+			}
+		}
+		if len(segments) == 1 {
+			if change.Remove {
+				delete(doc, change.Path)
+			} else {
+				doc[change.Path] = change.Value
+			}
+			continue
+		}
+
+		parent, _ := doc[segments[0]].(map[string]interface{})
+		if parent == nil {
+			parent = make(map[string]interface{})
+			doc[segments[0]] = parent
+		}
+
+		if change.Remove {
+			delete(parent, segments[1])
+		} else {
+			parent[segments[1]] = change.Value
+		}
+	}
+
+	body, err = json.Marshal(doc)
+	if err != nil {
+		return r, err
+	}
+
+	var patched Record
+	if err := json.Unmarshal(body, &patched); err != nil {
+		return r, &PatchError{Message: fmt.Sprintf("Patch does not produce a valid record: %s", err.Error())}
+	}
+	patched.DeletedAt = r.DeletedAt
+
+	return patched, nil
+}
+
+// hasPath reports whether the record document has a value at the path
+func hasPath(doc map[string]interface{}, segments []string) bool {
+	value, ok := doc[segments[0]]
+	if !ok || value == nil || len(segments) == 1 {
+		return ok && value != nil
+	}
+
+	parent, _ := value.(map[string]interface{})
+	_, ok = parent[segments[1]]
+	return ok
+}
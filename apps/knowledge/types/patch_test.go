@@ -0,0 +1,188 @@
+package types
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMergePatchSetsAndRemovesFields(t *testing.T) {
+	patch, err := MergePatch(map[string]interface{}{
+		"id":    "12345",
+		"title": "Leeds Bradford",
+		"details": map[string]interface{}{
+			"colour":      "Red",
+			"description": nil,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected merge patch to be valid but got %s", err.Error())
+	}
+
+	paths := patch.Paths()
+	sort.Strings(paths)
+	expected := []string{"details.colour", "details.description", "title"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected %v but got %v", expected, paths)
+	}
+
+	for _, change := range patch.Changes {
+		if change.Remove != (change.Path == "details.description") {
+			t.Errorf("Expected only details.description to be removed but %s has remove %v", change.Path, change.Remove)
+		}
+	}
+}
+
+func TestMergePatchRejectsReadOnlyFields(t *testing.T) {
+	for _, doc := range []map[string]interface{}{
+		{"deletedAt": "2018-06-01T00:00:00Z"},
+		{"location": map[string]interface{}{"coordinates": []interface{}{1.0, 2.0}}},
+		{"unknown": "value"},
+	} {
+		if _, err := MergePatch(doc); err == nil {
+			t.Errorf("Expected %v to be rejected", doc)
+		}
+	}
+}
+
+func TestJSONPatchConvertsPointers(t *testing.T) {
+	patch, err := JSONPatch([]PatchOperation{
+		{Op: "replace", Path: "/details/opening~1closing", Value: "9-5"},
+		{Op: "remove", Path: "/shortName"},
+	})
+	if err != nil {
+		t.Fatalf("Expected JSON patch to be valid but got %s", err.Error())
+	}
+
+	expected := []Change{
+		{Path: "details.opening/closing", Value: "9-5", MustExist: true},
+		{Path: "shortName", Remove: true, MustExist: true},
+	}
+	if !reflect.DeepEqual(patch.Changes, expected) {
+		t.Errorf("Expected %v but got %v", expected, patch.Changes)
+	}
+}
+
+func TestJSONPatchRejectsUnsupportedOperationsAndPaths(t *testing.T) {
+	for _, op := range []PatchOperation{
+		{Op: "move", Path: "/title"},
+		{Op: "add", Path: "title", Value: "Leeds"},
+		{Op: "add", Path: "/facilities/0", Value: "TAXI"},
+		{Op: "replace", Path: "/details/a/b", Value: "c"},
+		{Op: "replace", Path: "/version", Value: 4},
+	} {
+		if _, err := JSONPatch([]PatchOperation{op}); err == nil {
+			t.Errorf("Expected %+v to be rejected", op)
+		}
+	}
+}
+
+func TestApplyChangesOnlyPatchedFields(t *testing.T) {
+	r := Record{
+		ID:         "12345",
+		Title:      "Leeds",
+		Facilities: []string{"TAXI"},
+		Details:    map[string]interface{}{"description": "Airport", "colour": "Blue"},
+		Version:    2,
+	}
+	r.Location.Lat = 53.86
+
+	patch := Patch{Changes: []Change{
+		{Path: "details.colour", Value: "Red"},
+		{Path: "details.description", Remove: true},
+		{Path: "location.country", Value: "UK"},
+	}}
+
+	patched, err := patch.Apply(r)
+	if err != nil {
+		t.Fatalf("Expected patch to apply but got %s", err.Error())
+	}
+
+	if patched.Title != "Leeds" || patched.Version != 2 || patched.Location.Lat != 53.86 || !reflect.DeepEqual(patched.Facilities, r.Facilities) {
+		t.Errorf("Expected unpatched fields to be kept but got %+v", patched)
+	}
+
+	if patched.Location.Country != "UK" {
+		t.Errorf("Expected country to be UK but got %s", patched.Location.Country)
+	}
+
+	expected := map[string]interface{}{"colour": "Red"}
+	if !reflect.DeepEqual(patched.Details, expected) {
+		t.Errorf("Expected details %v but got %v", expected, patched.Details)
+	}
+
+	if r.Details["colour"] != "Blue" {
+		t.Error("Expected the original record to be left unchanged")
+	}
+}
+
+func TestApplyRejectsValuesOfTheWrongType(t *testing.T) {
+	patch := Patch{Changes: []Change{{Path: "location.lat", Value: "north"}}}
+
+	if _, err := patch.Apply(Record{}); err == nil {
+		t.Error("Expected a text latitude to be rejected")
+	} else if _, ok := err.(*PatchError); !ok {
+		t.Errorf("Expected a PatchError but got %T", err)
+	}
+}
+
+func TestApplyRejectsReplacingOrRemovingMissingFields(t *testing.T) {
+	r := Record{Details: map[string]interface{}{"colour": "Blue"}}
+
+	for _, op := range []PatchOperation{
+		{Op: "replace", Path: "/details/runways", Value: 2},
+		{Op: "remove", Path: "/details/runways"},
+	} {
+		patch, err := JSONPatch([]PatchOperation{op})
+		if err != nil {
+			t.Fatalf("Expected %+v to be valid but got %s", op, err.Error())
+		}
+
+		if _, err := patch.Apply(r); err == nil {
+			t.Errorf("Expected %+v of a missing detail to be rejected", op)
+		} else if _, ok := err.(*ValidationError); !ok {
+			t.Errorf("Expected a ValidationError but got %T", err)
+		}
+	}
+
+	patch, _ := JSONPatch([]PatchOperation{
+		{Op: "replace", Path: "/details/colour", Value: "Red"},
+		{Op: "add", Path: "/details/runways", Value: 2},
+	})
+	if _, err := patch.Apply(r); err != nil {
+		t.Errorf("Expected replacing an existing detail and adding a new one to apply but got %s", err.Error())
+	}
+
+	patch, _ = JSONPatch([]PatchOperation{{Op: "remove", Path: "/details/colour"}})
+	if _, err := patch.Apply(Record{}); err == nil {
+		t.Error("Expected removing a detail from a record without details to be rejected")
+	}
+}
+
+func TestApplyRejectsRemovingTheLocation(t *testing.T) {
+	r := Record{Title: "Leeds"}
+	r.Location.Lat = 53.86
+	r.Location.Lng = -1.66
+
+	for _, doc := range []map[string]interface{}{
+		{"location": nil},
+		{"location": map[string]interface{}{"lat": nil}},
+		{"location": map[string]interface{}{"lng": nil}},
+	} {
+		patch, err := MergePatch(doc)
+		if err != nil {
+			t.Fatalf("Expected %v to be valid but got %s", doc, err.Error())
+		}
+
+		if _, err := patch.Apply(r); err == nil {
+			t.Errorf("Expected %v to be rejected", doc)
+		} else if _, ok := err.(*ValidationError); !ok {
+			t.Errorf("Expected a ValidationError for %v but got %T", doc, err)
+		}
+	}
+
+	patch, _ := MergePatch(map[string]interface{}{"location": map[string]interface{}{"country": nil}})
+	if _, err := patch.Apply(r); err != nil {
+		t.Errorf("Expected the country to be removable but got %s", err.Error())
+	}
+}
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"fmt"
+	"time"
+)
+
 type Record struct {
 	ID         string                 `json:"id"`
 	Title      string                 `json:"title"`
@@ -7,12 +12,63 @@ type Record struct {
 	ShortName  string                 `json:"shortName"`
 	Facilities []string               `json:"facilities"`
 	Details    map[string]interface{} `json:"details"`
+
+	// Version is incremented every time the record is written, updates must
+	// be made against the current version
+	Version int `json:"version"`
+
+	// Attachments lists the files attached to the record, they are added and
+	// removed through their own endpoints and kept as they are by updates
+	Attachments []Attachment `json:"attachments,omitempty" bson:",omitempty"`
+
+	// DeletedAt is when the record was deleted, it is only set on records
+	// that have been deleted
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:",omitempty"`
+
+	// Distance is the distance in metres from the centre of a radius search,
+	// it is only set on the results of those searches
+	Distance *float64 `json:"distance,omitempty" bson:"-"`
+
+	// DetailValues holds the values of Details so they can be text indexed,
+	// it is maintained by the database and never sent to clients
+	DetailValues []string `json:"-" bson:",omitempty"`
 }
 
 type location struct {
 	Type        string    `json:"type"`
-	Coordinates []float64 `json:"coordinates`
+	Coordinates []float64 `json:"Coordinates"`
 	Lat         float64   `json:"lat"`
 	Lng         float64   `json:"lng"`
 	Country     string    `json:"country"`
+
+	// CountryCode and Region are found from the lat and lng when the record
+	// is written, CountryCode being the ISO 3166-1 alpha-2 code of the
+	// country. They're left empty when the location isn't in a known country.
+	CountryCode string `json:"countryCode,omitempty" bson:",omitempty"`
+	Region      string `json:"region,omitempty" bson:",omitempty"`
+
+	// Address is looked up to find the lat and lng of a new record given
+	// without them
+	Address string `json:"address,omitempty" bson:",omitempty"`
+}
+
+type RecordNotFoundError struct {
+	ID      string
+	Message string
+}
+
+func (rnf RecordNotFoundError) Error() string {
+	return fmt.Sprintf("%s : %s", rnf.Message, rnf.ID)
+}
+
+// ConflictError is returned when a record is updated against a version that
+// is no longer current, Current holds the record as it is in the database
+type ConflictError struct {
+	ID      string
+	Message string
+	Current Record
+}
+
+func (ce ConflictError) Error() string {
+	return fmt.Sprintf("%s : %s", ce.Message, ce.ID)
 }
@@ -7,11 +7,47 @@ type Service interface {
 	HealthCheck() http.HandlerFunc
 	NewRecord() http.HandlerFunc
 	Search() http.HandlerFunc
+	SearchWithin() http.HandlerFunc
+	Import() http.HandlerFunc
+	Export() http.HandlerFunc
+	Bulk() http.HandlerFunc
+	Get() http.HandlerFunc
 	Update() http.HandlerFunc
+	Patch() http.HandlerFunc
 	Delete() http.HandlerFunc
+	History() http.HandlerFunc
+	Revision() http.HandlerFunc
+	DeletedRecords() http.HandlerFunc
+	Restore() http.HandlerFunc
+	Attachments() http.HandlerFunc
+	AddAttachment() http.HandlerFunc
+	GetAttachment() http.HandlerFunc
+	DeleteAttachment() http.HandlerFunc
+	Notes() http.HandlerFunc
+	AddNote() http.HandlerFunc
+	UpdateNote() http.HandlerFunc
+	DeleteNote() http.HandlerFunc
 	GetFields() http.HandlerFunc
 	UpdateFields() http.HandlerFunc
 	DeleteField() http.HandlerFunc
+	DeletedFields() http.HandlerFunc
+	RestoreField() http.HandlerFunc
+	Clusters() http.HandlerFunc
+	Events() http.HandlerFunc
+	Geocode() http.HandlerFunc
+	Webhooks() http.HandlerFunc
+	CreateWebhook() http.HandlerFunc
+	DeadDeliveries() http.HandlerFunc
+	GetWebhook() http.HandlerFunc
+	UpdateWebhook() http.HandlerFunc
+	DeleteWebhook() http.HandlerFunc
+	WebhookDeliveries() http.HandlerFunc
+	RetryDelivery() http.HandlerFunc
+	Workspaces() http.HandlerFunc
+	CreateWorkspace() http.HandlerFunc
+
+	// InWorkspace scopes the requests passed to the handler to a workspace
+	InWorkspace(next http.HandlerFunc) http.HandlerFunc
 }
 
 // SearchQuery marshalls the query params into a search term
@@ -21,4 +57,52 @@ type SearchQuery struct {
 	MaxLat float64 `qstring:"maxLat"`
 	MinLng float64 `qstring:"minLng"`
 	MaxLng float64 `qstring:"maxLng"`
+
+	// Lat and Lng give the centre of a radius search, returning records
+	// within Radius metres ordered by distance
+	Lat    float64 `qstring:"lat"`
+	Lng    float64 `qstring:"lng"`
+	Radius float64 `qstring:"radius"`
+
+	// Polygons restricts the search to records within the polygons, it is
+	// set from the GeoJSON body of a search rather than the query params
+	Polygons [][][][]float64 `qstring:"-"`
+
+	// Facilities restricts the results to records with the facilities, when
+	// FacilityMatch is "all" records must have every facility otherwise any
+	// one of them is enough
+	Facilities    []string `qstring:"facility"`
+	FacilityMatch string   `qstring:"facilityMatch"`
+
+	// CountryCodes restricts the results to records in any of the countries,
+	// given by their ISO 3166-1 alpha-2 codes
+	CountryCodes []string `qstring:"countryCode"`
+
+	// Details filters the results on the values of their details, it is set
+	// from the details.{key} query params
+	Details []DetailFilter `qstring:"-"`
+
+	// Page is the 1 based page of results to return, each page holding
+	// PageSize records
+	Page     int `qstring:"page"`
+	PageSize int `qstring:"pageSize"`
+}
+
+// Operators that can be used to filter on a records details
+const (
+	OperatorEq       = "eq"
+	OperatorContains = "contains"
+	OperatorExists   = "exists"
+	OperatorGt       = "gt"
+	OperatorLt       = "lt"
+)
+
+// DetailFilter filters search results on the value of one of their details.
+// It is passed as a query param in the form details.{key}[{operator}]={value}
+// with the operator defaulting to eq, e.g. details.description=Airport or
+// details.runways[gt]=2
+type DetailFilter struct {
+	Key      string
+	Operator string
+	Value    string
 }
@@ -0,0 +1,152 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError is returned when a records details don't match the field
+// definitions, listing each offending field
+type ValidationError struct {
+	Message string
+	Fields  []FieldError
+}
+
+// FieldError describes why the value of a detail field is invalid
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (ve ValidationError) Error() string {
+	var problems []string
+	for _, f := range ve.Fields {
+		problems = append(problems, fmt.Sprintf("%s %s", f.Field, f.Message))
+	}
+	return fmt.Sprintf("%s : %s", ve.Message, strings.Join(problems, ", "))
+}
+
+// Check validates a field definition, making sure it has a name and a known
+// type, and that enum fields have options
+func (f Field) Check() error {
+	if strings.TrimSpace(f.Value) == "" {
+		return errors.New("Field must have a name")
+	}
+
+	switch f.Type {
+	case "", FieldText, FieldNumber, FieldDate, FieldURL, FieldBoolean:
+	case FieldEnum:
+		if len(f.Options) == 0 {
+			return fmt.Errorf("Enum field %s must have options", f.Value)
+		}
+	default:
+		return fmt.Errorf("Field %s has unknown type %q, expected text, number, date, url, enum or boolean", f.Value, f.Type)
+	}
+
+	return nil
+}
+
+// Validate checks a value against the fields type, returning the value to
+// store. Numbers and booleans sent as strings are converted, as the hub sends
+// every value as a string.
+func (f Field) Validate(value interface{}) (interface{}, error) {
+	switch f.Type {
+	case FieldNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case string:
+			if number, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return number, nil
+			}
+		}
+		return nil, errors.New("must be a number")
+	case FieldBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+		return nil, errors.New("must be true or false")
+	}
+
+	v, ok := value.(string)
+	if !ok {
+		return nil, errors.New("must be text")
+	}
+
+	switch f.Type {
+	case FieldDate:
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				return nil, errors.New("must be a date in the format YYYY-MM-DD")
+			}
+		}
+	case FieldURL:
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.New("must be an http or https URL")
+		}
+	case FieldEnum:
+		for _, option := range f.Options {
+			if v == option {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of %s", strings.Join(f.Options, ", "))
+	}
+
+	return v, nil
+}
+
+func empty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	s, ok := value.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// ValidateDetails checks the details of a record against the field
+// definitions, converting values to their fields type. Details that don't
+// belong to a field are left alone. A *ValidationError listing every invalid
+// field is returned if any are invalid.
+func ValidateDetails(fields []Field, details map[string]interface{}) error {
+	var problems []FieldError
+
+	for _, field := range fields {
+		key := field.Key()
+		value, present := details[key]
+
+		if !present || empty(value) {
+			if field.Required {
+				problems = append(problems, FieldError{Field: key, Message: "is required"})
+			}
+			continue
+		}
+
+		converted, err := field.Validate(value)
+		if err != nil {
+			problems = append(problems, FieldError{Field: key, Message: err.Error()})
+			continue
+		}
+		details[key] = converted
+	}
+
+	if len(problems) > 0 {
+		sort.Slice(problems, func(i, j int) bool {
+			return problems[i].Field < problems[j].Field
+		})
+		return &ValidationError{Message: "Record details do not match the fields", Fields: problems}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Webhook subscribes a URL to events about changes to records and fields
+type Webhook struct {
+	ID  string `json:"id" bson:"_id"`
+	URL string `json:"url"`
+
+	// Events lists the types of event sent to the URL, all of them when it's
+	// empty
+	Events []string `json:"events"`
+
+	// Secret signs the payloads sent to the URL, it is only returned when the
+	// webhook is created
+	Secret string `json:"secret,omitempty"`
+
+	Created   time.Time  `json:"created"`
+	CreatedBy string     `json:"createdBy"`
+	Updated   *time.Time `json:"updated,omitempty" bson:",omitempty"`
+}
+
+// MinSecretLength is the fewest characters a secret chosen for a webhook can
+// have
+const MinSecretLength = 16
+
+// Validate checks the URL of the webhook is an absolute http or https URL,
+// and that its secret, if it has one, isn't too short to be guessed
+func (h Webhook) Validate() error {
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("Webhook URL must be an http or https URL")
+	}
+	if h.Secret != "" && len(h.Secret) < MinSecretLength {
+		return fmt.Errorf("Webhook secret must be at least %d characters", MinSecretLength)
+	}
+	return nil
+}
+
+// Wants reports whether the webhook is sent events of the type
+func (h Webhook) Wants(eventType string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// States of a delivery
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Delivery is an event being sent to a webhook, along with every attempt
+// made to send it
+type Delivery struct {
+	ID        string `json:"id" bson:"_id"`
+	WebhookID string `json:"webhookId"`
+	Event     string `json:"event"`
+
+	// Payload is the JSON body sent, it's kept so retries send the same body
+	Payload string `json:"payload"`
+
+	Status   string            `json:"status"`
+	Attempts []DeliveryAttempt `json:"attempts"`
+
+	// Tries is how many of the attempts have been made since the delivery
+	// was queued or last retried, so a retried delivery is tried as many
+	// times again before it's dead
+	Tries int `json:"tries"`
+
+	// NextAttempt is when the delivery is next tried, it is only set while
+	// the delivery is pending
+	NextAttempt *time.Time `json:"nextAttempt,omitempty" bson:",omitempty"`
+
+	Created time.Time `json:"created"`
+}
+
+// DeliveryAttempt records the response to sending a delivery
+type DeliveryAttempt struct {
+	Time time.Time `json:"time"`
+
+	// StatusCode is the HTTP status of the response, 0 when there wasn't one
+	StatusCode int    `json:"statusCode"`
+	Error      string `json:"error,omitempty" bson:",omitempty"`
+
+	// Duration is how long the attempt took in milliseconds
+	Duration int64 `json:"duration"`
+}
+
+// WebhookNotFoundError is returned when there is no webhook with the ID
+type WebhookNotFoundError struct {
+	ID      string
+	Message string
+}
+
+func (wnf WebhookNotFoundError) Error() string {
+	return fmt.Sprintf("%s : %s", wnf.Message, wnf.ID)
+}
+
+// DeliveryNotFoundError is returned when there is no delivery with the ID,
+// or none are due
+type DeliveryNotFoundError struct {
+	ID      string
+	Message string
+}
+
+func (dnf DeliveryNotFoundError) Error() string {
+	return fmt.Sprintf("%s : %s", dnf.Message, dnf.ID)
+}
@@ -0,0 +1,60 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DefaultWorkspace holds the records and fields of requests that don't name a
+// workspace, it always exists
+const DefaultWorkspace = "default"
+
+var workspaceName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// reservedWorkspaceNames can't be used for workspaces as Mongo keeps the
+// system. prefix of collection names, and the names of its own databases,
+// for itself
+var reservedWorkspaceNames = map[string]bool{
+	"system": true,
+	"admin":  true,
+	"local":  true,
+	"config": true,
+}
+
+// Workspace is a separate set of records and fields so several teams can keep
+// their own map on one deployment
+type Workspace struct {
+	Name      string    `json:"name" bson:"_id"`
+	Title     string    `json:"title"`
+	Created   time.Time `json:"created"`
+	CreatedBy string    `json:"createdBy"`
+}
+
+// ValidWorkspaceName reports whether the name can be used for a workspace, it
+// must be lower case letters, numbers and hyphens, up to 32 long, and not one
+// of the names reserved by Mongo
+func ValidWorkspaceName(name string) bool {
+	return workspaceName.MatchString(name) && !reservedWorkspaceNames[name]
+}
+
+// WorkspaceNotFoundError is returned when a workspace doesn't exist
+type WorkspaceNotFoundError struct {
+	Name    string
+	Message string
+}
+
+func (wnf WorkspaceNotFoundError) Error() string {
+	return fmt.Sprintf("%s : %s", wnf.Message, wnf.Name)
+}
+
+// WorkspaceExistsError is returned when creating a workspace whose name is
+// already taken
+type WorkspaceExistsError struct {
+	Name    string
+	Message string
+}
+
+func (we WorkspaceExistsError) Error() string {
+	return fmt.Sprintf("%s : %s", we.Message, we.Name)
+}
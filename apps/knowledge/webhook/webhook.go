@@ -0,0 +1,345 @@
+// Package webhook sends events about changes to records and fields to the
+// URLs subscribed to them, retrying failed deliveries with exponential
+// backoff until they're given up on as dead.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/events"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// Headers sent with every delivery
+const (
+	EventHeader     = "X-Hub-Event"
+	DeliveryHeader  = "X-Hub-Delivery"
+	SignatureHeader = "X-Hub-Signature-256"
+)
+
+// PendingSize is how many events can be waiting to have their deliveries
+// queued before later ones are dropped
+const PendingSize = 1024
+
+// NewSecret returns a random secret to sign deliveries with
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature of the payload sent in SignatureHeader, the hex
+// HMAC-SHA256 of the payload keyed with the secret of the webhook prefixed
+// with sha256=
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher queues a delivery to every webhook wanting each event it's
+// given and sends them
+type Dispatcher struct {
+	DB     database.Database
+	Client *http.Client
+
+	// MaxAttempts is how many times a delivery is tried before it's dead
+	MaxAttempts int
+
+	// Backoff is how long to wait before the first retry, it doubles after
+	// each one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Interval is how often to check for deliveries that are due
+	Interval time.Duration
+
+	due chan bool
+}
+
+// NewDispatcher returns a dispatcher that tries each delivery 8 times over
+// about an hour and a half before giving up on it
+func NewDispatcher(db database.Database) *Dispatcher {
+	return &Dispatcher{
+		DB:          db,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 8,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  time.Hour,
+		Interval:    5 * time.Second,
+		due:         make(chan bool, 1),
+	}
+}
+
+// backoff returns how long to wait before trying again after the attempt
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.Backoff
+	for i := 1; i < attempt && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+	return wait
+}
+
+// Run queues deliveries for the events published to the broker and sends
+// them until stop is closed
+func (d *Dispatcher) Run(broker *events.Broker, stop <-chan struct{}) {
+	queue := make(chan events.Event, events.BufferSize)
+	go d.listen(broker, queue, stop)
+
+	go func() {
+		for {
+			select {
+			case e := <-queue:
+				d.Enqueue(e)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		d.SendDue()
+
+		select {
+		case <-ticker.C:
+		case <-d.due:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// listen moves events from the broker on to the queue, holding on to up to
+// PendingSize of them while the queue is full so the subscription isn't
+// dropped when lots of records change at once
+func (d *Dispatcher) listen(broker *events.Broker, queue chan<- events.Event, stop <-chan struct{}) {
+	sub := broker.Subscribe(nil)
+	defer func() { sub.Close() }()
+
+	var pending []events.Event
+	for {
+		var out chan<- events.Event
+		var next events.Event
+		if len(pending) > 0 {
+			out = queue
+			next = pending[0]
+		}
+
+		select {
+		case e, open := <-sub.C:
+			if !open {
+				log.Warn("Webhooks fell behind the events, some will not be delivered")
+				sub = broker.Subscribe(nil)
+				continue
+			}
+			pending = hold(pending, e)
+		case out <- next:
+			pending = pending[1:]
+		case <-stop:
+			return
+		}
+	}
+}
+
+// hold adds the event to those waiting to be queued, dropping it if there
+// are already PendingSize waiting
+func hold(pending []events.Event, e events.Event) []events.Event {
+	if len(pending) >= PendingSize {
+		log.WithFields(log.Fields{
+			"event":     e.Type,
+			"id":        e.ID,
+			"workspace": e.Workspace,
+		}).Warn("Webhooks fell behind the events, dropping event")
+		return pending
+	}
+	return append(pending, e)
+}
+
+// Enqueue queues a delivery of the event to every webhook of its workspace
+// that wants it
+func (d *Dispatcher) Enqueue(e events.Event) {
+	db := d.DB.In(e.Workspace)
+
+	webhooks, err := db.Webhooks()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"workspace": e.Workspace,
+			"error":     err.Error(),
+		}).Error("Unable to get webhooks")
+		return
+	}
+
+	var payload []byte
+	queued := 0
+	for _, h := range webhooks {
+		if !h.Wants(e.Type) {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = json.Marshal(e); err != nil {
+				log.WithFields(log.Fields{
+					"error": err.Error(),
+				}).Error("Unable to encode event")
+				return
+			}
+		}
+
+		if _, err := db.AddDelivery(types.Delivery{WebhookID: h.ID, Event: e.Type, Payload: string(payload)}); err != nil {
+			log.WithFields(log.Fields{
+				"webhook":   h.ID,
+				"event":     e.Type,
+				"workspace": e.Workspace,
+				"error":     err.Error(),
+			}).Error("Unable to queue webhook delivery")
+			continue
+		}
+		queued++
+	}
+
+	if queued > 0 {
+		select {
+		case d.due <- true:
+		default:
+		}
+	}
+}
+
+// SendDue sends every delivery that's due in every workspace
+func (d *Dispatcher) SendDue() {
+	workspaces, err := d.DB.Workspaces()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Unable to list workspaces to send webhooks for")
+		return
+	}
+
+	for _, ws := range workspaces {
+		db := d.DB.In(ws.Name)
+		for {
+			delivery, err := db.ClaimDelivery(2 * d.Client.Timeout)
+			if err != nil {
+				if _, ok := err.(*types.DeliveryNotFoundError); !ok {
+					log.WithFields(log.Fields{
+						"workspace": ws.Name,
+						"error":     err.Error(),
+					}).Error("Unable to get deliveries that are due")
+				}
+				break
+			}
+			d.send(db, delivery)
+		}
+	}
+}
+
+// send tries a delivery once, then stores how it went and when to try again
+func (d *Dispatcher) send(db database.Database, delivery types.Delivery) {
+	logger := log.WithFields(log.Fields{
+		"webhook":  delivery.WebhookID,
+		"delivery": delivery.ID,
+		"event":    delivery.Event,
+	})
+
+	webhook, err := db.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		if _, ok := err.(*types.WebhookNotFoundError); !ok {
+			return
+		}
+		// The webhook was deleted after the delivery was claimed
+		delivery.Status = types.DeliveryDead
+		delivery.NextAttempt = nil
+		db.UpdateDelivery(delivery)
+		return
+	}
+
+	attempt := d.attempt(webhook, delivery)
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.Tries++
+
+	switch {
+	case attempt.Error == "":
+		delivery.Status = types.DeliveryDelivered
+		delivery.NextAttempt = nil
+		logger.WithFields(log.Fields{
+			"status": attempt.StatusCode,
+		}).Info("Delivered webhook")
+	case delivery.Tries >= d.MaxAttempts:
+		delivery.Status = types.DeliveryDead
+		delivery.NextAttempt = nil
+		logger.WithFields(log.Fields{
+			"status":   attempt.StatusCode,
+			"attempts": delivery.Tries,
+			"error":    attempt.Error,
+		}).Error("Giving up on webhook delivery")
+	default:
+		next := attempt.Time.Add(d.backoff(delivery.Tries))
+		delivery.NextAttempt = &next
+		logger.WithFields(log.Fields{
+			"status":      attempt.StatusCode,
+			"attempts":    delivery.Tries,
+			"nextAttempt": next,
+			"error":       attempt.Error,
+		}).Warn("Webhook delivery failed, will retry")
+	}
+
+	if err := db.UpdateDelivery(delivery); err != nil {
+		logger.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Unable to store webhook delivery")
+	}
+}
+
+// attempt posts the payload of the delivery to the webhook, only a 2xx
+// response counts as delivered
+func (d *Dispatcher) attempt(webhook types.Webhook, delivery types.Delivery) types.DeliveryAttempt {
+	start := time.Now().UTC()
+	attempt := types.DeliveryAttempt{Time: start}
+
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "knowledge-hub-webhooks")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, payload))
+
+	resp, err := d.Client.Do(req)
+	attempt.Duration = int64(time.Since(start) / time.Millisecond)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("Unexpected response %s", resp.Status)
+	}
+	return attempt
+}
@@ -0,0 +1,175 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cstdev/knowledge-hub/apps/knowledge/database"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/events"
+	"github.com/cstdev/knowledge-hub/apps/knowledge/types"
+)
+
+// deliveryDB keeps the deliveries to a single webhook in memory
+type deliveryDB struct {
+	database.FakeDB
+	webhook    types.Webhook
+	deliveries []types.Delivery
+}
+
+func (db *deliveryDB) In(workspace string) database.Database {
+	return db
+}
+
+func (db *deliveryDB) Workspaces() ([]types.Workspace, error) {
+	return []types.Workspace{{Name: types.DefaultWorkspace}}, nil
+}
+
+func (db *deliveryDB) Webhooks() ([]types.Webhook, error) {
+	return []types.Webhook{db.webhook}, nil
+}
+
+func (db *deliveryDB) GetWebhook(id string) (types.Webhook, error) {
+	if id != db.webhook.ID {
+		return types.Webhook{}, &types.WebhookNotFoundError{ID: id, Message: "Webhook does not exist in the database."}
+	}
+	return db.webhook, nil
+}
+
+func (db *deliveryDB) AddDelivery(d types.Delivery) (types.Delivery, error) {
+	now := time.Now()
+	d.ID = "delivery-1"
+	d.Status = types.DeliveryPending
+	d.NextAttempt = &now
+	db.deliveries = append(db.deliveries, d)
+	return d, nil
+}
+
+func (db *deliveryDB) ClaimDelivery(lease time.Duration) (types.Delivery, error) {
+	for i, d := range db.deliveries {
+		if d.Status == types.DeliveryPending && !d.NextAttempt.After(time.Now()) {
+			next := time.Now().Add(lease)
+			db.deliveries[i].NextAttempt = &next
+			return db.deliveries[i], nil
+		}
+	}
+	return types.Delivery{}, &types.DeliveryNotFoundError{Message: "No deliveries are due."}
+}
+
+func (db *deliveryDB) UpdateDelivery(d types.Delivery) error {
+	for i := range db.deliveries {
+		if db.deliveries[i].ID == d.ID {
+			db.deliveries[i] = d
+		}
+	}
+	return nil
+}
+
+func TestSign(t *testing.T) {
+	got := Sign("It's a Secret to Everybody", []byte("Hello, World!"))
+	want := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	if got != want {
+		t.Errorf("Expected %s but got %s", want, got)
+	}
+}
+
+func TestBackoffDoublesUpToTheMaximum(t *testing.T) {
+	d := NewDispatcher(&database.FakeDB{})
+
+	for attempt, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		4:  4 * time.Minute,
+		8:  time.Hour,
+		40: time.Hour,
+	} {
+		if got := d.backoff(attempt); got != want {
+			t.Errorf("Expected to wait %s after attempt %d but got %s", want, attempt, got)
+		}
+	}
+}
+
+func TestDeliveriesAreSignedAndSent(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer server.Close()
+
+	db := &deliveryDB{webhook: types.Webhook{ID: "hook-1", URL: server.URL, Secret: "whsec_0123456789abcdef", Events: []string{events.RecordCreated}}}
+	d := NewDispatcher(db)
+
+	d.Enqueue(events.Event{Type: events.RecordDeleted, Workspace: types.DefaultWorkspace, ID: "12345"})
+	if len(db.deliveries) != 0 {
+		t.Fatalf("Expected events the webhook doesn't want not to be queued but got %d", len(db.deliveries))
+	}
+
+	d.Enqueue(events.Event{Type: events.RecordCreated, Workspace: types.DefaultWorkspace, ID: "12345"})
+	d.SendDue()
+
+	if len(db.deliveries) != 1 || db.deliveries[0].Status != types.DeliveryDelivered {
+		t.Fatalf("Expected the delivery to be delivered but got %+v", db.deliveries)
+	}
+	if header.Get(SignatureHeader) != Sign("whsec_0123456789abcdef", body) {
+		t.Errorf("Expected the body to be signed with the secret but got %s", header.Get(SignatureHeader))
+	}
+	if header.Get(EventHeader) != events.RecordCreated || header.Get(DeliveryHeader) != "delivery-1" {
+		t.Errorf("Expected the event and delivery headers to be set but got %v", header)
+	}
+}
+
+func TestFailingDeliveriesAreRetriedThenDead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	db := &deliveryDB{webhook: types.Webhook{ID: "hook-1", URL: server.URL, Secret: "whsec_0123456789abcdef"}}
+	d := NewDispatcher(db)
+	d.MaxAttempts = 3
+	d.Backoff = 0
+
+	d.Enqueue(events.Event{Type: events.RecordUpdated, Workspace: types.DefaultWorkspace, ID: "12345"})
+	d.SendDue()
+
+	delivery := db.deliveries[0]
+	if delivery.Status != types.DeliveryDead || len(delivery.Attempts) != 3 {
+		t.Fatalf("Expected the delivery to be dead after 3 attempts but got %s after %d", delivery.Status, len(delivery.Attempts))
+	}
+	if delivery.Attempts[0].StatusCode != http.StatusServiceUnavailable || delivery.Attempts[0].Error == "" {
+		t.Errorf("Expected the failed response to be logged but got %+v", delivery.Attempts[0])
+	}
+	if delivery.NextAttempt != nil {
+		t.Error("Expected a dead delivery not to be tried again")
+	}
+
+	now := time.Now()
+	db.deliveries[0].Status = types.DeliveryPending
+	db.deliveries[0].Tries = 0
+	db.deliveries[0].NextAttempt = &now
+	d.SendDue()
+
+	delivery = db.deliveries[0]
+	if delivery.Status != types.DeliveryDead || len(delivery.Attempts) != 6 {
+		t.Errorf("Expected a retried delivery to be tried 3 more times but got %s after %d", delivery.Status, len(delivery.Attempts))
+	}
+}
+
+func TestEventsAreDroppedWhenTooManyArePending(t *testing.T) {
+	var pending []events.Event
+	for i := 0; i < PendingSize+10; i++ {
+		pending = hold(pending, events.Event{Type: events.RecordUpdated, ID: strconv.Itoa(i)})
+	}
+
+	if len(pending) != PendingSize {
+		t.Fatalf("Expected %d events to be held on to but got %d", PendingSize, len(pending))
+	}
+	if pending[PendingSize-1].ID != strconv.Itoa(PendingSize-1) {
+		t.Errorf("Expected the later events to be dropped but the last held was %s", pending[PendingSize-1].ID)
+	}
+}